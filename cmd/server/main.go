@@ -1,69 +1,165 @@
-package main
-
-import (
-	"context"
-	"log"
-	"os"
-	"review-service/internal/database"
-	"review-service/internal/handlers"
-	"review-service/internal/service"
-
-	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
-)
-
-func main() {
-	connString := os.Getenv("DATABASE_URL")
-	// connString = "postgres://user:password@db:5432/review_service?sslmode=disable"
-	if connString == "" {
-		log.Fatal("No database url set in enviroment")
-	}
-
-	db, err := database.NewDB(connString)
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer db.Close()
-
-	// Инициализация схемы БД
-	ctx := context.Background()
-	if err := db.InitSchema(ctx); err != nil {
-		log.Fatal("Failed to initialize database schema:", err)
-	}
-
-	svc := service.NewService(db)
-	handler := handlers.NewHandler(svc)
-
-	r := gin.Default()
-
-	// Swagger UI с кастомной спецификацией
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler,
-		ginSwagger.URL("/openapi.yaml")))
-
-	// Эндпоинт для обслуживания OpenAPI спецификации
-	r.GET("/openapi.yaml", func(c *gin.Context) {
-		c.File("./openapi.yaml")
-	})
-
-	// Teams
-	r.POST("/team/add", handler.CreateTeam)
-	r.GET("/team/get", handler.GetTeam)
-
-	// Users
-	r.POST("/users/setIsActive", handler.SetUserActive)
-	r.GET("/users/getReview", handler.GetUserPRs)
-
-	// Pull Requests
-	r.POST("/pullRequest/create", handler.CreatePR)
-	r.POST("/pullRequest/merge", handler.MergePR)
-	r.POST("/pullRequest/reassign", handler.ReassignReviewer)
-
-	// Health
-	r.GET("/health", handler.HealthCheck)
-
-	log.Println("Server starting on :8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
-	}
-}
+package main
+
+import (
+	"context"
+	"log"
+	"review-service/internal/config"
+	"review-service/internal/database"
+	"review-service/internal/handlers"
+	"review-service/internal/models"
+	"review-service/internal/openapi"
+	"review-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+
+	connString := cfg.DatabaseURL
+	// connString = "postgres://user:password@db:5432/review_service?sslmode=disable"
+	if connString == "" {
+		log.Fatal("No database url set in enviroment, set DATABASE_URL or DB_HOST")
+	}
+
+	switch cfg.TimestampPrecision {
+	case config.PrecisionMilli:
+		models.SetTimestampLayout(models.LayoutMilli)
+	case config.PrecisionSecond:
+		models.SetTimestampLayout(models.LayoutSecond)
+	}
+
+	db, err := database.NewDB(connString, database.Options{
+		SimpleProtocol:     cfg.DBSimpleProtocol,
+		HealthCheckPeriod:  cfg.DBHealthCheckPeriod,
+		SlowQueryThreshold: cfg.SlowQueryThreshold,
+	})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	// Инициализация схемы БД
+	ctx := context.Background()
+	if err := db.InitSchema(ctx); err != nil {
+		log.Fatal("Failed to initialize database schema:", err)
+	}
+
+	if err := db.SetMaxReviewers(ctx, cfg.MaxReviewers); err != nil {
+		log.Fatal("Failed to store MAX_REVIEWERS:", err)
+	}
+
+	if cfg.AllowSelfReview {
+		log.Println("WARNING: ALLOW_SELF_REVIEW is enabled, authors may review their own PRs. Do not use in production")
+	}
+
+	if cfg.PRNameOverflow == config.OverflowTruncate {
+		log.Printf("PR names longer than %d characters are truncated (PR_NAME_OVERFLOW=truncate)", cfg.MaxPRNameLength)
+	} else {
+		log.Printf("PR names longer than %d characters are rejected (PR_NAME_OVERFLOW=reject)", cfg.MaxPRNameLength)
+	}
+
+	svc := service.NewService(db, cfg)
+
+	if cfg.DeferOutsideReviewWindow || cfg.AssignmentGracePeriod > 0 {
+		go svc.RunAssignmentWorker(ctx, cfg.AssignmentWorkerInterval)
+	}
+	if cfg.NotificationDigestInterval > 0 {
+		go svc.RunDigestWorker(ctx, cfg.NotificationDigestInterval)
+	}
+	if cfg.SoftDeleteRetention > 0 {
+		go svc.RunPurgeWorker(ctx, cfg.SoftDeletePurgeInterval, cfg.SoftDeleteRetention)
+	}
+
+	handler := handlers.NewHandler(svc, cfg)
+
+	r := gin.Default()
+	r.Use(handler.ConcurrencyLimitMiddleware())
+	r.Use(handler.APIKeyMiddleware())
+	r.Use(handler.ActorMiddleware())
+
+	var specValidator *openapi.Validator
+	if cfg.ValidateOpenAPI {
+		if specValidator, err = openapi.Load("./openapi.yaml"); err != nil {
+			log.Fatal("Failed to load openapi.yaml for VALIDATE_OPENAPI:", err)
+		}
+	}
+	r.Use(handler.OpenAPIValidationMiddleware(specValidator))
+
+	// Swagger UI с кастомной спецификацией
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler,
+		ginSwagger.URL("/openapi.yaml")))
+
+	// Эндпоинт для обслуживания OpenAPI спецификации
+	r.GET("/openapi.yaml", func(c *gin.Context) {
+		c.File("./openapi.yaml")
+	})
+
+	// Teams
+	r.POST("/team/add", handler.CreateTeam)
+	r.POST("/team/addBatch", handler.CreateTeams)
+	r.GET("/team/get", handler.GetTeam)
+	r.GET("/team/reviewerLoad", handler.GetTeamReviewerLoad)
+	r.GET("/team/unassignedMembers", handler.GetTeamUnassignedMembers)
+
+	// Users
+	r.POST("/users/setIsActive", handler.SetUserActive)
+	r.POST("/users/setAcceptingReviews", handler.SetAcceptingReviews)
+	r.POST("/users/setAvailabilityFromCalendar", handler.SetCalendarAvailability)
+	r.POST("/users/setMentor", handler.SetMentor)
+	r.POST("/users/setNotificationPrefs", handler.SetNotificationPrefs)
+	r.GET("/users/getReview", handler.GetUserPRs)
+	r.GET("/users/pendingReviews", handler.GetPendingReviews)
+	r.GET("/users/approvalRate", handler.GetApprovalRate)
+
+	// Pull Requests
+	r.POST("/pullRequest/create", handler.CreatePR)
+	r.POST("/pullRequest/merge", handler.MergePR)
+	r.POST("/pullRequest/markReady", handler.MarkReady)
+	r.POST("/pullRequest/reassign", handler.ReassignReviewer)
+	r.POST("/pullRequest/approve", handler.ApprovePR)
+	r.POST("/pullRequest/delete", handler.DeletePR)
+	r.POST("/pullRequest/assignReviewer", handler.AssignReviewer)
+	r.POST("/pullRequest/unassignReviewer", handler.UnassignReviewer)
+	r.GET("/pullRequest/get", handler.GetPR)
+	r.GET("/pullRequest/reviewers", handler.GetPRReviewers)
+	r.GET("/pullRequest/:id/reviewers", handler.GetPRReviewersResource)
+	r.GET("/pullRequest/soleReviewer", handler.GetSoleReviewerPRs)
+	r.GET("/pullRequest/byReviewers", handler.GetPRsByReviewers)
+	r.GET("/pullRequest/list", handler.ListPRs)
+	r.GET("/pullRequest/reassignments", handler.GetReassignments)
+	r.GET("/labels", handler.GetLabels)
+
+	// Reviewer pools
+	r.POST("/pool/add", handler.CreatePool)
+	r.GET("/pool/get", handler.GetPool)
+	r.POST("/pool/addMember", handler.AddPoolMember)
+	r.POST("/pool/removeMember", handler.RemovePoolMember)
+
+	// Stats
+	r.GET("/stats/reviewerDistribution", handler.GetReviewerDistribution)
+	r.GET("/stats/teamCapacity", handler.GetTeamCapacity)
+	r.GET("/stats/mergeThroughput", handler.GetMergeThroughput)
+	r.GET("/stats/responseTimes", handler.GetResponseTimes)
+	r.GET("/stats/coverageGaps", handler.GetCoverageGaps)
+
+	// Health
+	r.GET("/health", handler.HealthCheck)
+	r.GET("/version", handler.Version)
+	r.GET("/metrics", handler.Metrics)
+	r.GET("/admin/schema", handler.GetSchema)
+	r.POST("/admin/reconcile", handler.Reconcile)
+	r.GET("/admin/decisions", handler.GetDecisions)
+	r.GET("/admin/flags", handler.GetFlags)
+	r.GET("/events", handler.GetEvents)
+
+	log.Println("Server starting on :8080")
+	if err := r.Run(":8080"); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
@@ -0,0 +1,618 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp precisions accepted by TIMESTAMP_PRECISION
+const (
+	PrecisionNano   = "ns"
+	PrecisionMilli  = "ms"
+	PrecisionSecond = "s"
+)
+
+// Reviewer selection strategies accepted by ASSIGNMENT_STRATEGY
+const (
+	StrategyRandom     = "random"
+	StrategyFreshPairs = "fresh_pairs"
+)
+
+// Over-long PR name handling accepted by PR_NAME_OVERFLOW
+const (
+	OverflowReject   = "reject"
+	OverflowTruncate = "truncate"
+)
+
+// Handling of inactive reviewers accepted by INACTIVE_REVIEWER_PRS
+const (
+	InactiveReviewerShow = "show"
+	InactiveReviewerWarn = "warn"
+	InactiveReviewerHide = "hide"
+)
+
+// Sources of a feature flag's effective value
+const (
+	FlagSourceDefault = "default"
+	FlagSourceFile    = "file"
+	FlagSourceEnv     = "env"
+)
+
+// Flag is a boolean setting with its effective value and where it came from
+type Flag struct {
+	Name    string
+	Enabled bool
+	Source  string
+}
+
+// APIKey is an accepted X-API-Key
+type APIKey struct {
+	// Name is the principal the key's changes are attributed to in the audit
+	// log
+	Name string
+
+	// Teams the key may modify, nil allows every team
+	Teams []string
+}
+
+// Config holds service settings read from the environment
+type Config struct {
+	// DatabaseURL is DATABASE_URL or, without it, built from the DB_* parts
+	DatabaseURL string
+
+	// TimestampPrecision controls how created_at/merged_at are serialized
+	TimestampPrecision string
+
+	// AllowSelfReview lets the author be assigned to their own PR.
+	// Intended for solo demo/test setups only, never for production
+	AllowSelfReview bool
+
+	// MaxReviewers caps the reviewers of one PR, enforced by the database too
+	MaxReviewers int
+
+	// MaxTeamBatch caps the number of teams in one /team/addBatch request
+	MaxTeamBatch int
+
+	// LenientIDs accepts JSON numbers for ID fields, turning them into strings
+	LenientIDs bool
+
+	// StrictFields rejects unknown names in the "fields" query parameter
+	// instead of ignoring them
+	StrictFields bool
+
+	// AssignmentStrategy selects how CreatePR picks reviewers
+	AssignmentStrategy string
+
+	// DBSimpleProtocol switches pgx to the simple protocol for PgBouncer
+	DBSimpleProtocol bool
+
+	// DBHealthCheckPeriod is how often the pool checks idle connections;
+	// 0 keeps the pgxpool default
+	DBHealthCheckPeriod time.Duration
+
+	// SlowQueryThreshold logs database queries taking at least this long;
+	// 0 disables the log
+	SlowQueryThreshold time.Duration
+
+	// MaxIdle excludes active users idle for longer than this from new
+	// assignments; 0 disables the cutoff
+	MaxIdle time.Duration
+
+	// RelaxedReassign lets a reassign with no fresh candidate fall back to an
+	// existing co-reviewer instead of failing with NO_CANDIDATE
+	RelaxedReassign bool
+
+	// DropUnreplaceableReviewer makes a reassign with no replacement remove
+	// the old reviewer instead of failing with NO_CANDIDATE, tried after the
+	// RELAXED_REASSIGN fallback
+	DropUnreplaceableReviewer bool
+
+	// BlockInactiveAuthors rejects PRs created by inactive authors
+	BlockInactiveAuthors bool
+
+	// StrictAuthorTeam rejects PRs whose author's team no longer exists,
+	// instead of assigning from whatever the author's team_name points at
+	StrictAuthorTeam bool
+
+	// DefaultMemberActive is the is_active of team members created without one
+	DefaultMemberActive bool
+
+	// AssignmentRetries bounds how many times CreatePR picks reviewers again
+	// when a picked reviewer stops taking reviews concurrently; 0 fails on the first
+	AssignmentRetries int
+
+	// DistinctReviewerTeams requires every reviewer of a PR with a reviewer
+	// pool to come from a different team, failing with NO_CANDIDATE when that
+	// can't be met. PRs without a pool pick from the author's team as usual
+	DistinctReviewerTeams bool
+
+	// SeniorCoverage reserves a seat on junior authors' PRs for a senior
+	// reviewer when one is available
+	SeniorCoverage bool
+
+	// RequiredReviewerPools names reviewer pools each of which must have an
+	// active member among a PR's reviewers for its review policy to be met.
+	// Only reported through policy_satisfied, assignment doesn't enforce it
+	RequiredReviewerPools []string
+
+	// ReassignAfterMergeWindow still allows reassigning reviewers this long
+	// after a PR merged; 0 rejects reassigning merged PRs right away
+	ReassignAfterMergeWindow time.Duration
+
+	// AutoRefillOnUnassign picks a replacement right away when unassigning a
+	// reviewer leaves a PR with fewer than MaxReviewers reviewers
+	AutoRefillOnUnassign bool
+
+	// ReviewCooldown keeps reviewers out of new assignments for this long
+	// after a PR they reviewed merges, unless nobody else is left; 0 disables it
+	ReviewCooldown time.Duration
+
+	// MaxDailyReviews keeps reviewers already assigned this many reviews on
+	// the current UTC day out of automatic assignment, unless everyone is
+	// capped; 0 disables it
+	MaxDailyReviews int
+
+	// DeferOutsideReviewWindow leaves PRs created outside their team's review
+	// window unassigned until the worker assigns them within the window
+	DeferOutsideReviewWindow bool
+
+	// AssignmentGracePeriod leaves new PRs unassigned for this long so
+	// reviewers can volunteer; the worker then assigns PRs still without
+	// reviewers. 0 assigns on creation
+	AssignmentGracePeriod time.Duration
+
+	// AssignmentWorkerInterval is how often the deferred assignment worker runs
+	AssignmentWorkerInterval time.Duration
+
+	// SemanticStatus422 answers business rule violations (merged PR, no
+	// candidate, ...) with 422 instead of the legacy 400/409
+	SemanticStatus422 bool
+
+	// TeamSuggestions adds near-match team names to a /team/get 404; off by
+	// default since it discloses team names
+	TeamSuggestions bool
+
+	// TimezoneBalancing makes sure at least one reviewer is in or close to
+	// their working hours, based on users' utc_offset_minutes
+	TimezoneBalancing bool
+
+	// AreaAffinityWindow reserves a reviewer seat for a candidate who authored
+	// a PR with one of the new PR's labels within this window; 0 disables it
+	AreaAffinityWindow time.Duration
+
+	// MaxConcurrentRequests caps requests in flight, the rest get 503
+	// OVERLOADED; 0 disables the limit
+	MaxConcurrentRequests int
+
+	// MaxPageOffset is the largest offset accepted by paginated listings,
+	// deeper pages must use the keyset cursor
+	MaxPageOffset int
+
+	// PRIDPrefix is prepended to server-generated PR ids
+	PRIDPrefix string
+
+	// MaxPRNameLength caps pull_request_name in characters; PRNameOverflow
+	// decides whether longer names are rejected or truncated to fit
+	MaxPRNameLength int
+	PRNameOverflow  string
+
+	// InactiveReviewerPRs decides what /users/getReview does for an
+	// inactive reviewer: list their PRs as usual, add a warning, or hide them
+	InactiveReviewerPRs string
+
+	// WebhookURL receives PR notifications for teams without their own webhook
+	WebhookURL string
+
+	// NotificationDigestInterval batches assignment and replacement
+	// notifications into one digest per team sent this often. 0 sends
+	// each event right away
+	NotificationDigestInterval time.Duration
+
+	// SoftDeleteRetention is how long soft-deleted PRs are kept before the
+	// purge worker removes them for good. 0 keeps them forever
+	SoftDeleteRetention time.Duration
+
+	// SoftDeletePurgeInterval is how often the purge worker runs
+	SoftDeletePurgeInterval time.Duration
+
+	// ValidateOpenAPI checks every request against openapi.yaml before it
+	// reaches the handlers
+	ValidateOpenAPI bool
+
+	// Flags lists every boolean setting in load order. A flag is set by its
+	// environment variable or, without one, by FEATURE_FLAGS_FILE
+	Flags []Flag
+
+	// APIKeys maps each accepted X-API-Key to its principal and team scope.
+	// Empty disables API key checks
+	APIKeys map[string]APIKey
+}
+
+func Load() (*Config, error) {
+	cfg := &Config{
+		DatabaseURL:         os.Getenv("DATABASE_URL"),
+		TimestampPrecision:  getEnv("TIMESTAMP_PRECISION", PrecisionNano),
+		AssignmentStrategy:  getEnv("ASSIGNMENT_STRATEGY", StrategyRandom),
+		WebhookURL:          getEnv("WEBHOOK_URL", ""),
+		PRIDPrefix:          getEnv("PR_ID_PREFIX", "pr-"),
+		PRNameOverflow:      getEnv("PR_NAME_OVERFLOW", OverflowReject),
+		InactiveReviewerPRs: getEnv("INACTIVE_REVIEWER_PRS", InactiveReviewerShow),
+	}
+
+	flags, err := loadFlagFile(getEnv("FEATURE_FLAGS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseURL == "" {
+		if cfg.DatabaseURL, err = databaseURLFromParts(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.AllowSelfReview, err = flags.bool("ALLOW_SELF_REVIEW", false); err != nil {
+		return nil, err
+	}
+	if cfg.MaxTeamBatch, err = getInt("MAX_TEAM_BATCH", 50); err != nil {
+		return nil, err
+	}
+	if cfg.MaxReviewers, err = getInt("MAX_REVIEWERS", 2); err != nil {
+		return nil, err
+	}
+	if cfg.MaxReviewers < 1 {
+		return nil, fmt.Errorf("MAX_REVIEWERS must be at least 1")
+	}
+	if cfg.StrictFields, err = flags.bool("STRICT_FIELDS", false); err != nil {
+		return nil, err
+	}
+	if cfg.LenientIDs, err = flags.bool("LENIENT_IDS", false); err != nil {
+		return nil, err
+	}
+	if cfg.DBSimpleProtocol, err = flags.bool("DB_SIMPLE_PROTOCOL", false); err != nil {
+		return nil, err
+	}
+	if cfg.DBHealthCheckPeriod, err = getDuration("DB_HEALTHCHECK_PERIOD", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBHealthCheckPeriod < 0 {
+		return nil, fmt.Errorf("DB_HEALTHCHECK_PERIOD must not be negative")
+	}
+	if cfg.SlowQueryThreshold, err = getDuration("SLOW_QUERY_THRESHOLD", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxIdle, err = getDuration("MAX_IDLE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ReviewCooldown, err = getDuration("REVIEW_COOLDOWN", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxDailyReviews, err = getInt("MAX_DAILY_REVIEWS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.AutoRefillOnUnassign, err = flags.bool("AUTO_REFILL_ON_UNASSIGN", false); err != nil {
+		return nil, err
+	}
+	if cfg.RelaxedReassign, err = flags.bool("RELAXED_REASSIGN", false); err != nil {
+		return nil, err
+	}
+	if cfg.DropUnreplaceableReviewer, err = flags.bool("DROP_UNREPLACEABLE_REVIEWER", false); err != nil {
+		return nil, err
+	}
+	if cfg.BlockInactiveAuthors, err = flags.bool("BLOCK_INACTIVE_AUTHORS", false); err != nil {
+		return nil, err
+	}
+	if cfg.StrictAuthorTeam, err = flags.bool("STRICT_AUTHOR_TEAM", false); err != nil {
+		return nil, err
+	}
+	if cfg.DefaultMemberActive, err = flags.bool("DEFAULT_MEMBER_ACTIVE", true); err != nil {
+		return nil, err
+	}
+	if cfg.AssignmentRetries, err = getInt("ASSIGNMENT_RETRIES", 3); err != nil {
+		return nil, err
+	}
+	if cfg.AssignmentRetries < 0 {
+		return nil, fmt.Errorf("ASSIGNMENT_RETRIES must not be negative")
+	}
+	if cfg.DistinctReviewerTeams, err = flags.bool("DISTINCT_REVIEWER_TEAMS", false); err != nil {
+		return nil, err
+	}
+	if cfg.SeniorCoverage, err = flags.bool("SENIOR_COVERAGE", false); err != nil {
+		return nil, err
+	}
+	cfg.RequiredReviewerPools = getList("REQUIRED_REVIEWER_POOLS")
+	if cfg.ReassignAfterMergeWindow, err = getDuration("REASSIGN_AFTER_MERGE_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DeferOutsideReviewWindow, err = flags.bool("DEFER_OUTSIDE_REVIEW_WINDOW", false); err != nil {
+		return nil, err
+	}
+	if cfg.AssignmentGracePeriod, err = getDuration("ASSIGNMENT_GRACE_PERIOD", 0); err != nil {
+		return nil, err
+	}
+	if cfg.AssignmentWorkerInterval, err = getDuration("ASSIGNMENT_WORKER_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.AssignmentWorkerInterval <= 0 {
+		return nil, fmt.Errorf("ASSIGNMENT_WORKER_INTERVAL must be positive")
+	}
+	if cfg.SemanticStatus422, err = flags.bool("SEMANTIC_STATUS_422", false); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPageOffset, err = getInt("MAX_PAGE_OFFSET", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.MaxConcurrentRequests, err = getInt("MAX_CONCURRENT_REQUESTS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.TimezoneBalancing, err = flags.bool("TIMEZONE_BALANCING", false); err != nil {
+		return nil, err
+	}
+	if cfg.TeamSuggestions, err = flags.bool("TEAM_SUGGESTIONS", false); err != nil {
+		return nil, err
+	}
+	if cfg.AreaAffinityWindow, err = getDuration("AREA_AFFINITY_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPRNameLength, err = getInt("MAX_PR_NAME_LENGTH", 500); err != nil {
+		return nil, err
+	}
+	// The column is VARCHAR(500)
+	if cfg.MaxPRNameLength < 1 || cfg.MaxPRNameLength > 500 {
+		return nil, fmt.Errorf("MAX_PR_NAME_LENGTH must be between 1 and 500")
+	}
+	if cfg.NotificationDigestInterval, err = getDuration("NOTIFICATION_DIGEST_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.NotificationDigestInterval < 0 {
+		return nil, fmt.Errorf("NOTIFICATION_DIGEST_INTERVAL must not be negative")
+	}
+	if cfg.SoftDeleteRetention, err = getDuration("SOFT_DELETE_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.SoftDeleteRetention < 0 {
+		return nil, fmt.Errorf("SOFT_DELETE_RETENTION must not be negative")
+	}
+	if cfg.SoftDeletePurgeInterval, err = getDuration("SOFT_DELETE_PURGE_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.SoftDeletePurgeInterval <= 0 {
+		return nil, fmt.Errorf("SOFT_DELETE_PURGE_INTERVAL must be positive")
+	}
+	if cfg.ValidateOpenAPI, err = flags.bool("VALIDATE_OPENAPI", false); err != nil {
+		return nil, err
+	}
+	if cfg.APIKeys, err = getAPIKeys("API_KEYS"); err != nil {
+		return nil, err
+	}
+	if cfg.Flags, err = flags.loaded(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.TimestampPrecision {
+	case PrecisionNano, PrecisionMilli, PrecisionSecond:
+	default:
+		return nil, fmt.Errorf("invalid TIMESTAMP_PRECISION %q: expected ns, ms or s", cfg.TimestampPrecision)
+	}
+
+	switch cfg.AssignmentStrategy {
+	case StrategyRandom, StrategyFreshPairs:
+	default:
+		return nil, fmt.Errorf("invalid ASSIGNMENT_STRATEGY %q: expected random or fresh_pairs", cfg.AssignmentStrategy)
+	}
+
+	switch cfg.PRNameOverflow {
+	case OverflowReject, OverflowTruncate:
+	default:
+		return nil, fmt.Errorf("invalid PR_NAME_OVERFLOW %q: expected reject or truncate", cfg.PRNameOverflow)
+	}
+
+	switch cfg.InactiveReviewerPRs {
+	case InactiveReviewerShow, InactiveReviewerWarn, InactiveReviewerHide:
+	default:
+		return nil, fmt.Errorf("invalid INACTIVE_REVIEWER_PRS %q: expected show, warn or hide", cfg.InactiveReviewerPRs)
+	}
+
+	return cfg, nil
+}
+
+// databaseURLFromParts builds the connection string from DB_HOST, DB_PORT,
+// DB_USER, DB_PASSWORD, DB_NAME and DB_SSLMODE, for deployments injecting them
+// separately. It returns "" without DB_HOST. url.URL escapes the special
+// characters a password may contain
+func databaseURLFromParts() (string, error) {
+	host := getEnv("DB_HOST", "")
+	if host == "" {
+		return "", nil
+	}
+
+	port, err := getInt("DB_PORT", 5432)
+	if err != nil {
+		return "", err
+	}
+
+	dbURL := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:   "/" + getEnv("DB_NAME", ""),
+	}
+	if user := getEnv("DB_USER", ""); user != "" {
+		// The password is taken verbatim, surrounding spaces included
+		if password := os.Getenv("DB_PASSWORD"); password != "" {
+			dbURL.User = url.UserPassword(user, password)
+		} else {
+			dbURL.User = url.User(user)
+		}
+	}
+	if sslMode := getEnv("DB_SSLMODE", ""); sslMode != "" {
+		dbURL.RawQuery = url.Values{"sslmode": {sslMode}}.Encode()
+	}
+	return dbURL.String(), nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getList splits a comma-separated value, dropping empty entries
+func getList(key string) []string {
+	var list []string
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func getInt(key string, fallback int) (int, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return parsed, nil
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return parsed, nil
+}
+
+func getBool(key string, fallback bool) (bool, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return parsed, nil
+}
+
+// flagLoader resolves boolean settings from the environment and the
+// FEATURE_FLAGS_FILE, recording each one it resolved
+type flagLoader struct {
+	file  map[string]bool
+	flags []Flag
+}
+
+// loadFlagFile reads a JSON object of flag names to booleans, e.g.
+// {"SENIOR_COVERAGE": true}. An empty path loads no file
+func loadFlagFile(path string) (*flagLoader, error) {
+	loader := &flagLoader{}
+	if path == "" {
+		return loader, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read FEATURE_FLAGS_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &loader.file); err != nil {
+		return nil, fmt.Errorf("invalid FEATURE_FLAGS_FILE %s: %w", path, err)
+	}
+	return loader, nil
+}
+
+// bool returns the flag from the environment, else from the file, else
+// fallback
+func (l *flagLoader) bool(key string, fallback bool) (bool, error) {
+	flag := Flag{Name: key, Enabled: fallback, Source: FlagSourceDefault}
+	if enabled, ok := l.file[key]; ok {
+		flag.Enabled, flag.Source = enabled, FlagSourceFile
+	}
+	if strings.TrimSpace(os.Getenv(key)) != "" {
+		enabled, err := getBool(key, flag.Enabled)
+		if err != nil {
+			return false, err
+		}
+		flag.Enabled, flag.Source = enabled, FlagSourceEnv
+	}
+
+	l.flags = append(l.flags, flag)
+	return flag.Enabled, nil
+}
+
+// loaded returns the resolved flags, failing on file entries that name no
+// flag so that a typo doesn't silently leave the default in place
+func (l *flagLoader) loaded() ([]Flag, error) {
+	for _, name := range slices.Sorted(maps.Keys(l.file)) {
+		if !slices.ContainsFunc(l.flags, func(flag Flag) bool { return flag.Name == name }) {
+			return nil, fmt.Errorf("unknown flag %q in FEATURE_FLAGS_FILE", name)
+		}
+	}
+	return l.flags, nil
+}
+
+// getAPIKeys parses "key:team-a|team-b:ci-bot,key2:*" into key -> principal
+// and team scope, "*" leaving the key unscoped. A key without a name is
+// attributed to "key-" and the start of its SHA-256, which tells keys apart
+// without revealing them
+func getAPIKeys(key string) (map[string]APIKey, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return nil, nil
+	}
+
+	keys := make(map[string]APIKey)
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected key:team[|team...][:name] or key:*[:name]", key, entry)
+		}
+		apiKey, teams := parts[0], parts[1]
+		if _, dup := keys[apiKey]; dup {
+			return nil, fmt.Errorf("invalid %s: duplicate key", key)
+		}
+
+		name := ""
+		if len(parts) == 3 {
+			name = parts[2]
+		}
+		if name == "" {
+			sum := sha256.Sum256([]byte(apiKey))
+			name = "key-" + hex.EncodeToString(sum[:4])
+		}
+
+		if teams == "*" {
+			keys[apiKey] = APIKey{Name: name}
+			continue
+		}
+		var scope []string
+		for _, team := range strings.Split(teams, "|") {
+			if team = strings.TrimSpace(team); team != "" {
+				scope = append(scope, team)
+			}
+		}
+		if len(scope) == 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: no teams", key, entry)
+		}
+		keys[apiKey] = APIKey{Name: name, Teams: scope}
+	}
+	return keys, nil
+}
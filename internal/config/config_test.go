@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/review")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MaxReviewers != 2 {
+		t.Errorf("MaxReviewers = %d, want 2", cfg.MaxReviewers)
+	}
+	if cfg.AssignmentStrategy != StrategyRandom {
+		t.Errorf("AssignmentStrategy = %q, want %q", cfg.AssignmentStrategy, StrategyRandom)
+	}
+	if cfg.MaxPRNameLength != 500 || cfg.PRNameOverflow != OverflowReject {
+		t.Errorf("PR name limit = %d/%s, want 500/reject", cfg.MaxPRNameLength, cfg.PRNameOverflow)
+	}
+	if cfg.AssignmentWorkerInterval != time.Minute {
+		t.Errorf("AssignmentWorkerInterval = %v, want 1m", cfg.AssignmentWorkerInterval)
+	}
+	if cfg.SoftDeleteRetention != 0 || cfg.SoftDeletePurgeInterval != time.Hour {
+		t.Errorf("soft delete purge = %v every %v, want disabled every 1h", cfg.SoftDeleteRetention, cfg.SoftDeletePurgeInterval)
+	}
+	if !cfg.DefaultMemberActive {
+		t.Error("DefaultMemberActive = false, want true")
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		key, value, want string
+	}{
+		{"ASSIGNMENT_STRATEGY", "round_robin", "invalid ASSIGNMENT_STRATEGY"},
+		{"TIMESTAMP_PRECISION", "us", "invalid TIMESTAMP_PRECISION"},
+		{"PR_NAME_OVERFLOW", "wrap", "invalid PR_NAME_OVERFLOW"},
+		{"INACTIVE_REVIEWER_PRS", "drop", "invalid INACTIVE_REVIEWER_PRS"},
+		{"MAX_REVIEWERS", "0", "MAX_REVIEWERS must be at least 1"},
+		{"MAX_REVIEWERS", "two", "invalid MAX_REVIEWERS"},
+		{"MAX_PR_NAME_LENGTH", "501", "MAX_PR_NAME_LENGTH must be between 1 and 500"},
+		{"ALLOW_SELF_REVIEW", "maybe", "invalid ALLOW_SELF_REVIEW"},
+		{"MAX_IDLE", "30", "invalid MAX_IDLE"},
+		{"SOFT_DELETE_RETENTION", "-1h", "SOFT_DELETE_RETENTION must not be negative"},
+		{"SOFT_DELETE_PURGE_INTERVAL", "0s", "SOFT_DELETE_PURGE_INTERVAL must be positive"},
+		{"API_KEYS", "key-without-scope", "invalid API_KEYS entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", "postgres://localhost/review")
+			t.Setenv(tt.key, tt.value)
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatabaseURLFromParts(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_PORT", "6432")
+	t.Setenv("DB_USER", "review")
+	t.Setenv("DB_PASSWORD", "p@ss/w:rd")
+	t.Setenv("DB_NAME", "reviews")
+	t.Setenv("DB_SSLMODE", "disable")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := "postgres://review:p%40ss%2Fw%3Ard@db:6432/reviews?sslmode=disable"; cfg.DatabaseURL != want {
+		t.Errorf("DatabaseURL = %q, want %q", cfg.DatabaseURL, want)
+	}
+}
+
+func TestGetAPIKeys(t *testing.T) {
+	t.Setenv("API_KEYS", "team-key: backend | frontend : ci-bot, ops-key:*")
+
+	keys, err := getAPIKeys("API_KEYS")
+	if err != nil {
+		t.Fatalf("getAPIKeys: %v", err)
+	}
+	teamKey := keys["team-key"]
+	if !slices.Equal(teamKey.Teams, []string{"backend", "frontend"}) || teamKey.Name != "ci-bot" {
+		t.Errorf("team-key = %+v, want ci-bot scoped to [backend frontend]", teamKey)
+	}
+	opsKey, ok := keys["ops-key"]
+	if !ok || opsKey.Teams != nil {
+		t.Errorf("ops-key = %+v (present %v), want unscoped", opsKey, ok)
+	}
+	// Unnamed keys get a stable name that doesn't contain the key
+	if !strings.HasPrefix(opsKey.Name, "key-") || strings.Contains(opsKey.Name, "ops-key") {
+		t.Errorf("ops-key name = %q, want a key- fingerprint", opsKey.Name)
+	}
+
+	t.Setenv("API_KEYS", "a:backend,a:frontend")
+	if _, err := getAPIKeys("API_KEYS"); err == nil {
+		t.Error("duplicate key accepted")
+	}
+}
+
+func TestFeatureFlagsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	if err := os.WriteFile(path, []byte(`{"SENIOR_COVERAGE": true, "STRICT_FIELDS": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATABASE_URL", "postgres://localhost/review")
+	t.Setenv("FEATURE_FLAGS_FILE", path)
+	t.Setenv("STRICT_FIELDS", "false")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.SeniorCoverage {
+		t.Error("SeniorCoverage from the file not applied")
+	}
+	if cfg.StrictFields {
+		t.Error("STRICT_FIELDS from the environment didn't override the file")
+	}
+
+	sources := make(map[string]Flag)
+	for _, flag := range cfg.Flags {
+		sources[flag.Name] = flag
+	}
+	want := map[string]Flag{
+		"SENIOR_COVERAGE":   {Name: "SENIOR_COVERAGE", Enabled: true, Source: FlagSourceFile},
+		"STRICT_FIELDS":     {Name: "STRICT_FIELDS", Enabled: false, Source: FlagSourceEnv},
+		"ALLOW_SELF_REVIEW": {Name: "ALLOW_SELF_REVIEW", Enabled: false, Source: FlagSourceDefault},
+	}
+	for name, flag := range want {
+		if sources[name] != flag {
+			t.Errorf("flag %s = %+v, want %+v", name, sources[name], flag)
+		}
+	}
+}
+
+func TestFeatureFlagsFileUnknownFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	if err := os.WriteFile(path, []byte(`{"SENIOR_COVERAG": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATABASE_URL", "postgres://localhost/review")
+	t.Setenv("FEATURE_FLAGS_FILE", path)
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), `unknown flag "SENIOR_COVERAG"`) {
+		t.Errorf("Load error = %v, want unknown flag", err)
+	}
+}
@@ -1,438 +1,2550 @@
-package database
-
-import (
-	"context"
-	"database/sql"
-	"fmt"
-	"os"
-	"review-service/internal/models"
-	"time"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-
-	"strings"
-)
-
-type DB struct {
-	pool *pgxpool.Pool
-}
-
-func NewDB(connString string) (*DB, error) {
-	pool, err := pgxpool.New(context.Background(), connString)
-	if err != nil {
-		return nil, err
-	}
-
-	// Test connection
-	if err := pool.Ping(context.Background()); err != nil {
-		return nil, err
-	}
-
-	return &DB{pool: pool}, nil
-}
-
-func (db *DB) Close() {
-	if db.pool != nil {
-		db.pool.Close()
-	}
-}
-
-// Team methods
-func (db *DB) CreateTeam(ctx context.Context, team *models.Team) error {
-	query := `INSERT INTO teams (name) VALUES ($1)`
-	_, err := db.pool.Exec(ctx, query, team.TeamName)
-	return err
-}
-
-func (db *DB) GetTeamByName(ctx context.Context, name string) (*models.Team, error) {
-	var team models.Team
-	query := `SELECT name FROM teams WHERE name = $1`
-	err := db.pool.QueryRow(ctx, query, name).Scan(&team.TeamName)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("team not found")
-		}
-		return nil, err
-	}
-
-	// Get team members
-	membersQuery := `SELECT user_id, username, is_active FROM users WHERE team_name = $1`
-	rows, err := db.pool.Query(ctx, membersQuery, name)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	team.Members = []models.TeamMember{}
-	for rows.Next() {
-		var member models.TeamMember
-		if err := rows.Scan(&member.UserID, &member.Username, &member.IsActive); err != nil {
-			return nil, err
-		}
-		team.Members = append(team.Members, member)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return &team, nil
-}
-
-func (db *DB) TeamExists(ctx context.Context, name string) (bool, error) {
-	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM teams WHERE name = $1)`
-	err := db.pool.QueryRow(ctx, query, name).Scan(&exists)
-	return exists, err
-}
-
-// User methods
-func (db *DB) CreateOrUpdateUser(ctx context.Context, user *models.User) error {
-	query := `INSERT INTO users (user_id, username, team_name, is_active) 
-              VALUES ($1, $2, $3, $4)
-              ON CONFLICT (user_id) DO UPDATE SET 
-              username = EXCLUDED.username, 
-              team_name = EXCLUDED.team_name, 
-              is_active = EXCLUDED.is_active`
-	_, err := db.pool.Exec(ctx, query, user.UserID, user.Username, user.TeamName, user.IsActive)
-	return err
-}
-
-func (db *DB) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
-	var user models.User
-	query := `SELECT user_id, username, team_name, is_active FROM users WHERE user_id = $1`
-	err := db.pool.QueryRow(ctx, query, userID).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
-func (db *DB) UpdateUser(ctx context.Context, user *models.User) error {
-	query := `UPDATE users SET username = $1, team_name = $2, is_active = $3 WHERE user_id = $4`
-	result, err := db.pool.Exec(ctx, query, user.Username, user.TeamName, user.IsActive, user.UserID)
-	if err != nil {
-		return err
-	}
-
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("user not found")
-	}
-
-	return nil
-}
-
-func (db *DB) GetActiveUsersByTeam(ctx context.Context, teamName string, excludeUserID string) ([]models.User, error) {
-	query := `SELECT user_id, username, team_name, is_active 
-              FROM users 
-              WHERE team_name = $1 AND is_active = true AND user_id != $2`
-	rows, err := db.pool.Query(ctx, query, teamName, excludeUserID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return users, nil
-}
-
-func (db *DB) UserExists(ctx context.Context, userID string) (bool, error) {
-	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)`
-	err := db.pool.QueryRow(ctx, query, userID).Scan(&exists)
-	return exists, err
-}
-
-// PR methods
-func (db *DB) CreatePR(ctx context.Context, pr *models.PullRequest) error {
-	tx, err := db.pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
-
-	// Insert PR
-	query := `INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, created_at) 
-              VALUES ($1, $2, $3, $4, $5)`
-	_, err = tx.Exec(ctx, query, pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, pr.CreatedAt)
-	if err != nil {
-		return err
-	}
-
-	// Insert reviewers
-	for _, reviewerID := range pr.AssignedReviewers {
-		_, err = tx.Exec(ctx,
-			`INSERT INTO pr_reviewers (pr_id, reviewer_id) VALUES ($1, $2)`,
-			pr.PullRequestID, reviewerID)
-		if err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit(ctx)
-}
-
-func (db *DB) GetPRByID(ctx context.Context, prID string) (*models.PullRequest, error) {
-	var pr models.PullRequest
-	var createdAt, mergedAt sql.NullTime
-
-	query := `SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at 
-              FROM pull_requests WHERE pull_request_id = $1`
-	err := db.pool.QueryRow(ctx, query, prID).Scan(
-		&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt,
-	)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("PR not found")
-		}
-		return nil, err
-	}
-
-	// Set timestamps if they exist
-	if createdAt.Valid {
-		pr.CreatedAt = &createdAt.Time
-	}
-	if mergedAt.Valid {
-		pr.MergedAt = &mergedAt.Time
-	}
-
-	// Get reviewers
-	reviewersQuery := `SELECT reviewer_id FROM pr_reviewers WHERE pr_id = $1`
-	rows, err := db.pool.Query(ctx, reviewersQuery, prID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	pr.AssignedReviewers = []string{}
-	for rows.Next() {
-		var reviewerID string
-		if err := rows.Scan(&reviewerID); err != nil {
-			return nil, err
-		}
-		pr.AssignedReviewers = append(pr.AssignedReviewers, reviewerID)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return &pr, nil
-}
-
-func (db *DB) UpdatePR(ctx context.Context, pr *models.PullRequest) error {
-	query := `UPDATE pull_requests 
-              SET pull_request_name = $1, author_id = $2, status = $3, merged_at = $4 
-              WHERE pull_request_id = $5`
-
-	result, err := db.pool.Exec(ctx, query,
-		pr.PullRequestName, pr.AuthorID, pr.Status, pr.MergedAt, pr.PullRequestID)
-	if err != nil {
-		return err
-	}
-
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("PR not found")
-	}
-
-	return nil
-}
-
-func (db *DB) UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus) error {
-	var mergedAt interface{}
-	if status == models.PRStatusMerged {
-		mergedAt = time.Now()
-	} else {
-		mergedAt = nil
-	}
-
-	query := `UPDATE pull_requests SET status = $1, merged_at = $2 WHERE pull_request_id = $3`
-	result, err := db.pool.Exec(ctx, query, status, mergedAt, prID)
-	if err != nil {
-		return err
-	}
-
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("PR not found")
-	}
-
-	return nil
-}
-
-func (db *DB) UpdatePRReviewers(ctx context.Context, prID string, reviewers []string) error {
-	tx, err := db.pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
-
-	// Delete existing reviewers
-	_, err = tx.Exec(ctx, `DELETE FROM pr_reviewers WHERE pr_id = $1`, prID)
-	if err != nil {
-		return err
-	}
-
-	// Insert new reviewers
-	for _, reviewerID := range reviewers {
-		_, err = tx.Exec(ctx,
-			`INSERT INTO pr_reviewers (pr_id, reviewer_id) VALUES ($1, $2)`,
-			prID, reviewerID)
-		if err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit(ctx)
-}
-
-func (db *DB) ReplaceReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) error {
-	query := `UPDATE pr_reviewers SET reviewer_id = $1 WHERE pr_id = $2 AND reviewer_id = $3`
-	result, err := db.pool.Exec(ctx, query, newReviewerID, prID, oldReviewerID)
-	if err != nil {
-		return err
-	}
-
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("reviewer not found in PR")
-	}
-
-	return nil
-}
-
-func (db *DB) GetPRsByReviewer(ctx context.Context, reviewerID string) ([]models.PullRequest, error) {
-	query := `SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.created_at, p.merged_at
-              FROM pull_requests p
-              JOIN pr_reviewers pr ON p.pull_request_id = pr.pr_id
-              WHERE pr.reviewer_id = $1`
-
-	rows, err := db.pool.Query(ctx, query, reviewerID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var prs []models.PullRequest
-	for rows.Next() {
-		var pr models.PullRequest
-		var createdAt, mergedAt sql.NullTime
-
-		err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt)
-		if err != nil {
-			return nil, err
-		}
-
-		// Set timestamps
-		if createdAt.Valid {
-			pr.CreatedAt = &createdAt.Time
-		}
-		if mergedAt.Valid {
-			pr.MergedAt = &mergedAt.Time
-		}
-
-		// Get reviewers for this PR
-		reviewerRows, err := db.pool.Query(ctx,
-			`SELECT reviewer_id FROM pr_reviewers WHERE pr_id = $1`, pr.PullRequestID)
-		if err != nil {
-			return nil, err
-		}
-
-		pr.AssignedReviewers = []string{}
-		for reviewerRows.Next() {
-			var reviewerID string
-			if err := reviewerRows.Scan(&reviewerID); err != nil {
-				reviewerRows.Close()
-				return nil, err
-			}
-			pr.AssignedReviewers = append(pr.AssignedReviewers, reviewerID)
-		}
-		reviewerRows.Close()
-
-		prs = append(prs, pr)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return prs, nil
-}
-
-func (db *DB) PRExists(ctx context.Context, prID string) (bool, error) {
-	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM pull_requests WHERE pull_request_id = $1)`
-	err := db.pool.QueryRow(ctx, query, prID).Scan(&exists)
-	return exists, err
-}
-
-func (db *DB) IsReviewerAssigned(ctx context.Context, prID, reviewerID string) (bool, error) {
-	var assigned bool
-	query := `SELECT EXISTS(SELECT 1 FROM pr_reviewers WHERE pr_id = $1 AND reviewer_id = $2)`
-	err := db.pool.QueryRow(ctx, query, prID, reviewerID).Scan(&assigned)
-	return assigned, err
-}
-
-// Health check
-func (db *DB) HealthCheck(ctx context.Context) error {
-	return db.pool.Ping(ctx)
-}
-
-// Migration and initialization
-func (db *DB) InitSchema(ctx context.Context) error {
-	// Check if tables already exist
-	var tablesExist bool
-	query := `SELECT EXISTS(
-        SELECT FROM information_schema.tables 
-        WHERE table_schema = 'public' AND table_name = 'teams'
-    )`
-	err := db.pool.QueryRow(ctx, query).Scan(&tablesExist)
-	if err != nil {
-		return err
-	}
-
-	if tablesExist {
-		return nil // Tables already exist
-	}
-
-	// Execute SQL migration file
-	sqlContent, err := os.ReadFile("migrations/001_init.sql")
-	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
-	}
-
-	// Split SQL by queries
-	queries := strings.Split(string(sqlContent), ";")
-
-	for _, query := range queries {
-		query = strings.TrimSpace(query)
-		if query == "" {
-			continue
-		}
-
-		// Add semicolon back
-		query = query + ";"
-
-		_, err := db.pool.Exec(ctx, query)
-		if err != nil {
-			return fmt.Errorf("failed to execute query: %s, error: %w", query, err)
-		}
-	}
-
-	return nil
-}
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"review-service/internal/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"strings"
+)
+
+type DB struct {
+	pool   *pgxpool.Pool
+	tracer *QueryTracer
+}
+
+// Options tunes the connection pool on top of the connection string
+type Options struct {
+	// SimpleProtocol disables prepared statements and their caches, which is
+	// required behind PgBouncer in transaction pooling mode
+	SimpleProtocol bool
+
+	// HealthCheckPeriod is how often idle connections are checked and dead
+	// ones pruned; 0 keeps the pgxpool default (1 minute)
+	HealthCheckPeriod time.Duration
+
+	// SlowQueryThreshold logs queries taking at least this long; 0 disables it
+	SlowQueryThreshold time.Duration
+}
+
+func NewDB(connString string, opts Options) (*DB, error) {
+	config, err := NewPoolConfig(connString, opts)
+	if err != nil {
+		return nil, err
+	}
+	tracer := NewQueryTracer(opts.SlowQueryThreshold)
+	config.ConnConfig.Tracer = tracer
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	if err := pool.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return &DB{pool: pool, tracer: tracer}, nil
+}
+
+// NewPoolConfig parses the connection string and applies opts to the result
+func NewPoolConfig(connString string, opts Options) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.SimpleProtocol {
+		config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+		config.ConnConfig.StatementCacheCapacity = 0
+		config.ConnConfig.DescriptionCacheCapacity = 0
+	}
+
+	if opts.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+
+	return config, nil
+}
+
+// WriteQueryMetrics writes the query statistics in the Prometheus text format
+func (db *DB) WriteQueryMetrics(w io.Writer) error {
+	return db.tracer.WritePrometheus(w)
+}
+
+func (db *DB) Close() {
+	if db.pool != nil {
+		db.pool.Close()
+	}
+}
+
+// Team methods
+// CreateTeam creates the team with its members and reports how many members
+// were new users and how many existing ones were updated
+func (db *DB) CreateTeam(ctx context.Context, team *models.Team) (*models.TeamMemberSummary, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	summary, err := createTeamTx(ctx, tx, team)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// CreateTeams creates several teams in one transaction. Each team runs in its
+// own savepoint, so a duplicate or failing team doesn't affect the others
+func (db *DB) CreateTeams(ctx context.Context, teams []models.Team) ([]models.TeamBatchResult, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]models.TeamBatchResult, 0, len(teams))
+	for i := range teams {
+		team := &teams[i]
+		result := models.TeamBatchResult{TeamName: team.TeamName}
+
+		var exists bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM teams WHERE name = $1)`, team.TeamName).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			result.Status = models.BatchStatusTeamExists
+		} else if err := createTeamSavepoint(ctx, tx, team); err != nil {
+			result.Status = models.BatchStatusError
+			result.Error = err.Error()
+		} else {
+			result.Status = models.BatchStatusCreated
+			result.Team = team
+		}
+
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func createTeamSavepoint(ctx context.Context, tx pgx.Tx, team *models.Team) error {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer savepoint.Rollback(ctx)
+
+	if _, err := createTeamTx(ctx, savepoint, team); err != nil {
+		return err
+	}
+
+	return savepoint.Commit(ctx)
+}
+
+func createTeamTx(ctx context.Context, tx pgx.Tx, team *models.Team) (*models.TeamMemberSummary, error) {
+	var createdAt time.Time
+	err := tx.QueryRow(ctx,
+		`INSERT INTO teams (name, created_by, assignment_seed, review_window_start, review_window_end, webhook_url)
+         VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, '')) RETURNING created_at`,
+		team.TeamName, team.CreatedBy, team.AssignmentSeed, team.ReviewWindowStart, team.ReviewWindowEnd,
+		team.WebhookURL).Scan(&createdAt)
+	if err != nil {
+		return nil, err
+	}
+	team.CreatedAt = models.NewTimestamp(createdAt)
+
+	if !team.MoveMembers && len(team.Members) > 0 {
+		ids := make([]string, len(team.Members))
+		for i, member := range team.Members {
+			ids[i] = member.UserID
+		}
+
+		var userID, teamName string
+		err := tx.QueryRow(ctx,
+			`SELECT user_id, team_name FROM users WHERE user_id = ANY($1) AND team_name <> $2 ORDER BY user_id LIMIT 1`,
+			ids, team.TeamName).Scan(&userID, &teamName)
+		if err == nil {
+			return nil, fmt.Errorf("%w: %s is in team %s", ErrUserInAnotherTeam, userID, teamName)
+		}
+		if err != pgx.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	summary := &models.TeamMemberSummary{}
+	for _, member := range team.Members {
+		var created bool
+		err = tx.QueryRow(ctx, upsertUserQuery, member.UserID, member.Username, team.TeamName, member.IsActive,
+			member.ManagerID, member.UTCOffsetMinutes, member.Seniority).Scan(&created)
+		if err != nil {
+			return nil, err
+		}
+
+		if created {
+			summary.MembersCreated++
+		} else {
+			summary.MembersUpdated++
+		}
+		if member.IsActive != nil && *member.IsActive {
+			summary.ActiveMembers++
+		}
+	}
+
+	return summary, nil
+}
+
+func (db *DB) GetTeamByName(ctx context.Context, name string) (*models.Team, error) {
+	var team models.Team
+	var createdBy, webhookURL sql.NullString
+	var createdAt sql.NullTime
+	query := `SELECT name, created_by, created_at, assignment_seed, review_window_start, review_window_end, webhook_url
+              FROM teams WHERE name = $1`
+	err := db.pool.QueryRow(ctx, query, name).Scan(&team.TeamName, &createdBy, &createdAt, &team.AssignmentSeed,
+		&team.ReviewWindowStart, &team.ReviewWindowEnd, &webhookURL)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("team not found")
+		}
+		return nil, err
+	}
+
+	team.CreatedBy = createdBy.String
+	team.WebhookURL = webhookURL.String
+	if createdAt.Valid {
+		team.CreatedAt = models.NewTimestamp(createdAt.Time)
+	}
+
+	// Get team members
+	membersQuery := `SELECT user_id, username, is_active, COALESCE(manager_id, ''), utc_offset_minutes,
+                            COALESCE(seniority, '')
+                     FROM users WHERE team_name = $1`
+	rows, err := db.pool.Query(ctx, membersQuery, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	team.Members = []models.TeamMember{}
+	for rows.Next() {
+		var member models.TeamMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.IsActive, &member.ManagerID,
+			&member.UTCOffsetMinutes, &member.Seniority); err != nil {
+			return nil, err
+		}
+		team.Members = append(team.Members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// GetTeamAssignmentSeed returns the team's reviewer selection seed, nil when
+// the team has none (or doesn't exist)
+func (db *DB) GetTeamAssignmentSeed(ctx context.Context, name string) (*int64, error) {
+	var seed *int64
+	query := `SELECT assignment_seed FROM teams WHERE name = $1`
+	err := db.pool.QueryRow(ctx, query, name).Scan(&seed)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// likeEscaper escapes LIKE wildcards so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SuggestTeamNames returns up to limit team names that match name ignoring
+// case, contain it, or are a prefix of it, closest in length first
+func (db *DB) SuggestTeamNames(ctx context.Context, name string, limit int) ([]string, error) {
+	query := `SELECT name FROM teams
+              WHERE name ILIKE '%' || $1 || '%'
+                 OR $2 ILIKE replace(replace(replace(name, '\', '\\'), '%', '\%'), '_', '\_') || '%'
+              ORDER BY lower(name) = lower($2) DESC, abs(length(name) - length($2)), name
+              LIMIT $3`
+	rows, err := db.pool.Query(ctx, query, likeEscaper.Replace(name), name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var team string
+		if err := rows.Scan(&team); err != nil {
+			return nil, err
+		}
+		names = append(names, team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// GetTeamReviewWindow returns the team's review window hours (UTC), nil when
+// the team has no window configured
+func (db *DB) GetTeamReviewWindow(ctx context.Context, name string) (start, end *int, err error) {
+	query := `SELECT review_window_start, review_window_end FROM teams WHERE name = $1`
+	err = db.pool.QueryRow(ctx, query, name).Scan(&start, &end)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, nil, err
+	}
+	return start, end, nil
+}
+
+// GetAuthorWebhookURL returns the webhook configured for the author's team,
+// empty when the team has none
+func (db *DB) GetAuthorWebhookURL(ctx context.Context, authorID string) (string, error) {
+	var url string
+	query := `SELECT COALESCE(t.webhook_url, '')
+              FROM users u JOIN teams t ON t.name = u.team_name
+              WHERE u.user_id = $1`
+	err := db.pool.QueryRow(ctx, query, authorID).Scan(&url)
+	if err != nil && err != pgx.ErrNoRows {
+		return "", err
+	}
+	return url, nil
+}
+
+// AddDigestItem queues a notification for the digest of the author's team
+func (db *DB) AddDigestItem(ctx context.Context, authorID, webhookURL string, payload []byte) error {
+	query := `INSERT INTO notification_digest_items (team_name, webhook_url, payload)
+              SELECT team_name, $2, $3 FROM users WHERE user_id = $1`
+	_, err := db.pool.Exec(ctx, query, authorID, webhookURL, payload)
+	return err
+}
+
+// TakeDigestItems removes and returns every queued digest notification in
+// the order they were queued. Deleting them claims them, so concurrent
+// flushes never send an item twice
+func (db *DB) TakeDigestItems(ctx context.Context) ([]models.DigestItem, error) {
+	query := `WITH taken AS (
+                  DELETE FROM notification_digest_items
+                  RETURNING id, team_name, webhook_url, payload
+              )
+              SELECT team_name, webhook_url, payload FROM taken ORDER BY id`
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.DigestItem{}
+	for rows.Next() {
+		var item models.DigestItem
+		if err := rows.Scan(&item.TeamName, &item.WebhookURL, &item.Payload); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetNeverAssignedMembers returns active team members who have never been
+// assigned as a reviewer, according to pr_reviewer_history
+func (db *DB) GetNeverAssignedMembers(ctx context.Context, teamName string) ([]models.User, error) {
+	query := `SELECT u.user_id, u.username, u.team_name, u.is_active
+              FROM users u
+              LEFT JOIN pr_reviewer_history h ON h.reviewer_id = u.user_id
+              WHERE u.team_name = $1 AND u.is_active AND h.reviewer_id IS NULL
+              ORDER BY u.user_id`
+	rows, err := db.pool.Query(ctx, query, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (db *DB) TeamExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM teams WHERE name = $1)`
+	err := db.pool.QueryRow(ctx, query, name).Scan(&exists)
+	return exists, err
+}
+
+// GetTeamReviewerLoad returns the number of open PRs each active team member
+// currently reviews, members without reviews included with 0
+func (db *DB) GetTeamReviewerLoad(ctx context.Context, teamName string) ([]models.ReviewerLoad, error) {
+	query := `SELECT u.user_id, u.username, COUNT(p.pull_request_id)
+              FROM users u
+              LEFT JOIN pr_reviewers r ON r.reviewer_id = u.user_id
+              LEFT JOIN pull_requests p ON p.pull_request_id = r.pr_id
+                   AND p.status = 'OPEN' AND p.deleted_at IS NULL
+              WHERE u.team_name = $1 AND u.is_active = true
+              GROUP BY u.user_id, u.username
+              ORDER BY u.user_id`
+	rows, err := db.pool.Query(ctx, query, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loads := []models.ReviewerLoad{}
+	for rows.Next() {
+		var load models.ReviewerLoad
+		if err := rows.Scan(&load.UserID, &load.Username, &load.OpenReviews); err != nil {
+			return nil, err
+		}
+		loads = append(loads, load)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return loads, nil
+}
+
+// GetTeamCapacity returns every team's active member count and the number of
+// reviews those members hold on open PRs
+func (db *DB) GetTeamCapacity(ctx context.Context) ([]models.TeamCapacity, error) {
+	query := `SELECT t.name,
+                     COUNT(DISTINCT u.user_id),
+                     COUNT(p.pull_request_id)
+              FROM teams t
+              LEFT JOIN users u ON u.team_name = t.name AND u.is_active
+              LEFT JOIN pr_reviewers r ON r.reviewer_id = u.user_id
+              LEFT JOIN pull_requests p ON p.pull_request_id = r.pr_id
+                   AND p.status = 'OPEN' AND p.deleted_at IS NULL
+              GROUP BY t.name
+              ORDER BY t.name`
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []models.TeamCapacity{}
+	for rows.Next() {
+		var team models.TeamCapacity
+		if err := rows.Scan(&team.TeamName, &team.ActiveMembers, &team.OpenReviews); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// GetLabels returns the distinct labels of non-deleted PRs with the number of
+// PRs carrying each, limited to PRs of the team's authors unless teamName is
+// empty
+func (db *DB) GetLabels(ctx context.Context, teamName string) ([]models.LabelUsage, error) {
+	query := `SELECT l.label, COUNT(*)
+              FROM pr_labels l
+              JOIN pull_requests p ON p.pull_request_id = l.pr_id AND p.deleted_at IS NULL
+              JOIN users u ON u.user_id = p.author_id
+              WHERE $1 = '' OR u.team_name = $1
+              GROUP BY l.label
+              ORDER BY l.label`
+	rows, err := db.pool.Query(ctx, query, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []models.LabelUsage{}
+	for rows.Next() {
+		var usage models.LabelUsage
+		if err := rows.Scan(&usage.Label, &usage.Count); err != nil {
+			return nil, err
+		}
+		labels = append(labels, usage)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// GetResponseTimes aggregates assignment-to-approval times per reviewer,
+// slowest first, optionally only for members of one team. Reviewers without
+// any assignment are left out
+func (db *DB) GetResponseTimes(ctx context.Context, teamName string) ([]models.ReviewerResponseTime, error) {
+	query := `SELECT u.user_id, u.username,
+                     COUNT(r.approved_at),
+                     COUNT(*) FILTER (WHERE r.approved_at IS NULL AND p.status = 'OPEN'),
+                     AVG(EXTRACT(EPOCH FROM r.approved_at - r.assigned_at)),
+                     PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM r.approved_at - r.assigned_at))
+              FROM pr_reviewers r
+              JOIN users u ON u.user_id = r.reviewer_id
+              JOIN pull_requests p ON p.pull_request_id = r.pr_id AND p.deleted_at IS NULL
+              WHERE $1 = '' OR u.team_name = $1
+              GROUP BY u.user_id, u.username
+              ORDER BY 5 DESC NULLS LAST, u.user_id`
+	rows, err := db.pool.Query(ctx, query, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := []models.ReviewerResponseTime{}
+	for rows.Next() {
+		var t models.ReviewerResponseTime
+		if err := rows.Scan(&t.UserID, &t.Username, &t.Responded, &t.Pending,
+			&t.AvgResponseSeconds, &t.MedianResponseSeconds); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return times, nil
+}
+
+// GetMergeThroughput counts PRs merged between since and until per bucket
+// ("day" or "week"), returning a row for every bucket in the range
+func (db *DB) GetMergeThroughput(ctx context.Context, bucket string, since, until time.Time) ([]models.ThroughputBucket, error) {
+	query := `SELECT b.start, COUNT(p.pull_request_id)
+              FROM generate_series(date_trunc($1, $2::timestamp), date_trunc($1, $3::timestamp),
+                                   ('1 ' || $1)::interval) AS b(start)
+              LEFT JOIN pull_requests p ON date_trunc($1, p.merged_at) = b.start
+                   AND p.status = 'MERGED' AND p.deleted_at IS NULL
+                   AND p.merged_at >= $2 AND p.merged_at <= $3
+              GROUP BY b.start
+              ORDER BY b.start`
+	rows, err := db.pool.Query(ctx, query, bucket, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []models.ThroughputBucket{}
+	for rows.Next() {
+		var start time.Time
+		var b models.ThroughputBucket
+		if err := rows.Scan(&start, &b.MergedCount); err != nil {
+			return nil, err
+		}
+		b.Start = models.NewTimestamp(start)
+		buckets = append(buckets, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// CompleteReviews records that the PR's reviewers finished a review at the
+// given time, starting their REVIEW_COOLDOWN
+func (db *DB) CompleteReviews(ctx context.Context, prID string, at time.Time) error {
+	query := `UPDATE users SET last_review_completed_at = $2
+              WHERE user_id IN (SELECT reviewer_id FROM pr_reviewers WHERE pr_id = $1)`
+	_, err := db.pool.Exec(ctx, query, prID, at)
+	return err
+}
+
+// CountOpenReviews returns the number of open PRs the user is a reviewer of
+func (db *DB) CountOpenReviews(ctx context.Context, userID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*)
+              FROM pr_reviewers r
+              JOIN pull_requests p ON p.pull_request_id = r.pr_id
+              WHERE r.reviewer_id = $1 AND p.status = 'OPEN' AND p.deleted_at IS NULL`
+	err := db.pool.QueryRow(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// GetReviewerDistribution counts the PRs authored by the team's members by
+// their number of reviewers, optionally only PRs with the given status
+func (db *DB) GetReviewerDistribution(ctx context.Context, teamName, status string) ([]models.ReviewerCountBucket, error) {
+	query := `SELECT reviewer_count, COUNT(*)
+              FROM (
+                  SELECT p.pull_request_id, COUNT(r.reviewer_id) AS reviewer_count
+                  FROM pull_requests p
+                  JOIN users u ON u.user_id = p.author_id
+                  LEFT JOIN pr_reviewers r ON r.pr_id = p.pull_request_id
+                  WHERE u.team_name = $1 AND p.deleted_at IS NULL
+                    AND ($2 = '' OR p.status = $2)
+                  GROUP BY p.pull_request_id
+              ) counts
+              GROUP BY reviewer_count
+              ORDER BY reviewer_count`
+	rows, err := db.pool.Query(ctx, query, teamName, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []models.ReviewerCountBucket{}
+	for rows.Next() {
+		var bucket models.ReviewerCountBucket
+		if err := rows.Scan(&bucket.ReviewerCount, &bucket.PRCount); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// User methods
+const upsertUserQuery = `INSERT INTO users (user_id, username, team_name, is_active, manager_id, utc_offset_minutes, seniority) 
+              VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''))
+              ON CONFLICT (user_id) DO UPDATE SET 
+              username = EXCLUDED.username, 
+              team_name = EXCLUDED.team_name, 
+              is_active = EXCLUDED.is_active,
+              manager_id = EXCLUDED.manager_id,
+              utc_offset_minutes = EXCLUDED.utc_offset_minutes,
+              seniority = EXCLUDED.seniority
+              RETURNING (xmax = 0)`
+
+func (db *DB) CreateOrUpdateUser(ctx context.Context, user *models.User) error {
+	_, err := db.pool.Exec(ctx, upsertUserQuery, user.UserID, user.Username, user.TeamName, user.IsActive,
+		user.ManagerID, user.UTCOffsetMinutes, user.Seniority)
+	return err
+}
+
+func (db *DB) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	var managerID sql.NullString
+	query := `SELECT user_id, username, team_name, is_active, accepting_reviews, manager_id, utc_offset_minutes,
+                     COALESCE(seniority, '')
+              FROM users WHERE user_id = $1`
+	err := db.pool.QueryRow(ctx, query, userID).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive,
+		&user.AcceptingReviews, &managerID, &user.UTCOffsetMinutes, &user.Seniority)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	user.ManagerID = managerID.String
+	return &user, nil
+}
+
+func (db *DB) UpdateUser(ctx context.Context, user *models.User) error {
+	query := `UPDATE users SET username = $1, team_name = $2, is_active = $3 WHERE user_id = $4`
+	result, err := db.pool.Exec(ctx, query, user.Username, user.TeamName, user.IsActive, user.UserID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetAcceptingReviews pauses or resumes new review assignments for the user
+func (db *DB) SetAcceptingReviews(ctx context.Context, userID string, accepting bool) error {
+	query := `UPDATE users SET accepting_reviews = $1 WHERE user_id = $2`
+	result, err := db.pool.Exec(ctx, query, accepting, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetCalendarBusy replaces the user's calendar busy window, nil clears it
+func (db *DB) SetCalendarBusy(ctx context.Context, userID string, busy *models.BusyWindow) error {
+	var busyFrom, busyUntil *time.Time
+	if busy != nil {
+		busyFrom, busyUntil = &busy.Start.Time, &busy.End.Time
+	}
+
+	query := `UPDATE users SET busy_from = $1, busy_until = $2 WHERE user_id = $3`
+	result, err := db.pool.Exec(ctx, query, busyFrom, busyUntil, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetNotificationPrefs replaces the user's notification preferences
+func (db *DB) SetNotificationPrefs(ctx context.Context, userID string, prefs models.NotificationPrefs) error {
+	query := `UPDATE users SET notification_prefs = $1 WHERE user_id = $2`
+	result, err := db.pool.Exec(ctx, query, prefs, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// GetUsersByIDs returns the existing users among userIDs, ordered by ID
+func (db *DB) GetUsersByIDs(ctx context.Context, userIDs []string) ([]models.User, error) {
+	query := `SELECT user_id, username, team_name, is_active, COALESCE(seniority, '') FROM users
+              WHERE user_id = ANY($1)
+              ORDER BY user_id`
+	rows, err := db.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive, &user.Seniority); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetNotifiableUsers returns the subset of userIDs, in the same order, who
+// want assignment notifications delivered on the channel
+func (db *DB) GetNotifiableUsers(ctx context.Context, userIDs []string, channel string) ([]string, error) {
+	query := `SELECT user_id FROM users
+              WHERE user_id = ANY($1)
+                AND COALESCE((notification_prefs->>'assignments')::boolean, true)
+                AND notification_prefs->'channels' ? $2`
+	rows, err := db.pool.Query(ctx, query, userIDs, channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool, len(userIDs))
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		wanted[userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	notifiable := []string{}
+	for _, userID := range userIDs {
+		if wanted[userID] {
+			notifiable = append(notifiable, userID)
+		}
+	}
+	return notifiable, nil
+}
+
+// SetAuthorMentor sets the mentor always assigned to the author's PRs, an
+// empty mentorID removes the rule
+func (db *DB) SetAuthorMentor(ctx context.Context, authorID, mentorID string) error {
+	if mentorID == "" {
+		_, err := db.pool.Exec(ctx, `DELETE FROM author_mentors WHERE author_id = $1`, authorID)
+		return err
+	}
+
+	query := `INSERT INTO author_mentors (author_id, mentor_id) VALUES ($1, $2)
+              ON CONFLICT (author_id) DO UPDATE SET mentor_id = EXCLUDED.mentor_id`
+	_, err := db.pool.Exec(ctx, query, authorID, mentorID)
+	return err
+}
+
+// GetAvailableMentor returns the author's mentor if one is configured and
+// currently active and accepting reviews, nil otherwise
+func (db *DB) GetAvailableMentor(ctx context.Context, authorID string) (*models.User, error) {
+	query := `SELECT u.user_id, u.username, u.team_name, u.is_active, u.last_active_at, u.manager_id, u.utc_offset_minutes,
+                     u.last_review_completed_at, u.busy_from, u.busy_until, COALESCE(u.seniority, '')
+              FROM author_mentors m JOIN users u ON u.user_id = m.mentor_id
+              WHERE m.author_id = $1 AND u.is_active AND u.accepting_reviews
+                AND NOT COALESCE(now() >= u.busy_from AND now() < u.busy_until, false)`
+	rows, err := db.pool.Query(ctx, query, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanCandidate(rows)
+}
+
+func (db *DB) GetActiveUsersByTeam(ctx context.Context, teamName string, excludeUserID string) ([]models.User, error) {
+	query := `SELECT user_id, username, team_name, is_active, last_active_at, manager_id, utc_offset_minutes,
+                     last_review_completed_at, busy_from, busy_until, COALESCE(seniority, '')
+              FROM users 
+              WHERE team_name = $1 AND is_active = true AND accepting_reviews AND user_id != $2
+              ORDER BY user_id`
+	rows, err := db.pool.Query(ctx, query, teamName, excludeUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanCandidate(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetCandidatePool returns every member of the reviewer pool, or of the team
+// when poolName is empty, including those that can't review right now
+func (db *DB) GetCandidatePool(ctx context.Context, teamName, poolName string) ([]models.User, error) {
+	query := `SELECT user_id, is_active, accepting_reviews
+              FROM users WHERE team_name = $1
+              ORDER BY user_id`
+	arg := teamName
+	if poolName != "" {
+		query = `SELECT u.user_id, u.is_active, u.accepting_reviews
+                 FROM users u
+                 JOIN reviewer_pool_members m ON m.user_id = u.user_id
+                 WHERE m.pool_name = $1
+                 ORDER BY u.user_id`
+		arg = poolName
+	}
+
+	rows, err := db.pool.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.UserID, &user.IsActive, &user.AcceptingReviews); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// scanCandidate scans a user row selected as a reviewer candidate
+func scanCandidate(rows pgx.Rows) (*models.User, error) {
+	var user models.User
+	var lastActiveAt, lastReviewCompletedAt, busyFrom, busyUntil sql.NullTime
+	var managerID sql.NullString
+	err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive, &lastActiveAt, &managerID,
+		&user.UTCOffsetMinutes, &lastReviewCompletedAt, &busyFrom, &busyUntil, &user.Seniority)
+	if err != nil {
+		return nil, err
+	}
+	user.ManagerID = managerID.String
+	if lastActiveAt.Valid {
+		user.LastActiveAt = models.NewTimestamp(lastActiveAt.Time)
+	}
+	if lastReviewCompletedAt.Valid {
+		user.LastReviewCompletedAt = models.NewTimestamp(lastReviewCompletedAt.Time)
+	}
+	if busyFrom.Valid && busyUntil.Valid {
+		user.CalendarBusy = &models.BusyWindow{
+			Start: models.Timestamp{Time: busyFrom.Time},
+			End:   models.Timestamp{Time: busyUntil.Time},
+		}
+	}
+	return &user, nil
+}
+
+// TouchUser records that the user did something in the service just now
+func (db *DB) TouchUser(ctx context.Context, userID string) error {
+	_, err := db.pool.Exec(ctx, `UPDATE users SET last_active_at = now() WHERE user_id = $1`, userID)
+	return err
+}
+
+func (db *DB) UserExists(ctx context.Context, userID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)`
+	err := db.pool.QueryRow(ctx, query, userID).Scan(&exists)
+	return exists, err
+}
+
+// PR methods
+// CreatePR inserts the PR with its labels and reviewers. The reviewers' user
+// rows are share-locked first, waiting out concurrent changes to them; if any
+// reviewer no longer takes reviews nothing is written and those reviewers are
+// returned, so the caller can pick again without them
+func (db *DB) CreatePR(ctx context.Context, pr *models.PullRequest) ([]string, error) {
+	if !pr.Status.IsValid() {
+		return nil, ErrInvalidStatus
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	unavailable, err := lockReviewersTx(ctx, tx, pr.AssignedReviewers)
+	if err != nil || len(unavailable) > 0 {
+		return unavailable, err
+	}
+
+	// Insert PR
+	query := `INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, created_at,
+                                       reviewer_pool, pending_assignment, review_required) 
+              VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, COALESCE($8, true))`
+	_, err = tx.Exec(ctx, query, pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, pr.CreatedAt,
+		pr.ReviewerPool, pr.PendingAssignment, pr.ReviewRequired)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, label := range pr.Labels {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO pr_labels (pr_id, label) VALUES ($1, $2) ON CONFLICT DO NOTHING`, pr.PullRequestID, label)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Insert reviewers
+	for _, reviewerID := range pr.AssignedReviewers {
+		if err := insertReviewerTx(ctx, tx, pr.PullRequestID, reviewerID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, tx.Commit(ctx)
+}
+
+// lockReviewersTx share-locks the user rows of the reviewers that still take
+// reviews and returns the rest. Concurrent creates don't block each other; a
+// row being updated is waited for and rechecked once the update commits, so a
+// deactivation is seen while a mere activity touch doesn't drop the reviewer.
+// Rows are locked in user_id order so creates can't deadlock each other
+func lockReviewersTx(ctx context.Context, tx pgx.Tx, reviewers []string) ([]string, error) {
+	if len(reviewers) == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx,
+		`SELECT user_id FROM users
+         WHERE user_id = ANY($1) AND is_active AND accepting_reviews
+         ORDER BY user_id
+         FOR SHARE`, reviewers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked := make(map[string]bool, len(reviewers))
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		locked[userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unavailable []string
+	for _, reviewer := range reviewers {
+		if !locked[reviewer] {
+			unavailable = append(unavailable, reviewer)
+		}
+	}
+	return unavailable, nil
+}
+
+func (db *DB) GetPRByID(ctx context.Context, prID string) (*models.PullRequest, error) {
+	var pr models.PullRequest
+	var createdAt, mergedAt sql.NullTime
+
+	query := `SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at,
+                     COALESCE(reviewer_pool, ''), pending_assignment, review_required
+              FROM pull_requests WHERE pull_request_id = $1 AND deleted_at IS NULL`
+	err := db.pool.QueryRow(ctx, query, prID).Scan(
+		&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt,
+		&pr.ReviewerPool, &pr.PendingAssignment, &pr.ReviewRequired,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrPRNotFound
+		}
+		return nil, err
+	}
+
+	// Set timestamps if they exist
+	if createdAt.Valid {
+		pr.CreatedAt = models.NewTimestamp(createdAt.Time)
+	}
+	if mergedAt.Valid {
+		pr.MergedAt = models.NewTimestamp(mergedAt.Time)
+	}
+
+	// Get reviewers
+	pr.AssignedReviewers, err = db.GetPRReviewerIDs(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Labels, err = db.GetPRLabels(ctx, prID); err != nil {
+		return nil, err
+	}
+
+	return &pr, nil
+}
+
+// GetPRLabels returns the PR's labels in alphabetical order
+func (db *DB) GetPRLabels(ctx context.Context, prID string) ([]string, error) {
+	rows, err := db.pool.Query(ctx, `SELECT label FROM pr_labels WHERE pr_id = $1 ORDER BY label`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []string
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// GetTeamsOfUsers returns the distinct teams the users belong to, in
+// alphabetical order
+func (db *DB) GetTeamsOfUsers(ctx context.Context, userIDs []string) ([]string, error) {
+	query := `SELECT DISTINCT team_name FROM users WHERE user_id = ANY($1) ORDER BY team_name`
+	rows, err := db.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []string{}
+	for rows.Next() {
+		var team string
+		if err := rows.Scan(&team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// GetPoolsOfUsers maps each of userIDs that belongs to a reviewer pool to
+// the names of its pools
+func (db *DB) GetPoolsOfUsers(ctx context.Context, userIDs []string) (map[string][]string, error) {
+	query := `SELECT user_id, pool_name FROM reviewer_pool_members WHERE user_id = ANY($1) ORDER BY pool_name`
+	rows, err := db.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := make(map[string][]string)
+	for rows.Next() {
+		var userID, pool string
+		if err := rows.Scan(&userID, &pool); err != nil {
+			return nil, err
+		}
+		pools[userID] = append(pools[userID], pool)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pools, nil
+}
+
+// GetRecentAreaAuthors returns those of userIDs who authored a PR carrying
+// one of the labels since the given time
+func (db *DB) GetRecentAreaAuthors(ctx context.Context, userIDs, labels []string, since time.Time) ([]string, error) {
+	query := `SELECT DISTINCT p.author_id
+              FROM pull_requests p
+              JOIN pr_labels l ON l.pr_id = p.pull_request_id
+              WHERE p.author_id = ANY($1) AND l.label = ANY($2)
+                AND p.created_at >= $3 AND p.deleted_at IS NULL
+              ORDER BY p.author_id`
+	rows, err := db.pool.Query(ctx, query, userIDs, labels, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := []string{}
+	for rows.Next() {
+		var authorID string
+		if err := rows.Scan(&authorID); err != nil {
+			return nil, err
+		}
+		authors = append(authors, authorID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// GetPRReviewerIDs returns the PR's reviewers ordered by username (then user
+// id), the order used in every PR response
+func (db *DB) GetPRReviewerIDs(ctx context.Context, prID string) ([]string, error) {
+	query := `SELECT r.reviewer_id
+              FROM pr_reviewers r LEFT JOIN users u ON u.user_id = r.reviewer_id
+              WHERE r.pr_id = $1
+              ORDER BY u.username, r.reviewer_id`
+	rows, err := db.pool.Query(ctx, query, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviewers := []string{}
+	for rows.Next() {
+		var reviewerID string
+		if err := rows.Scan(&reviewerID); err != nil {
+			return nil, err
+		}
+		reviewers = append(reviewers, reviewerID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviewers, nil
+}
+
+// GetReviewersForPRs returns the reviewers of each PR in one query, in the
+// same order as GetPRReviewerIDs. PRs without reviewers are absent from the map
+func (db *DB) GetReviewersForPRs(ctx context.Context, prIDs []string) (map[string][]string, error) {
+	reviewers := make(map[string][]string, len(prIDs))
+	if len(prIDs) == 0 {
+		return reviewers, nil
+	}
+
+	query := `SELECT r.pr_id, r.reviewer_id
+              FROM pr_reviewers r LEFT JOIN users u ON u.user_id = r.reviewer_id
+              WHERE r.pr_id = ANY($1)
+              ORDER BY r.pr_id, u.username, r.reviewer_id`
+	rows, err := db.pool.Query(ctx, query, prIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var prID, reviewerID string
+		if err := rows.Scan(&prID, &reviewerID); err != nil {
+			return nil, err
+		}
+		reviewers[prID] = append(reviewers[prID], reviewerID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviewers, nil
+}
+
+func (db *DB) UpdatePR(ctx context.Context, pr *models.PullRequest) error {
+	if !pr.Status.IsValid() {
+		return ErrInvalidStatus
+	}
+
+	query := `UPDATE pull_requests 
+              SET pull_request_name = $1, author_id = $2, status = $3, merged_at = $4 
+              WHERE pull_request_id = $5 AND deleted_at IS NULL`
+
+	result, err := db.pool.Exec(ctx, query,
+		pr.PullRequestName, pr.AuthorID, pr.Status, pr.MergedAt, pr.PullRequestID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("PR not found")
+	}
+
+	return nil
+}
+
+// MergePR marks an open PR merged and returns when. A PR that isn't open
+// (already merged, a draft or deleted) is left as is and false is returned,
+// so of concurrent merges exactly one succeeds
+func (db *DB) MergePR(ctx context.Context, prID string) (time.Time, bool, error) {
+	var mergedAt time.Time
+	query := `UPDATE pull_requests SET status = 'MERGED', merged_at = now()
+              WHERE pull_request_id = $1 AND status = 'OPEN' AND deleted_at IS NULL
+              RETURNING merged_at`
+	err := db.pool.QueryRow(ctx, query, prID).Scan(&mergedAt)
+	if err == pgx.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return mergedAt, true, nil
+}
+
+func (db *DB) UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus) error {
+	if !status.IsValid() {
+		return ErrInvalidStatus
+	}
+
+	var mergedAt interface{}
+	if status == models.PRStatusMerged {
+		mergedAt = time.Now()
+	} else {
+		mergedAt = nil
+	}
+
+	query := `UPDATE pull_requests SET status = $1, merged_at = $2
+              WHERE pull_request_id = $3 AND deleted_at IS NULL`
+	result, err := db.pool.Exec(ctx, query, status, mergedAt, prID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("PR not found")
+	}
+
+	return nil
+}
+
+// ReassignPRReviewers replaces the PR's reviewers. The PR row is locked
+// first, so a concurrent merge or delete lands entirely before or after the
+// change: a deleted PR fails with ErrPRNotFound, a merged one with
+// ErrPRMerged unless merged after mergedAfter (nil: never allowed). Then the
+// replacement's user row is locked and rechecked, so a concurrent deactivation
+// can't leave an inactive reviewer. It returns false without changes if the
+// replacement is no longer active. An empty replacementID only removes
+func (db *DB) ReassignPRReviewers(ctx context.Context, prID string, reviewers []string, replacementID string, mergedAfter *time.Time) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status models.PullRequestStatus
+	var mergedAt sql.NullTime
+	err = tx.QueryRow(ctx,
+		`SELECT status, merged_at FROM pull_requests
+         WHERE pull_request_id = $1 AND deleted_at IS NULL FOR UPDATE`, prID).Scan(&status, &mergedAt)
+	if err == pgx.ErrNoRows {
+		return false, ErrPRNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	if status == models.PRStatusMerged &&
+		(mergedAfter == nil || !mergedAt.Valid || mergedAt.Time.Before(*mergedAfter)) {
+		return false, ErrPRMerged
+	}
+
+	if replacementID == "" {
+		if err := updatePRReviewersTx(ctx, tx, prID, reviewers); err != nil {
+			return false, err
+		}
+		return true, tx.Commit(ctx)
+	}
+
+	var isActive bool
+	err = tx.QueryRow(ctx, `SELECT is_active AND accepting_reviews FROM users WHERE user_id = $1 FOR UPDATE`,
+		replacementID).Scan(&isActive)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !isActive {
+		return false, nil
+	}
+
+	if err := updatePRReviewersTx(ctx, tx, prID, reviewers); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+func updatePRReviewersTx(ctx context.Context, tx pgx.Tx, prID string, reviewers []string) error {
+	// Delete reviewers that are no longer assigned, the remaining rows keep
+	// their notes and approvals
+	_, err := tx.Exec(ctx, `DELETE FROM pr_reviewers WHERE pr_id = $1 AND reviewer_id <> ALL($2)`, prID, reviewers)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE pr_reviewer_history SET removed_at = now()
+         WHERE pr_id = $1 AND reviewer_id <> ALL($2) AND removed_at IS NULL`, prID, reviewers)
+	if err != nil {
+		return err
+	}
+
+	// Insert new reviewers
+	for _, reviewerID := range reviewers {
+		if err := insertReviewerTx(ctx, tx, prID, reviewerID, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ErrInvalidStatus is returned instead of writing an unknown PR status
+var ErrInvalidStatus = errors.New("invalid PR status")
+
+// ErrUserInAnotherTeam is returned instead of silently moving a member of
+// another team into a new one
+var ErrUserInAnotherTeam = errors.New("user belongs to another team")
+
+// ErrReviewerNotAssigned is returned by ApproveReview when the user isn't a
+// reviewer of the PR
+var ErrReviewerNotAssigned = errors.New("reviewer not assigned")
+
+// ErrUserNotFound is returned by GetUserByID for an unknown user, telling it
+// apart from a failed query
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrPRNotFound and ErrPRMerged report the PR's state found under the row
+// lock of a reviewer change, ErrPRNotFound also an unknown or deleted PR in
+// GetPRByID and SoftDeletePR
+var (
+	ErrPRNotFound = errors.New("PR not found")
+	ErrPRMerged   = errors.New("PR merged")
+)
+
+// maxReviewersConstraint is raised by the pr_reviewers trigger on exceeding the cap
+const maxReviewersConstraint = "pr_reviewers_max_reviewers"
+
+// ErrTooManyReviewers is returned when an insert would exceed the reviewer cap
+var ErrTooManyReviewers = errors.New("too many reviewers")
+
+// insertReviewerTx assigns the reviewer unless already assigned and records
+// the assignment in pr_reviewer_history. Errors name the failing reviewer
+func insertReviewerTx(ctx context.Context, tx pgx.Tx, prID, reviewerID, note string) error {
+	result, err := tx.Exec(ctx,
+		`INSERT INTO pr_reviewers (pr_id, reviewer_id, note) VALUES ($1, $2, NULLIF($3, '')) ON CONFLICT DO NOTHING`,
+		prID, reviewerID, note)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == maxReviewersConstraint {
+			err = ErrTooManyReviewers
+		}
+		return fmt.Errorf("failed to assign reviewer %s to PR %s: %w", reviewerID, prID, err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO pr_reviewer_history (pr_id, reviewer_id) VALUES ($1, $2)`, prID, reviewerID)
+	if err != nil {
+		return fmt.Errorf("failed to record assignment of reviewer %s to PR %s: %w", reviewerID, prID, err)
+	}
+	return nil
+}
+
+// GetAssignmentCountsSince counts each user's reviewer assignments made at or
+// after since, removed ones included. Users without any are left out
+func (db *DB) GetAssignmentCountsSince(ctx context.Context, userIDs []string, since time.Time) (map[string]int, error) {
+	query := `SELECT reviewer_id, COUNT(*)
+              FROM pr_reviewer_history
+              WHERE reviewer_id = ANY($1) AND assigned_at >= $2
+              GROUP BY reviewer_id`
+	rows, err := db.pool.Query(ctx, query, userIDs, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetReviewerHistory returns every reviewer assignment of the PR in the order
+// they were made, removed ones included
+func (db *DB) GetReviewerHistory(ctx context.Context, prID string) ([]models.ReviewerHistoryEntry, error) {
+	query := `SELECT reviewer_id, assigned_at, removed_at
+              FROM pr_reviewer_history
+              WHERE pr_id = $1
+              ORDER BY assigned_at, id`
+	rows, err := db.pool.Query(ctx, query, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.ReviewerHistoryEntry{}
+	for rows.Next() {
+		var entry models.ReviewerHistoryEntry
+		var assignedAt time.Time
+		var removedAt sql.NullTime
+		if err := rows.Scan(&entry.UserID, &assignedAt, &removedAt); err != nil {
+			return nil, err
+		}
+		entry.AssignedAt = models.NewTimestamp(assignedAt)
+		if removedAt.Valid {
+			entry.RemovedAt = models.NewTimestamp(removedAt.Time)
+		}
+		history = append(history, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GetPendingAssignmentPRs returns open PRs whose reviewer assignment was deferred
+func (db *DB) GetPendingAssignmentPRs(ctx context.Context) ([]models.PullRequest, error) {
+	query := `SELECT pull_request_id, pull_request_name, author_id, status, COALESCE(reviewer_pool, ''), created_at
+              FROM pull_requests
+              WHERE pending_assignment AND status = 'OPEN' AND deleted_at IS NULL
+              ORDER BY created_at`
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prs := []models.PullRequest{}
+	for rows.Next() {
+		pr := models.PullRequest{PendingAssignment: true}
+		var createdAt time.Time
+		if err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &pr.ReviewerPool,
+			&createdAt); err != nil {
+			return nil, err
+		}
+		pr.CreatedAt = models.NewTimestamp(createdAt)
+		prs = append(prs, pr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+// CompletePendingAssignment stores the reviewers of a deferred PR and clears
+// its pending flag. It returns false when the PR was no longer pending
+func (db *DB) CompletePendingAssignment(ctx context.Context, prID string, reviewers []string) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx,
+		`UPDATE pull_requests SET pending_assignment = false
+         WHERE pull_request_id = $1 AND pending_assignment AND deleted_at IS NULL`, prID)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	for _, reviewerID := range reviewers {
+		if err := insertReviewerTx(ctx, tx, prID, reviewerID, ""); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// MarkPRReady turns a draft PR into an open one, leaving its assignment to the
+// worker if pending, and assigns the reviewers in the same transaction. It
+// reports false if the PR is no longer a draft
+func (db *DB) MarkPRReady(ctx context.Context, prID string, pending bool, reviewers []string) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx,
+		`UPDATE pull_requests SET status = 'OPEN', pending_assignment = $2
+         WHERE pull_request_id = $1 AND status = 'DRAFT' AND deleted_at IS NULL`, prID, pending)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	for _, reviewerID := range reviewers {
+		if err := insertReviewerTx(ctx, tx, prID, reviewerID, ""); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// AddReviewer assigns one more reviewer to the PR with an optional note
+func (db *DB) AddReviewer(ctx context.Context, prID, reviewerID, note string) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertReviewerTx(ctx, tx, prID, reviewerID, note); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetPRReviewers returns the reviewers of the PR with their assignment details
+func (db *DB) GetPRReviewers(ctx context.Context, prID string) ([]models.Reviewer, error) {
+	query := `SELECT r.reviewer_id, COALESCE(r.note, ''), r.assigned_at, r.approved_at
+              FROM pr_reviewers r LEFT JOIN users u ON u.user_id = r.reviewer_id
+              WHERE r.pr_id = $1
+              ORDER BY u.username, r.reviewer_id`
+	rows, err := db.pool.Query(ctx, query, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviewers := []models.Reviewer{}
+	for rows.Next() {
+		var reviewer models.Reviewer
+		var assignedAt, approvedAt sql.NullTime
+		if err := rows.Scan(&reviewer.UserID, &reviewer.Note, &assignedAt, &approvedAt); err != nil {
+			return nil, err
+		}
+		if assignedAt.Valid {
+			reviewer.AssignedAt = models.NewTimestamp(assignedAt.Time)
+		}
+		if approvedAt.Valid {
+			reviewer.ApprovedAt = models.NewTimestamp(approvedAt.Time)
+		}
+		if assignedAt.Valid && approvedAt.Valid {
+			seconds := int64(approvedAt.Time.Sub(assignedAt.Time) / time.Second)
+			reviewer.ResponseSeconds = &seconds
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviewers, nil
+}
+
+// prListBatchSize is how many PRs ListPRs reads per keyset page
+const prListBatchSize = 500
+
+// ListPRs calls fn for every non-deleted PR matching the filters, ordered by
+// (created_at, pull_request_id). Rows are read in keyset pages so the whole
+// result set is never held in memory; empty filters match everything
+func (db *DB) ListPRs(ctx context.Context, status, authorID string, fn func(models.PullRequest) error) error {
+	query := `SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.created_at, p.merged_at,
+                     COALESCE((SELECT array_agg(r.reviewer_id ORDER BY u.username, r.reviewer_id)
+                               FROM pr_reviewers r LEFT JOIN users u ON u.user_id = r.reviewer_id
+                               WHERE r.pr_id = p.pull_request_id), '{}'),
+                     p.review_required
+              FROM pull_requests p
+              WHERE p.deleted_at IS NULL
+                AND ($1 = '' OR p.status = $1)
+                AND ($2 = '' OR p.author_id = $2)
+                AND ($3::timestamp IS NULL OR (p.created_at, p.pull_request_id) > ($3, $4))
+              ORDER BY p.created_at, p.pull_request_id
+              LIMIT $5`
+
+	var lastCreatedAt *time.Time
+	var lastID string
+	for {
+		rows, err := db.pool.Query(ctx, query, status, authorID, lastCreatedAt, lastID, prListBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var batch []models.PullRequest
+		for rows.Next() {
+			var pr models.PullRequest
+			var createdAt time.Time
+			var mergedAt sql.NullTime
+			err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt,
+				&pr.AssignedReviewers, &pr.ReviewRequired)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			pr.CreatedAt = models.NewTimestamp(createdAt)
+			if mergedAt.Valid {
+				pr.MergedAt = models.NewTimestamp(mergedAt.Time)
+			}
+			batch = append(batch, pr)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, pr := range batch {
+			if err := fn(pr); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < prListBatchSize {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		lastCreatedAt, lastID = &last.CreatedAt.Time, last.PullRequestID
+	}
+}
+
+// GetSoleReviewerPRs returns OPEN PRs where reviewerID is the only assigned reviewer
+func (db *DB) GetSoleReviewerPRs(ctx context.Context, reviewerID string) ([]models.PullRequestShort, error) {
+	query := `SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status
+              FROM pull_requests p
+              JOIN pr_reviewers r ON p.pull_request_id = r.pr_id
+              WHERE p.status = 'OPEN' AND p.deleted_at IS NULL
+                AND p.pull_request_id IN (SELECT pr_id FROM pr_reviewers WHERE reviewer_id = $1)
+              GROUP BY p.pull_request_id
+              HAVING COUNT(*) = 1
+              ORDER BY p.created_at`
+
+	rows, err := db.pool.Query(ctx, query, reviewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prs := []models.PullRequestShort{}
+	for rows.Next() {
+		var pr models.PullRequestShort
+		if err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status); err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+// GetCoverageGaps returns the page of open PRs, across all teams, none of
+// whose reviewers is active, ordered by (created_at, pull_request_id). PRs that
+// need no review or wait for deferred assignment aren't gaps
+func (db *DB) GetCoverageGaps(ctx context.Context, page models.Page) ([]models.CoverageGap, error) {
+	query := `SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.created_at, u.team_name,
+                     COALESCE(array_agg(r.reviewer_id ORDER BY r.reviewer_id) FILTER (WHERE r.reviewer_id IS NOT NULL), '{}')
+              FROM pull_requests p
+              JOIN users u ON u.user_id = p.author_id
+              LEFT JOIN pr_reviewers r ON r.pr_id = p.pull_request_id
+              WHERE p.status = 'OPEN' AND p.deleted_at IS NULL
+                AND p.review_required AND NOT p.pending_assignment
+                AND NOT EXISTS (
+                    SELECT 1 FROM pr_reviewers ar JOIN users ru ON ru.user_id = ar.reviewer_id
+                    WHERE ar.pr_id = p.pull_request_id AND ru.is_active
+                )
+                AND ($1::timestamp IS NULL OR (p.created_at, p.pull_request_id) > ($1, $2))
+              GROUP BY p.pull_request_id, u.team_name
+              ORDER BY p.created_at, p.pull_request_id
+              LIMIT $3 OFFSET $4`
+
+	var limit *int
+	if page.Limit > 0 {
+		limit = &page.Limit
+	}
+
+	var afterCreatedAt *time.Time
+	var afterID string
+	if page.After != nil {
+		afterCreatedAt, afterID = &page.After.At, page.After.PullRequestID
+	}
+
+	rows, err := db.pool.Query(ctx, query, afterCreatedAt, afterID, limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gaps := []models.CoverageGap{}
+	for rows.Next() {
+		var gap models.CoverageGap
+		var createdAt sql.NullTime
+		if err := rows.Scan(&gap.PullRequestID, &gap.PullRequestName, &gap.AuthorID, &gap.Status, &createdAt,
+			&gap.TeamName, &gap.InactiveReviewers); err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			gap.CreatedAt = models.NewTimestamp(createdAt.Time)
+		}
+		gaps = append(gaps, gap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return gaps, nil
+}
+
+// GetPRsByReviewer returns the page of the reviewer's PRs ordered by when
+// they were assigned, (assigned_at, pull_request_id). page.After continues
+// after the given PR, so a PR assigned between pages lands on a later page
+// whatever its age
+func (db *DB) GetPRsByReviewer(ctx context.Context, reviewerID string, page models.Page) ([]models.PullRequest, error) {
+	return db.getPRsByReviewer(ctx, reviewerID, false, page)
+}
+
+// GetPendingReviews returns the open PRs the reviewer hasn't approved yet
+func (db *DB) GetPendingReviews(ctx context.Context, reviewerID string, page models.Page) ([]models.PullRequest, error) {
+	return db.getPRsByReviewer(ctx, reviewerID, true, page)
+}
+
+func (db *DB) getPRsByReviewer(ctx context.Context, reviewerID string, pendingOnly bool, page models.Page) ([]models.PullRequest, error) {
+	query := `SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.created_at, p.merged_at, pr.assigned_at
+              FROM pull_requests p
+              JOIN pr_reviewers pr ON p.pull_request_id = pr.pr_id
+              WHERE pr.reviewer_id = $1 AND p.deleted_at IS NULL
+                AND ($2::timestamp IS NULL OR (pr.assigned_at, p.pull_request_id) > ($2, $6))
+                AND (NOT $5 OR (p.status = 'OPEN' AND pr.approved_at IS NULL))
+              ORDER BY pr.assigned_at, p.pull_request_id
+              LIMIT $3 OFFSET $4`
+
+	var limit *int
+	if page.Limit > 0 {
+		limit = &page.Limit
+	}
+
+	var afterAssignedAt *time.Time
+	var afterID string
+	if page.After != nil {
+		afterAssignedAt, afterID = &page.After.At, page.After.PullRequestID
+	}
+
+	rows, err := db.pool.Query(ctx, query, reviewerID, afterAssignedAt, limit, page.Offset, pendingOnly, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prs := []models.PullRequest{}
+	for rows.Next() {
+		var pr models.PullRequest
+		var createdAt, mergedAt sql.NullTime
+		var assignedAt time.Time
+
+		err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt, &assignedAt)
+		if err != nil {
+			return nil, err
+		}
+		pr.AssignedAt = models.NewTimestamp(assignedAt)
+
+		// Set timestamps
+		if createdAt.Valid {
+			pr.CreatedAt = models.NewTimestamp(createdAt.Time)
+		}
+		if mergedAt.Valid {
+			pr.MergedAt = models.NewTimestamp(mergedAt.Time)
+		}
+
+		prs = append(prs, pr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fetch the reviewers of the whole page at once
+	prIDs := make([]string, len(prs))
+	for i, pr := range prs {
+		prIDs[i] = pr.PullRequestID
+	}
+	reviewers, err := db.GetReviewersForPRs(ctx, prIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range prs {
+		prs[i].AssignedReviewers = models.EmptyIfNil(reviewers[prs[i].PullRequestID])
+	}
+
+	return prs, nil
+}
+
+// GetPRsByReviewers returns the PRs reviewed by any of the users, or with
+// matchAll by every one of them, ordered by (created_at, id). userIDs must
+// be free of duplicates, matchAll compares their count to the matches
+func (db *DB) GetPRsByReviewers(ctx context.Context, userIDs []string, matchAll bool, page models.Page) ([]models.PullRequest, error) {
+	query := `SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.created_at, p.merged_at
+              FROM pull_requests p
+              JOIN pr_reviewers r ON p.pull_request_id = r.pr_id
+              WHERE r.reviewer_id = ANY($1) AND p.deleted_at IS NULL
+                AND ($2::timestamp IS NULL OR (p.created_at, p.pull_request_id) > ($2, $3))
+              GROUP BY p.pull_request_id
+              HAVING NOT $4 OR COUNT(DISTINCT r.reviewer_id) = cardinality($1::text[])
+              ORDER BY p.created_at, p.pull_request_id
+              LIMIT $5 OFFSET $6`
+
+	var limit *int
+	if page.Limit > 0 {
+		limit = &page.Limit
+	}
+
+	var afterCreatedAt *time.Time
+	var afterID string
+	if page.After != nil {
+		afterCreatedAt, afterID = &page.After.At, page.After.PullRequestID
+	}
+
+	rows, err := db.pool.Query(ctx, query, userIDs, afterCreatedAt, afterID, matchAll, limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prs := []models.PullRequest{}
+	for rows.Next() {
+		var pr models.PullRequest
+		var createdAt, mergedAt sql.NullTime
+
+		err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if createdAt.Valid {
+			pr.CreatedAt = models.NewTimestamp(createdAt.Time)
+		}
+		if mergedAt.Valid {
+			pr.MergedAt = models.NewTimestamp(mergedAt.Time)
+		}
+
+		prs = append(prs, pr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+func (db *DB) PRExists(ctx context.Context, prID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM pull_requests WHERE pull_request_id = $1 AND deleted_at IS NULL)`
+	err := db.pool.QueryRow(ctx, query, prID).Scan(&exists)
+	return exists, err
+}
+
+// PRIDTaken reports whether any PR, soft-deleted ones included, uses the ID
+func (db *DB) PRIDTaken(ctx context.Context, prID string) (bool, error) {
+	var taken bool
+	query := `SELECT EXISTS(SELECT 1 FROM pull_requests WHERE pull_request_id = $1)`
+	err := db.pool.QueryRow(ctx, query, prID).Scan(&taken)
+	return taken, err
+}
+
+// SoftDeletePR hides the PR from lookups and listings, keeping its rows for audit
+func (db *DB) SoftDeletePR(ctx context.Context, prID string) (time.Time, error) {
+	var deletedAt time.Time
+	query := `UPDATE pull_requests SET deleted_at = now()
+              WHERE pull_request_id = $1 AND deleted_at IS NULL
+              RETURNING deleted_at`
+	err := db.pool.QueryRow(ctx, query, prID).Scan(&deletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return time.Time{}, ErrPRNotFound
+		}
+		return time.Time{}, err
+	}
+	return deletedAt, nil
+}
+
+// PurgeDeletedPRs hard-deletes PRs soft-deleted before the cutoff together
+// with their reviewer history and assignment decisions, returning how many
+// PRs were removed. Reviewers and labels go with the PR by cascade, audit
+// events are kept
+func (db *DB) PurgeDeletedPRs(ctx context.Context, before time.Time) (int, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `DELETE FROM pull_requests
+         WHERE deleted_at IS NOT NULL AND deleted_at < $1
+         RETURNING pull_request_id`, before)
+	if err != nil {
+		return 0, err
+	}
+	var prIDs []string
+	for rows.Next() {
+		var prID string
+		if err := rows.Scan(&prID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		prIDs = append(prIDs, prID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(prIDs) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM pr_reviewer_history WHERE pr_id = ANY($1)`, prIDs); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM assignment_decisions WHERE pull_request_id = ANY($1)`, prIDs); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(prIDs), nil
+}
+
+// GetLastPairings returns, for every pair of the given users that reviewed a
+// PR together, when that last happened. Keys are ordered so that key[0] < key[1]
+func (db *DB) GetLastPairings(ctx context.Context, userIDs []string) (map[[2]string]time.Time, error) {
+	query := `SELECT a.reviewer_id, b.reviewer_id, MAX(p.created_at)
+              FROM pr_reviewers a
+              JOIN pr_reviewers b ON b.pr_id = a.pr_id AND a.reviewer_id < b.reviewer_id
+              JOIN pull_requests p ON p.pull_request_id = a.pr_id
+              WHERE a.reviewer_id = ANY($1) AND b.reviewer_id = ANY($1)
+              GROUP BY a.reviewer_id, b.reviewer_id`
+	rows, err := db.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pairings := make(map[[2]string]time.Time)
+	for rows.Next() {
+		var first, second string
+		var lastPaired time.Time
+		if err := rows.Scan(&first, &second, &lastPaired); err != nil {
+			return nil, err
+		}
+		pairings[[2]string{first, second}] = lastPaired
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pairings, nil
+}
+
+// ApproveReview marks the reviewer's assignment as approved, keeping the first approval time
+func (db *DB) ApproveReview(ctx context.Context, prID, reviewerID string) error {
+	query := `UPDATE pr_reviewers SET approved_at = COALESCE(approved_at, now())
+              WHERE pr_id = $1 AND reviewer_id = $2`
+	result, err := db.pool.Exec(ctx, query, prID, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrReviewerNotAssigned
+	}
+
+	return nil
+}
+
+// GetApprovalStats counts the reviewer's assignments on PRs merged since the
+// given time and how many of them the reviewer approved before merge
+func (db *DB) GetApprovalStats(ctx context.Context, reviewerID string, since time.Time) (assigned, approved int, err error) {
+	query := `SELECT COUNT(*), COUNT(r.approved_at)
+              FROM pr_reviewers r
+              JOIN pull_requests p ON p.pull_request_id = r.pr_id
+              WHERE r.reviewer_id = $1 AND p.status = 'MERGED' AND p.merged_at >= $2
+                AND p.deleted_at IS NULL`
+	err = db.pool.QueryRow(ctx, query, reviewerID, since).Scan(&assigned, &approved)
+	return assigned, approved, err
+}
+
+// Pool methods
+func (db *DB) CreatePool(ctx context.Context, pool *models.ReviewerPool) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO reviewer_pools (name) VALUES ($1)`, pool.PoolName)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range pool.Members {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO reviewer_pool_members (pool_name, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			pool.PoolName, member.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (db *DB) GetPoolByName(ctx context.Context, name string) (*models.ReviewerPool, error) {
+	var pool models.ReviewerPool
+	query := `SELECT name FROM reviewer_pools WHERE name = $1`
+	err := db.pool.QueryRow(ctx, query, name).Scan(&pool.PoolName)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("pool not found")
+		}
+		return nil, err
+	}
+
+	membersQuery := `SELECT u.user_id, u.username, u.team_name, u.is_active
+                     FROM users u
+                     JOIN reviewer_pool_members m ON m.user_id = u.user_id
+                     WHERE m.pool_name = $1`
+	rows, err := db.pool.Query(ctx, membersQuery, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pool.Members = []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive); err != nil {
+			return nil, err
+		}
+		pool.Members = append(pool.Members, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &pool, nil
+}
+
+func (db *DB) PoolExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM reviewer_pools WHERE name = $1)`
+	err := db.pool.QueryRow(ctx, query, name).Scan(&exists)
+	return exists, err
+}
+
+func (db *DB) AddPoolMember(ctx context.Context, poolName, userID string) error {
+	query := `INSERT INTO reviewer_pool_members (pool_name, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := db.pool.Exec(ctx, query, poolName, userID)
+	return err
+}
+
+func (db *DB) RemovePoolMember(ctx context.Context, poolName, userID string) error {
+	query := `DELETE FROM reviewer_pool_members WHERE pool_name = $1 AND user_id = $2`
+	result, err := db.pool.Exec(ctx, query, poolName, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not in pool")
+	}
+
+	return nil
+}
+
+func (db *DB) GetActiveUsersByPool(ctx context.Context, poolName string, excludeUserID string) ([]models.User, error) {
+	query := `SELECT u.user_id, u.username, u.team_name, u.is_active, u.last_active_at, u.manager_id, u.utc_offset_minutes,
+                     u.last_review_completed_at, u.busy_from, u.busy_until, COALESCE(u.seniority, '')
+              FROM users u
+              JOIN reviewer_pool_members m ON m.user_id = u.user_id
+              WHERE m.pool_name = $1 AND u.is_active = true AND u.accepting_reviews AND u.user_id != $2
+              ORDER BY u.user_id`
+	rows, err := db.pool.Query(ctx, query, poolName, excludeUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanCandidate(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Audit events
+// RecordEvent appends an audit event
+func (db *DB) RecordEvent(ctx context.Context, event *models.AuditEvent) error {
+	var details []byte
+	if len(event.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(event.Details); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO audit_events (event_type, pull_request_id, user_id, actor_id, details)
+              VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5)`
+	_, err := db.pool.Exec(ctx, query, event.Type, event.PullRequestID, event.UserID, event.ActorID, details)
+	return err
+}
+
+// GetEvents returns the latest audit events, newest first, optionally only
+// those of one PR
+func (db *DB) GetEvents(ctx context.Context, prID string, limit int) ([]models.AuditEvent, error) {
+	query := `SELECT id, event_type, COALESCE(pull_request_id, ''), COALESCE(user_id, ''), actor_id, details, created_at
+              FROM audit_events
+              WHERE $1 = '' OR pull_request_id = $1
+              ORDER BY id DESC
+              LIMIT $2`
+	rows, err := db.pool.Query(ctx, query, prID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var details []byte
+		var createdAt time.Time
+		err := rows.Scan(&event.ID, &event.Type, &event.PullRequestID, &event.UserID, &event.ActorID, &details, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &event.Details); err != nil {
+				return nil, err
+			}
+		}
+		event.CreatedAt = models.NewTimestamp(createdAt)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// RecordDecision appends an assignment decision to the decisions log
+func (db *DB) RecordDecision(ctx context.Context, decision *models.AssignmentDecision) error {
+	query := `INSERT INTO assignment_decisions
+                  (pull_request_id, kind, strategy, candidate_pool_size, selected, replaced_user_id, fallback, actor_id)
+              VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8)`
+	_, err := db.pool.Exec(ctx, query, decision.PullRequestID, decision.Kind, decision.Strategy, decision.PoolSize,
+		decision.Selected, decision.ReplacedUserID, decision.Fallback, decision.ActorID)
+	return err
+}
+
+// GetDecisions returns the PR's assignment decisions, oldest first
+func (db *DB) GetDecisions(ctx context.Context, prID string) ([]models.AssignmentDecision, error) {
+	query := `SELECT id, pull_request_id, kind, strategy, candidate_pool_size, selected,
+                     COALESCE(replaced_user_id, ''), COALESCE(fallback, ''), actor_id, created_at
+              FROM assignment_decisions
+              WHERE pull_request_id = $1
+              ORDER BY id`
+	rows, err := db.pool.Query(ctx, query, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []models.AssignmentDecision{}
+	for rows.Next() {
+		var decision models.AssignmentDecision
+		var createdAt time.Time
+		err := rows.Scan(&decision.ID, &decision.PullRequestID, &decision.Kind, &decision.Strategy, &decision.PoolSize,
+			&decision.Selected, &decision.ReplacedUserID, &decision.Fallback, &decision.ActorID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		decision.CreatedAt = models.NewTimestamp(createdAt)
+		decisions = append(decisions, decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// GetReassignments returns the PR's reviewer replacements, oldest first
+func (db *DB) GetReassignments(ctx context.Context, prID string) ([]models.Reassignment, error) {
+	query := `SELECT COALESCE(details->>'old_user_id', ''), COALESCE(user_id, ''), actor_id, created_at
+              FROM audit_events
+              WHERE pull_request_id = $1 AND event_type = $2
+              ORDER BY id`
+	rows, err := db.pool.Query(ctx, query, prID, models.EventReviewerReassign)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reassignments := []models.Reassignment{}
+	for rows.Next() {
+		var reassignment models.Reassignment
+		var reassignedAt time.Time
+		err := rows.Scan(&reassignment.OldUserID, &reassignment.NewUserID, &reassignment.ActorID, &reassignedAt)
+		if err != nil {
+			return nil, err
+		}
+		reassignment.ReassignedAt = models.NewTimestamp(reassignedAt)
+		reassignments = append(reassignments, reassignment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reassignments, nil
+}
+
+// Health check
+func (db *DB) HealthCheck(ctx context.Context) error {
+	return db.pool.Ping(ctx)
+}
+
+// Reconcile finds rows whose references no longer resolve, which the foreign
+// keys rule out unless they were bypassed (restores with triggers disabled,
+// manual fixes). With fix, the missing teams are recreated as empty shells and
+// reviewer rows of missing users are removed, in one transaction with the report
+func (db *DB) Reconcile(ctx context.Context, fix bool) (*models.ReconcileReport, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	report := &models.ReconcileReport{
+		OrphanedUsers:     []models.OrphanedUser{},
+		OrphanedPRs:       []models.OrphanedPR{},
+		OrphanedReviewers: []models.OrphanedReviewer{},
+	}
+
+	err = collectPairs(ctx, tx,
+		`SELECT u.user_id, u.team_name FROM users u
+         WHERE NOT EXISTS (SELECT 1 FROM teams t WHERE t.name = u.team_name)
+         ORDER BY u.team_name, u.user_id`,
+		func(userID, teamName string) {
+			report.OrphanedUsers = append(report.OrphanedUsers, models.OrphanedUser{UserID: userID, TeamName: teamName})
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectPairs(ctx, tx,
+		`SELECT p.pull_request_id, p.author_id FROM pull_requests p
+         WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = p.author_id)
+         ORDER BY p.pull_request_id`,
+		func(prID, authorID string) {
+			report.OrphanedPRs = append(report.OrphanedPRs, models.OrphanedPR{PullRequestID: prID, AuthorID: authorID})
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectPairs(ctx, tx,
+		`SELECT r.pr_id, r.reviewer_id FROM pr_reviewers r
+         WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = r.reviewer_id)
+         ORDER BY r.pr_id, r.reviewer_id`,
+		func(prID, reviewerID string) {
+			report.OrphanedReviewers = append(report.OrphanedReviewers,
+				models.OrphanedReviewer{PullRequestID: prID, ReviewerID: reviewerID})
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	if !fix {
+		return report, nil
+	}
+
+	for _, user := range report.OrphanedUsers {
+		tag, err := tx.Exec(ctx, `INSERT INTO teams (name) VALUES ($1) ON CONFLICT DO NOTHING`, user.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() > 0 {
+			report.TeamsCreated = append(report.TeamsCreated, user.TeamName)
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE pr_reviewer_history h SET removed_at = now()
+         WHERE h.removed_at IS NULL AND NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = h.reviewer_id)`)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM pr_reviewers r WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = r.reviewer_id)`)
+	if err != nil {
+		return nil, err
+	}
+	report.ReviewersRemoved = tag.RowsAffected()
+	report.Fixed = true
+
+	return report, tx.Commit(ctx)
+}
+
+// collectPairs runs a query selecting two text columns and calls fn per row
+func collectPairs(ctx context.Context, tx pgx.Tx, query string, fn func(a, b string)) error {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a, b string
+		if err := rows.Scan(&a, &b); err != nil {
+			return err
+		}
+		fn(a, b)
+	}
+	return rows.Err()
+}
+
+// Migration and initialization
+func (db *DB) InitSchema(ctx context.Context) error {
+	// Check if tables already exist
+	var tablesExist bool
+	query := `SELECT EXISTS(
+        SELECT FROM information_schema.tables 
+        WHERE table_schema = 'public' AND table_name = 'teams'
+    )`
+	err := db.pool.QueryRow(ctx, query).Scan(&tablesExist)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version VARCHAR(255) PRIMARY KEY,
+        applied_at TIMESTAMP NOT NULL DEFAULT now()
+    )`)
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob("migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	applied, err := db.GetAppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	recorded := make(map[string]bool, len(applied))
+	for _, migration := range applied {
+		recorded[migration.Version] = true
+	}
+
+	for _, file := range files {
+		version := strings.TrimSuffix(filepath.Base(file), ".sql")
+		if recorded[version] {
+			continue
+		}
+
+		// A database created before migrations were recorded already has the
+		// base schema. The later migrations are idempotent, so they are
+		// applied to it again once and recorded from then on
+		if !tablesExist || version != "001_init" {
+			if err := db.applyMigration(ctx, file); err != nil {
+				return err
+			}
+		}
+
+		_, err = db.pool.Exec(ctx,
+			`INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT DO NOTHING`, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAppliedMigrations returns the recorded migrations ordered by version
+func (db *DB) GetAppliedMigrations(ctx context.Context) ([]models.SchemaMigration, error) {
+	rows, err := db.pool.Query(ctx, `SELECT version, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	migrations := []models.SchemaMigration{}
+	for rows.Next() {
+		var migration models.SchemaMigration
+		var appliedAt time.Time
+		if err := rows.Scan(&migration.Version, &appliedAt); err != nil {
+			return nil, err
+		}
+		migration.AppliedAt = models.NewTimestamp(appliedAt)
+		migrations = append(migrations, migration)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return migrations, nil
+}
+
+func (db *DB) applyMigration(ctx context.Context, file string) error {
+	// Execute SQL migration file
+	sqlContent, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read migration file: %w", err)
+	}
+
+	// Without arguments pgx uses the simple protocol, which runs the whole
+	// file at once, function bodies with semicolons included
+	if _, err := db.pool.Exec(ctx, string(sqlContent)); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", filepath.Base(file), err)
+	}
+
+	return nil
+}
+
+// SetMaxReviewers stores the reviewer cap enforced by the pr_reviewers trigger
+func (db *DB) SetMaxReviewers(ctx context.Context, max int) error {
+	_, err := db.pool.Exec(ctx,
+		`INSERT INTO settings (name, value) VALUES ('max_reviewers', $1)
+         ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value`, strconv.Itoa(max))
+	return err
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"review-service/internal/models"
+)
+
+// testDB connects to TEST_DATABASE_URL and recreates the schema from the
+// migrations, skipping the test without it. The database is wiped, so it
+// must be one used only by these tests
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	// InitSchema reads migrations/ relative to the working directory
+	t.Chdir("../..")
+
+	db, err := NewDB(url, Options{})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	ctx := context.Background()
+	if _, err := db.pool.Exec(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("reset schema: %v", err)
+	}
+	if err := db.InitSchema(ctx); err != nil {
+		t.Fatalf("InitSchema: %v", err)
+	}
+	return db
+}
+
+// seedTeam creates an active team with the given members
+func seedTeam(t *testing.T, db *DB, teamName string, userIDs ...string) {
+	t.Helper()
+	active := true
+	team := &models.Team{TeamName: teamName}
+	for _, userID := range userIDs {
+		team.Members = append(team.Members, models.TeamMember{UserID: userID, Username: userID, IsActive: &active})
+	}
+	if _, err := db.CreateTeam(context.Background(), team); err != nil {
+		t.Fatalf("CreateTeam %s: %v", teamName, err)
+	}
+}
+
+// seedPR creates an open PR created at the given time
+func seedPR(t *testing.T, db *DB, prID, authorID string, createdAt time.Time, reviewers ...string) {
+	t.Helper()
+	pr := &models.PullRequest{
+		PullRequestID:     prID,
+		PullRequestName:   prID,
+		AuthorID:          authorID,
+		Status:            models.PRStatusOpen,
+		CreatedAt:         models.NewTimestamp(createdAt),
+		AssignedReviewers: reviewers,
+	}
+	unavailable, err := db.CreatePR(context.Background(), pr)
+	if err != nil || len(unavailable) > 0 {
+		t.Fatalf("CreatePR %s: %v (unavailable %v)", prID, err, unavailable)
+	}
+}
+
+func TestSoftDeletedPRHidden(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedTeam(t, db, "backend", "author", "r1")
+	seedPR(t, db, "pr-1", "author", time.Now(), "r1")
+	seedPR(t, db, "pr-2", "author", time.Now(), "r1")
+
+	if _, err := db.SoftDeletePR(ctx, "pr-1"); err != nil {
+		t.Fatalf("SoftDeletePR: %v", err)
+	}
+
+	if _, err := db.GetPRByID(ctx, "pr-1"); err != ErrPRNotFound {
+		t.Errorf("GetPRByID after delete: error = %v, want ErrPRNotFound", err)
+	}
+	prs, err := db.GetPRsByReviewer(ctx, "r1", models.Page{})
+	if err != nil {
+		t.Fatalf("GetPRsByReviewer: %v", err)
+	}
+	if len(prs) != 1 || prs[0].PullRequestID != "pr-2" {
+		t.Errorf("reviewer's PRs = %+v, want only pr-2", prs)
+	}
+	if _, err := db.SoftDeletePR(ctx, "pr-1"); err != ErrPRNotFound {
+		t.Errorf("second SoftDeletePR: error = %v, want ErrPRNotFound", err)
+	}
+}
+
+func TestInitSchemaSkipsRecordedMigrations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	files, err := filepath.Glob("migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := db.GetAppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("GetAppliedMigrations: %v", err)
+	}
+	if len(applied) != len(files) {
+		t.Fatalf("recorded %d migrations, want %d", len(applied), len(files))
+	}
+
+	// A recorded migration is not applied again, so the dropped index stays dropped
+	if _, err := db.pool.Exec(ctx, `DROP INDEX idx_pr_created_id`); err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	if err := db.InitSchema(ctx); err != nil {
+		t.Fatalf("second InitSchema: %v", err)
+	}
+	var exists bool
+	err = db.pool.QueryRow(ctx, `SELECT to_regclass('idx_pr_created_id') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("migration 015 was re-applied on the second InitSchema")
+	}
+	again, err := db.GetAppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("GetAppliedMigrations: %v", err)
+	}
+	if len(again) != len(applied) {
+		t.Errorf("recorded %d migrations after restart, want %d", len(again), len(applied))
+	}
+}
+
+func TestMaxReviewersTrigger(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	if err := db.SetMaxReviewers(ctx, 2); err != nil {
+		t.Fatalf("SetMaxReviewers: %v", err)
+	}
+	seedTeam(t, db, "backend", "author", "r1", "r2", "r3")
+	seedPR(t, db, "pr-1", "author", time.Now(), "r1")
+
+	if err := db.AddReviewer(ctx, "pr-1", "r2", ""); err != nil {
+		t.Fatalf("AddReviewer r2: %v", err)
+	}
+	if err := db.AddReviewer(ctx, "pr-1", "r3", ""); !errors.Is(err, ErrTooManyReviewers) {
+		t.Errorf("AddReviewer over the cap: error = %v, want ErrTooManyReviewers", err)
+	}
+	// Re-adding a current reviewer does not count against the cap
+	if err := db.AddReviewer(ctx, "pr-1", "r1", ""); err != nil {
+		t.Errorf("AddReviewer r1 again: %v", err)
+	}
+	reviewers, err := db.GetPRReviewerIDs(ctx, "pr-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviewers) != 2 {
+		t.Errorf("reviewers = %v, want 2", reviewers)
+	}
+}
+
+func TestListPRsKeysetPages(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedTeam(t, db, "backend", "author")
+
+	// More than two batches, pairs sharing created_at so the ID breaks ties
+	total := 2*prListBatchSize + 7
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, created_at)
+		SELECT 'pr-' || lpad(i::text, 5, '0'), 'pr', 'author', 'OPEN', '2025-01-10'::timestamp + (i / 2) * interval '1 second'
+		FROM generate_series(0, $1 - 1) AS i`, total)
+	if err != nil {
+		t.Fatalf("seed PRs: %v", err)
+	}
+
+	var ids []string
+	err = db.ListPRs(ctx, "", "", func(pr models.PullRequest) error {
+		ids = append(ids, pr.PullRequestID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListPRs: %v", err)
+	}
+	if len(ids) != total {
+		t.Fatalf("listed %d PRs, want %d", len(ids), total)
+	}
+	for i, id := range ids {
+		if want := fmt.Sprintf("pr-%05d", i); id != want {
+			t.Fatalf("item %d = %s, want %s", i, id, want)
+		}
+	}
+}
+
+func TestGetPRsByReviewerCursorWhileAssigning(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedTeam(t, db, "backend", "author", "r1", "r2")
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	seedPR(t, db, "pr-old", "author", old, "r2")
+	for i := range 4 {
+		seedPR(t, db, fmt.Sprintf("pr-%d", i), "author", old.Add(time.Duration(i+1)*time.Hour), "r1")
+	}
+
+	seen := map[string]int{}
+	page := models.Page{Limit: 2}
+	for pages := 0; ; pages++ {
+		prs, err := db.GetPRsByReviewer(ctx, "r1", page)
+		if err != nil {
+			t.Fatalf("GetPRsByReviewer: %v", err)
+		}
+		for _, pr := range prs {
+			seen[pr.PullRequestID]++
+		}
+		if len(prs) < page.Limit {
+			break
+		}
+		page.After = models.NewPRCursor(prs[len(prs)-1])
+
+		// Between the first pages r1 gets a PR older than everything seen
+		// so far and a brand new one
+		if pages == 0 {
+			if err := db.AddReviewer(ctx, "pr-old", "r1", ""); err != nil {
+				t.Fatalf("AddReviewer: %v", err)
+			}
+			seedPR(t, db, "pr-new", "author", time.Now(), "r1")
+		}
+	}
+
+	want := []string{"pr-0", "pr-1", "pr-2", "pr-3", "pr-old", "pr-new"}
+	for _, prID := range want {
+		if seen[prID] != 1 {
+			t.Errorf("%s listed %d times, want once", prID, seen[prID])
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("listed %v, want %v", seen, want)
+	}
+}
+
+func TestConcurrentCreatePR(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	if err := db.SetMaxReviewers(ctx, 2); err != nil {
+		t.Fatalf("SetMaxReviewers: %v", err)
+	}
+	seedTeam(t, db, "backend", "author", "r1", "r2")
+
+	// Activity updates on the reviewers' rows run throughout; a create must
+	// wait for them instead of treating the reviewers as unavailable
+	stop := make(chan struct{})
+	touched := make(chan struct{})
+	go func() {
+		defer close(touched)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			db.TouchUser(ctx, "r1")
+			db.TouchUser(ctx, "r2")
+		}
+	}()
+
+	const creates = 30
+	errs := make(chan error, creates)
+	var wg sync.WaitGroup
+	for i := range creates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pr := &models.PullRequest{
+				PullRequestID:     fmt.Sprintf("pr-%d", i),
+				PullRequestName:   "concurrent",
+				AuthorID:          "author",
+				Status:            models.PRStatusOpen,
+				AssignedReviewers: []string{"r2", "r1"},
+			}
+			unavailable, err := db.CreatePR(ctx, pr)
+			if err == nil && len(unavailable) > 0 {
+				err = fmt.Errorf("%s: reviewers %v reported unavailable", pr.PullRequestID, unavailable)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-touched
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	var overCap int
+	err := db.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM (SELECT pr_id FROM pr_reviewers GROUP BY pr_id HAVING COUNT(*) <> 2) AS wrong`).Scan(&overCap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overCap != 0 {
+		t.Errorf("%d PRs don't have exactly 2 reviewers", overCap)
+	}
+}
+
+func TestPurgeDeletedPRs(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedTeam(t, db, "backend", "author", "r1")
+	for _, prID := range []string{"pr-old", "pr-recent", "pr-live"} {
+		seedPR(t, db, prID, "author", time.Now(), "r1")
+	}
+	for _, prID := range []string{"pr-old", "pr-recent"} {
+		if _, err := db.SoftDeletePR(ctx, prID); err != nil {
+			t.Fatalf("SoftDeletePR %s: %v", prID, err)
+		}
+	}
+	_, err := db.pool.Exec(ctx, `UPDATE pull_requests SET deleted_at = NOW() - interval '10 days' WHERE pull_request_id = 'pr-old'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := db.PurgeDeletedPRs(ctx, time.Now().Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedPRs: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d PRs, want 1", purged)
+	}
+
+	counts := map[string][2]int{}
+	for _, prID := range []string{"pr-old", "pr-recent", "pr-live"} {
+		var prs, history int
+		err := db.pool.QueryRow(ctx, `
+			SELECT (SELECT COUNT(*) FROM pull_requests WHERE pull_request_id = $1),
+			       (SELECT COUNT(*) FROM pr_reviewer_history WHERE pr_id = $1)`, prID).Scan(&prs, &history)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[prID] = [2]int{prs, history}
+	}
+	want := map[string][2]int{"pr-old": {0, 0}, "pr-recent": {1, 1}, "pr-live": {1, 1}}
+	for prID, c := range want {
+		if counts[prID] != c {
+			t.Errorf("%s: %d rows and %d history entries, want %d and %d", prID, counts[prID][0], counts[prID][1], c[0], c[1])
+		}
+	}
+}
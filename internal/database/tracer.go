@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// queryDurationBuckets are the upper bounds, in seconds, of the query
+// duration histogram
+var queryDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// maxQueryNameLength bounds the SQL prefix used as a query's name
+const maxQueryNameLength = 80
+
+// QueryTracer records the duration, affected rows and errors of every query
+// run through the pool, including queries inside transactions and batches.
+// Queries slower than the threshold are logged, every query feeds the
+// per-query duration histograms written by WritePrometheus
+type QueryTracer struct {
+	slowThreshold time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*queryStats
+}
+
+type queryStats struct {
+	buckets      []uint64
+	count        uint64
+	errors       uint64
+	rowsAffected int64
+	sum          float64
+}
+
+// NewQueryTracer returns a tracer logging queries slower than slowThreshold;
+// 0 disables the log
+func NewQueryTracer(slowThreshold time.Duration) *QueryTracer {
+	return &QueryTracer{slowThreshold: slowThreshold, stats: make(map[string]*queryStats)}
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	sql   string
+	start time.Time
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{sql: data.SQL, start: time.Now()})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	t.record(trace.sql, time.Since(trace.start), data.CommandTag.RowsAffected(), data.Err)
+}
+
+// Batch queries are sent together, so each one is timed from the end of the
+// previous one (or the batch start)
+func (t *QueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{start: time.Now()})
+}
+
+func (t *QueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	t.record(data.SQL, now.Sub(trace.start), data.CommandTag.RowsAffected(), data.Err)
+	trace.start = now
+}
+
+func (t *QueryTracer) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
+
+// record adds one query execution to the statistics
+func (t *QueryTracer) record(sql string, duration time.Duration, rows int64, err error) {
+	name := queryName(sql)
+	if t.slowThreshold > 0 && duration >= t.slowThreshold {
+		log.Printf("Slow query (%s, %d rows, err=%v): %s", duration, rows, err, name)
+	}
+
+	seconds := duration.Seconds()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.stats[name]
+	if !ok {
+		stats = &queryStats{buckets: make([]uint64, len(queryDurationBuckets))}
+		t.stats[name] = stats
+	}
+	stats.count++
+	stats.sum += seconds
+	stats.rowsAffected += rows
+	if err != nil {
+		stats.errors++
+	}
+	for i, bound := range queryDurationBuckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+		}
+	}
+}
+
+// queryName collapses the whitespace of the SQL and cuts it to a readable
+// prefix. Queries are static strings, so the names stay a small set
+func queryName(sql string) string {
+	name := strings.Join(strings.Fields(sql), " ")
+	if len(name) > maxQueryNameLength {
+		name = name[:maxQueryNameLength]
+	}
+	return name
+}
+
+// WritePrometheus writes the query statistics in the Prometheus text format
+func (t *QueryTracer) WritePrometheus(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.stats))
+	for name := range t.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# HELP db_query_duration_seconds Duration of database queries.\n")
+	b.WriteString("# TYPE db_query_duration_seconds histogram\n")
+	for _, name := range names {
+		stats := t.stats[name]
+		label := escapeLabel(name)
+		for i, bound := range queryDurationBuckets {
+			fmt.Fprintf(&b, "db_query_duration_seconds_bucket{query=\"%s\",le=\"%g\"} %d\n", label, bound, stats.buckets[i])
+		}
+		fmt.Fprintf(&b, "db_query_duration_seconds_bucket{query=\"%s\",le=\"+Inf\"} %d\n", label, stats.count)
+		fmt.Fprintf(&b, "db_query_duration_seconds_sum{query=\"%s\"} %g\n", label, stats.sum)
+		fmt.Fprintf(&b, "db_query_duration_seconds_count{query=\"%s\"} %d\n", label, stats.count)
+	}
+
+	b.WriteString("# HELP db_query_errors_total Database queries that returned an error.\n")
+	b.WriteString("# TYPE db_query_errors_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "db_query_errors_total{query=\"%s\"} %d\n", escapeLabel(name), t.stats[name].errors)
+	}
+
+	b.WriteString("# HELP db_query_rows_affected_total Rows affected by database queries.\n")
+	b.WriteString("# TYPE db_query_rows_affected_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "db_query_rows_affected_total{query=\"%s\"} %d\n", escapeLabel(name), t.stats[name].rowsAffected)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
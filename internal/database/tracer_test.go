@@ -0,0 +1,71 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestQueryTracer(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tracer := NewQueryTracer(time.Millisecond)
+	ctx := context.Background()
+
+	const update = `UPDATE users
+                    SET is_active = false WHERE team_name = $1`
+	traced := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: update})
+	time.Sleep(5 * time.Millisecond)
+	tracer.TraceQueryEnd(traced, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 3")})
+
+	traced = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: update})
+	tracer.TraceQueryEnd(traced, nil, pgx.TraceQueryEndData{Err: errors.New("deadlock detected")})
+
+	// Batch queries are recorded one by one
+	const insert = `INSERT INTO pr_reviewers (pr_id, reviewer_id) VALUES ($1, $2)`
+	traced = tracer.TraceBatchStart(ctx, nil, pgx.TraceBatchStartData{})
+	for range 2 {
+		tracer.TraceBatchQuery(traced, nil, pgx.TraceBatchQueryData{SQL: insert, CommandTag: pgconn.NewCommandTag("INSERT 0 1")})
+	}
+	tracer.TraceBatchEnd(traced, nil, pgx.TraceBatchEndData{})
+
+	if !strings.Contains(logged.String(), "Slow query") || !strings.Contains(logged.String(), "UPDATE users SET is_active = false WHERE team_name = $1") {
+		t.Errorf("slow log = %q, want the slow UPDATE", logged.String())
+	}
+
+	var metrics strings.Builder
+	if err := tracer.WritePrometheus(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`db_query_duration_seconds_count{query="UPDATE users SET is_active = false WHERE team_name = $1"} 2`,
+		`db_query_duration_seconds_bucket{query="UPDATE users SET is_active = false WHERE team_name = $1",le="+Inf"} 2`,
+		`db_query_errors_total{query="UPDATE users SET is_active = false WHERE team_name = $1"} 1`,
+		`db_query_rows_affected_total{query="UPDATE users SET is_active = false WHERE team_name = $1"} 3`,
+		`db_query_duration_seconds_count{query="INSERT INTO pr_reviewers (pr_id, reviewer_id) VALUES ($1, $2)"} 2`,
+		`db_query_errors_total{query="INSERT INTO pr_reviewers (pr_id, reviewer_id) VALUES ($1, $2)"} 0`,
+		`db_query_rows_affected_total{query="INSERT INTO pr_reviewers (pr_id, reviewer_id) VALUES ($1, $2)"} 2`,
+	} {
+		if !strings.Contains(metrics.String(), want+"\n") {
+			t.Errorf("metrics are missing %s:\n%s", want, metrics.String())
+		}
+	}
+
+	// The slow execution alone took 5ms
+	_, sum, _ := strings.Cut(metrics.String(), `db_query_duration_seconds_sum{query="UPDATE users SET is_active = false WHERE team_name = $1"} `)
+	sum, _, _ = strings.Cut(sum, "\n")
+	if seconds, err := strconv.ParseFloat(sum, 64); err != nil || seconds < 0.005 {
+		t.Errorf("duration sum = %q, want at least 0.005", sum)
+	}
+}
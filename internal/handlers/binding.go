@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"review-service/internal/models"
+	"review-service/internal/openapi"
+)
+
+// OpenAPIValidationMiddleware rejects requests not matching openapi.yaml with
+// INVALID_INPUT listing the violations, before they reach the handlers. A nil
+// validator (VALIDATE_OPENAPI off) lets every request through
+func (h *Handler) OpenAPIValidationMiddleware(validator *openapi.Validator) gin.HandlerFunc {
+	if validator == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		req := openapi.Request{
+			Method:     c.Request.Method,
+			Route:      c.FullPath(),
+			PathParams: make(map[string]string, len(c.Params)),
+			Query:      c.Request.URL.Query(),
+			Header:     c.Request.Header,
+		}
+		for _, param := range c.Params {
+			req.PathParams[param.Key] = param.Value
+		}
+
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
+				c.Abort()
+				return
+			}
+			// Handlers bind the body again
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+			if len(bytes.TrimSpace(body)) > 0 {
+				decoder := json.NewDecoder(bytes.NewReader(body))
+				decoder.UseNumber()
+				if err := decoder.Decode(&req.Body); err != nil {
+					writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "invalid JSON body: "+err.Error()))
+					c.Abort()
+					return
+				}
+				// Checked as the handler will see it
+				if h.cfg.LenientIDs {
+					req.Body = stringifyIDs(req.Body, false)
+				}
+				req.HasBody = true
+			}
+		}
+
+		violations := validator.Validate(req)
+		if len(violations) == 0 {
+			c.Next()
+			return
+		}
+
+		fields := make([]models.FieldError, 0, len(violations))
+		names := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			fields = append(fields, models.FieldError{Field: violation.Field, Code: violation.Code, Message: violation.Message})
+			names = append(names, violation.Field)
+		}
+		errResp := createError("INVALID_INPUT", "request does not match the API schema: "+strings.Join(names, ", "))
+		errResp.Error.Fields = fields
+		writeError(c, http.StatusBadRequest, errResp)
+		c.Abort()
+	}
+}
+
+// bindJSON binds the request body like ShouldBindJSON. With LENIENT_IDS numeric
+// IDs are accepted too and turned into strings, for clients sending user_id: 42
+func (h *Handler) bindJSON(c *gin.Context, obj any) error {
+	if !h.cfg.LenientIDs {
+		return c.ShouldBindJSON(obj)
+	}
+	return c.ShouldBindWith(obj, lenientIDBinding{})
+}
+
+// lenientIDBinding is the JSON binding with numeric IDs normalized to strings
+// before decoding, so the request structs and their validation stay unchanged
+type lenientIDBinding struct{}
+
+func (lenientIDBinding) Name() string {
+	return "json"
+}
+
+func (b lenientIDBinding) Bind(req *http.Request, obj any) error {
+	if req == nil || req.Body == nil {
+		return errors.New("invalid request")
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	return b.BindBody(body, obj)
+}
+
+func (lenientIDBinding) BindBody(body []byte, obj any) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	normalized, err := json.Marshal(stringifyIDs(raw, false))
+	if err != nil {
+		return err
+	}
+	return binding.JSON.BindBody(normalized, obj)
+}
+
+// stringifyIDs replaces numbers under ID keys with their literal text, at
+// any depth so team members and ID lists are covered too
+func stringifyIDs(value any, isID bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = stringifyIDs(item, isIDKey(key))
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = stringifyIDs(item, isID)
+		}
+	case json.Number:
+		if isID {
+			return v.String()
+		}
+	}
+	return value
+}
+
+// isIDKey reports whether a request field holds an ID or a list of them
+func isIDKey(key string) bool {
+	return strings.HasSuffix(key, "_id") || strings.HasSuffix(key, "_ids") ||
+		key == "team_name" || key == "pool_name"
+}
@@ -1,173 +1,1518 @@
-package handlers
-
-import (
-	"net/http"
-	"review-service/internal/models"
-	"review-service/internal/service"
-
-	"github.com/gin-gonic/gin"
-)
-
-type Handler struct {
-	service *service.Service
-}
-
-func NewHandler(service *service.Service) *Handler {
-	return &Handler{service: service}
-}
-
-func (h *Handler) CreateTeam(c *gin.Context) {
-	var req models.CreateTeamRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
-		return
-	}
-
-	team, err := h.service.CreateTeam(c.Request.Context(), req)
-	if err != nil {
-		switch err {
-		case service.ErrTeamExists:
-			c.JSON(http.StatusBadRequest, createError("TEAM_EXISTS", "team_name already exists"))
-		default:
-			c.JSON(http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
-		}
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{"team": team})
-}
-
-func (h *Handler) GetTeam(c *gin.Context) {
-	teamName := c.Query("team_name")
-	if teamName == "" {
-		c.JSON(http.StatusBadRequest, createError("MISSING_PARAM", "team_name is required"))
-		return
-	}
-
-	team, err := h.service.GetTeam(c.Request.Context(), teamName)
-	if err != nil {
-		c.JSON(http.StatusNotFound, createError("NOT_FOUND", "team not found"))
-		return
-	}
-
-	c.JSON(http.StatusOK, team)
-}
-
-func (h *Handler) SetUserActive(c *gin.Context) {
-	var req models.SetUserActiveRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
-		return
-	}
-
-	user, err := h.service.SetUserActive(c.Request.Context(), req)
-	if err != nil {
-		c.JSON(http.StatusNotFound, createError("NOT_FOUND", "user not found"))
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"user": user})
-}
-
-func (h *Handler) CreatePR(c *gin.Context) {
-	var req models.CreatePRRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
-		return
-	}
-
-	pr, err := h.service.CreatePR(c.Request.Context(), req)
-	if err != nil {
-		switch err {
-		case service.ErrPRExists:
-			c.JSON(http.StatusConflict, createError("PR_EXISTS", "PR id already exists"))
-		case service.ErrUserNotFound:
-			c.JSON(http.StatusNotFound, createError("NOT_FOUND", "author/team not found"))
-		default:
-			c.JSON(http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
-		}
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{"pr": pr})
-}
-
-func (h *Handler) MergePR(c *gin.Context) {
-	var req models.MergePRRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
-		return
-	}
-
-	pr, err := h.service.MergePR(c.Request.Context(), req.PullRequestID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"pr": pr})
-}
-
-func (h *Handler) ReassignReviewer(c *gin.Context) {
-	var req models.ReassignReviewerRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
-		return
-	}
-
-	pr, newReviewerID, err := h.service.ReassignReviewer(c.Request.Context(), req)
-	if err != nil {
-		switch err {
-		case service.ErrPRNotFound:
-			c.JSON(http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
-		case service.ErrPRMerged:
-			c.JSON(http.StatusConflict, createError("PR_MERGED", "cannot reassign on merged PR"))
-		case service.ErrReviewerNotAssigned:
-			c.JSON(http.StatusConflict, createError("NOT_ASSIGNED", "reviewer is not assigned to this PR"))
-		case service.ErrNoCandidate:
-			c.JSON(http.StatusConflict, createError("NO_CANDIDATE", "no active replacement candidate in team"))
-		case service.ErrUserNotFound:
-			c.JSON(http.StatusNotFound, createError("NOT_FOUND", "user not found"))
-		default:
-			c.JSON(http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
-		}
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"pr":          pr,
-		"replaced_by": newReviewerID,
-	})
-}
-
-func (h *Handler) GetUserPRs(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, createError("MISSING_PARAM", "user_id is required"))
-		return
-	}
-
-	response, err := h.service.GetUserPRs(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
-		return
-	}
-
-	c.JSON(http.StatusOK, response)
-}
-
-func (h *Handler) HealthCheck(c *gin.Context) {
-	err := h.service.CheckHealth(c.Request.Context())
-	if err == nil {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-		return
-	}
-	c.JSON(http.StatusServiceUnavailable, createError("INTERNAL_ERROR", err.Error()))
-}
-
-func createError(code, message string) models.ErrorResponse {
-	var errResp models.ErrorResponse
-	errResp.Error.Code = code
-	errResp.Error.Message = message
-	return errResp
-}
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"review-service/internal/config"
+	"review-service/internal/models"
+	"review-service/internal/service"
+	"review-service/internal/version"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	service *service.Service
+	cfg     *config.Config
+}
+
+func NewHandler(service *service.Service, cfg *config.Config) *Handler {
+	return &Handler{service: service, cfg: cfg}
+}
+
+func (h *Handler) CreateTeam(c *gin.Context) {
+	var req models.CreateTeamRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeTeam(c, req.TeamName) {
+		return
+	}
+
+	team, summary, err := h.service.CreateTeam(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrTeamExists:
+			writeError(c, http.StatusBadRequest, createError("TEAM_EXISTS", "team_name already exists"))
+		case service.ErrUserInAnotherTeam:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("USER_IN_ANOTHER_TEAM",
+				"a member already belongs to another team, pass move=true to move them"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"team": team, "summary": summary})
+}
+
+func (h *Handler) CreateTeams(c *gin.Context) {
+	var req models.CreateTeamsBatchRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	for _, team := range req.Teams {
+		if !h.authorizeTeam(c, team.TeamName) {
+			return
+		}
+	}
+
+	results, err := h.service.CreateTeams(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrBatchTooLarge:
+			writeError(c, h.semanticStatus(http.StatusBadRequest), createError("INVALID_INPUT", "too many teams in one batch"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (h *Handler) GetTeam(c *gin.Context) {
+	teamName := c.Query("team_name")
+	if teamName == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "team_name is required"))
+		return
+	}
+
+	team, err := h.service.GetTeam(c.Request.Context(), teamName)
+	if err != nil {
+		errResp := createError("NOT_FOUND", "team not found")
+		errResp.Error.Suggestions, err = h.service.SuggestTeams(c.Request.Context(), teamName)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+			return
+		}
+		writeError(c, http.StatusNotFound, errResp)
+		return
+	}
+
+	h.writeProjected(c, http.StatusOK, team)
+}
+
+func (h *Handler) GetTeamReviewerLoad(c *gin.Context) {
+	teamName := c.Query("team_name")
+	if teamName == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "team_name is required"))
+		return
+	}
+
+	load, err := h.service.GetTeamReviewerLoad(c.Request.Context(), teamName)
+	if err != nil {
+		switch err {
+		case service.ErrTeamNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "team not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, load, "members")
+}
+
+func (h *Handler) GetReviewerDistribution(c *gin.Context) {
+	teamName := c.Query("team_name")
+	if teamName == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "team_name is required"))
+		return
+	}
+
+	distribution, err := h.service.GetReviewerDistribution(c.Request.Context(), teamName, c.Query("status"))
+	if err != nil {
+		switch err {
+		case service.ErrInvalidStatus:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "status must be OPEN, MERGED or DRAFT"))
+		case service.ErrTeamNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "team not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, distribution)
+}
+
+func (h *Handler) GetLabels(c *gin.Context) {
+	labels, err := h.service.GetLabels(c.Request.Context(), c.Query("team_name"))
+	if err != nil {
+		switch err {
+		case service.ErrTeamNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "team not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, labels, "labels")
+}
+
+func (h *Handler) GetResponseTimes(c *gin.Context) {
+	times, err := h.service.GetResponseTimes(c.Request.Context(), c.Query("team_name"))
+	if err != nil {
+		switch err {
+		case service.ErrTeamNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "team not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, times, "reviewers")
+}
+
+// maxThroughputDays caps the window of /stats/mergeThroughput
+const maxThroughputDays = 366
+
+func (h *Handler) GetMergeThroughput(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", models.BucketDay)
+	windowText := c.DefaultQuery("window", "30d")
+
+	days, err := strconv.Atoi(strings.TrimSuffix(windowText, "d"))
+	if err != nil || !strings.HasSuffix(windowText, "d") || days < 1 || days > maxThroughputDays {
+		writeError(c, http.StatusBadRequest,
+			createError("INVALID_INPUT", fmt.Sprintf("window must be between 1d and %dd", maxThroughputDays)))
+		return
+	}
+
+	throughput, err := h.service.GetMergeThroughput(c.Request.Context(), bucket, time.Duration(days)*24*time.Hour, windowText)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidBucket:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "bucket must be day or week"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, throughput)
+}
+
+func (h *Handler) GetTeamCapacity(c *gin.Context) {
+	capacity, err := h.service.GetTeamCapacity(c.Request.Context())
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, capacity, "teams")
+}
+
+func (h *Handler) GetTeamUnassignedMembers(c *gin.Context) {
+	teamName := c.Query("team_name")
+	if teamName == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "team_name is required"))
+		return
+	}
+
+	unassigned, err := h.service.GetTeamUnassignedMembers(c.Request.Context(), teamName)
+	if err != nil {
+		switch err {
+		case service.ErrTeamNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "team not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, unassigned, "members")
+}
+
+func (h *Handler) SetUserActive(c *gin.Context) {
+	var req models.SetUserActiveRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeUser(c, req.UserID) {
+		return
+	}
+
+	user, err := h.service.SetUserActive(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) SetMentor(c *gin.Context) {
+	var req models.SetMentorRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeUser(c, req.UserID) {
+		return
+	}
+
+	mentor, err := h.service.SetMentor(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user or mentor not found"))
+		case service.ErrAuthorReview:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("AUTHOR_REVIEW", "user cannot be their own mentor"))
+		case service.ErrMentorInactive:
+			writeError(c, h.semanticStatus(http.StatusBadRequest), createError("INVALID_INPUT", "mentor is not active"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, mentor)
+}
+
+func (h *Handler) SetAcceptingReviews(c *gin.Context) {
+	var req models.SetAcceptingReviewsRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeUser(c, req.UserID) {
+		return
+	}
+
+	user, err := h.service.SetAcceptingReviews(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) SetCalendarAvailability(c *gin.Context) {
+	var req models.SetCalendarAvailabilityRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeUser(c, req.UserID) {
+		return
+	}
+
+	user, err := h.service.SetCalendarAvailability(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidBusyWindow:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "busy window must have a start and end after it"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) SetNotificationPrefs(c *gin.Context) {
+	var req models.SetNotificationPrefsRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeUser(c, req.UserID) {
+		return
+	}
+
+	user, err := h.service.SetNotificationPrefs(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) CreatePR(c *gin.Context) {
+	var req models.CreatePRRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeUser(c, req.AuthorID) {
+		return
+	}
+	req.DryRun = c.GetHeader("X-Dry-Run") == "true"
+	req.Explain = c.Query("explain") == "true"
+
+	pr, err := h.service.CreatePR(c.Request.Context(), req)
+	if err != nil {
+		if h.writeNoCandidate(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrPRExists:
+			if c.Query("return_existing") == "true" {
+				h.writeExistingPR(c, req.PullRequestID)
+				return
+			}
+			writeError(c, http.StatusConflict, createError("PR_EXISTS", "PR id already exists"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "author/team not found"))
+		case service.ErrTeamNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "author's team not found"))
+		case service.ErrPoolNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "reviewer pool not found"))
+		case service.ErrAuthorInactive:
+			writeError(c, http.StatusForbidden, createError("AUTHOR_INACTIVE", "inactive authors can't create PRs"))
+		case service.ErrInvalidStatus:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "status must be OPEN, MERGED or DRAFT"))
+		case service.ErrPRNameTooLong:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", fmt.Sprintf("pull_request_name is longer than %d characters", h.cfg.MaxPRNameLength)))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	if c.Query("include_reasons") != "true" {
+		pr.ReviewerReasons = nil
+	}
+	if !h.completePR(c, pr) {
+		return
+	}
+
+	if req.DryRun {
+		c.Header("X-Dry-Run", "true")
+		c.JSON(http.StatusOK, gin.H{"pr": pr})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"pr": pr})
+}
+
+// writeExistingPR answers a create with return_existing=true whose ID is
+// taken with the PR already stored under it. A soft-deleted PR can't be
+// returned, so its ID still gets the 409
+func (h *Handler) writeExistingPR(c *gin.Context, prID string) {
+	pr, err := h.service.GetPR(c.Request.Context(), prID, false)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusConflict, createError("PR_EXISTS", "PR id already exists"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, pr) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pr": pr})
+}
+
+func (h *Handler) MergePR(c *gin.Context) {
+	var req models.MergePRRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	pr, alreadyMerged, err := h.service.MergePR(c.Request.Context(), req.PullRequestID)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		case service.ErrPRDraft:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_DRAFT", "draft PRs can't be merged, mark the PR ready first"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, pr) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pr": pr, "already_merged": alreadyMerged})
+}
+
+func (h *Handler) MarkReady(c *gin.Context) {
+	var req models.MarkReadyRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	pr, err := h.service.MarkReady(c.Request.Context(), req.PullRequestID)
+	if err != nil {
+		if h.writeNoCandidate(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		case service.ErrPRMerged:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_MERGED", "cannot mark merged PR ready"))
+		case service.ErrTooManyReviewers:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("TOO_MANY_REVIEWERS", "PR already has the maximum number of reviewers"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "author/team not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, pr) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pr": pr})
+}
+
+func (h *Handler) AssignReviewer(c *gin.Context) {
+	var req models.AssignReviewerRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	response, err := h.service.AssignReviewer(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		case service.ErrPRMerged:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_MERGED", "cannot assign reviewers on merged PR"))
+		case service.ErrAuthorReview:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("AUTHOR_REVIEW", "author cannot review their own PR"))
+		case service.ErrAlreadyAssigned:
+			writeError(c, http.StatusConflict, createError("ALREADY_ASSIGNED", "reviewer is already assigned to this PR"))
+		case service.ErrTooManyReviewers:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("TOO_MANY_REVIEWERS", "PR already has the maximum number of reviewers"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetPRReviewers(c *gin.Context) {
+	prID := c.Query("pull_request_id")
+	if prID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "pull_request_id is required"))
+		return
+	}
+
+	response, ok := h.prReviewers(c, prID)
+	if !ok {
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "reviewers")
+}
+
+// GetPRReviewersResource serves the reviewers as a sub-resource of the PR,
+// GET /pullRequest/{id}/reviewers. The response carries an ETag, so clients
+// polling for reviewer changes get a bodyless 304 while nothing changed
+func (h *Handler) GetPRReviewersResource(c *gin.Context) {
+	response, ok := h.prReviewers(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	// The projection is part of the representation, so it's hashed too
+	body, err := json.Marshal(response)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+	sum := sha256.Sum256(append(body, "\x00"+c.Query("fields")...))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "reviewers")
+}
+
+// prReviewers loads the PR's reviewers, writing the error response on failure
+func (h *Handler) prReviewers(c *gin.Context, prID string) (*models.PRReviewersResponse, bool) {
+	response, err := h.service.GetPRReviewers(c.Request.Context(), prID)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return nil, false
+	}
+	return response, true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match too, as If-None-Match uses weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) GetPR(c *gin.Context) {
+	prID := c.Query("pull_request_id")
+	if prID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "pull_request_id is required"))
+		return
+	}
+
+	pr, err := h.service.GetPR(c.Request.Context(), prID, c.Query("include_history") == "true")
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, pr) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pr": pr})
+}
+
+func (h *Handler) DeletePR(c *gin.Context) {
+	var req models.DeletePRRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	pr, err := h.service.DeletePR(c.Request.Context(), req.PullRequestID)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pr": pr})
+}
+
+func (h *Handler) ReassignReviewer(c *gin.Context) {
+	var req models.ReassignReviewerRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	result, err := h.service.ReassignReviewer(c.Request.Context(), req)
+	if err != nil {
+		if h.writeNoCandidate(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		case service.ErrPRMerged:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_MERGED", "cannot reassign on merged PR"))
+		case service.ErrReviewerNotAssigned:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("NOT_ASSIGNED", "reviewer is not assigned to this PR"))
+		case service.ErrTooManyReviewers:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("TOO_MANY_REVIEWERS", "PR already has the maximum number of reviewers"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, result.PR) {
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) UnassignReviewer(c *gin.Context) {
+	var req models.UnassignReviewerRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	pr, refilledBy, err := h.service.UnassignReviewer(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		case service.ErrPRMerged:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_MERGED", "cannot unassign on merged PR"))
+		case service.ErrReviewerNotAssigned:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("NOT_ASSIGNED", "reviewer is not assigned to this PR"))
+		case service.ErrTooManyReviewers:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("TOO_MANY_REVIEWERS", "PR already has the maximum number of reviewers"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, pr) {
+		return
+	}
+
+	response := gin.H{"pr": pr}
+	if refilledBy != "" {
+		response["refilled_by"] = refilledBy
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// completePR fills in the computed fields of a single PR response: the
+// review policy result always and pr.ReviewerTeams when the client passed
+// include_reviewer_teams=true. On failure it writes the error and returns false
+func (h *Handler) completePR(c *gin.Context, pr *models.PullRequest) bool {
+	if err := h.service.EvaluatePolicies(c.Request.Context(), pr); err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return false
+	}
+	if c.Query("include_reviewer_teams") != "true" {
+		return true
+	}
+
+	if err := h.service.AddReviewerTeams(c.Request.Context(), pr); err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return false
+	}
+	return true
+}
+
+func (h *Handler) ApprovePR(c *gin.Context) {
+	var req models.ApprovePRRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizePR(c, req.PullRequestID) {
+		return
+	}
+
+	result, err := h.service.ApprovePR(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		case service.ErrPRMerged:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_MERGED", "cannot approve merged PR"))
+		case service.ErrPRDraft:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("PR_DRAFT", "draft PRs can't be approved, mark the PR ready first"))
+		case service.ErrReviewerNotAssigned:
+			writeError(c, h.semanticStatus(http.StatusConflict), createError("NOT_ASSIGNED", "reviewer is not assigned to this PR"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	if !h.completePR(c, result.PR) {
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) GetApprovalRate(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "user_id is required"))
+		return
+	}
+
+	windowParam := c.DefaultQuery("window", "90d")
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
+		return
+	}
+
+	rate, err := h.service.GetApprovalRate(c.Request.Context(), userID, window)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+	rate.Window = windowParam
+
+	h.writeProjected(c, http.StatusOK, rate)
+}
+
+func (h *Handler) GetUserPRs(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "user_id is required"))
+		return
+	}
+
+	page, err := parsePage(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
+		return
+	}
+
+	response, err := h.service.GetUserPRs(c.Request.Context(), userID, page)
+	if err != nil {
+		switch err {
+		case service.ErrOffsetTooLarge:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT",
+				fmt.Sprintf("offset must be at most %d, page with cursor=<next_cursor> instead", h.cfg.MaxPageOffset)))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "pull_requests")
+}
+
+func (h *Handler) GetPendingReviews(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "user_id is required"))
+		return
+	}
+
+	page, err := parsePage(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
+		return
+	}
+
+	response, err := h.service.GetPendingReviews(c.Request.Context(), userID, page)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		case service.ErrOffsetTooLarge:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT",
+				fmt.Sprintf("offset must be at most %d, page with cursor=<next_cursor> instead", h.cfg.MaxPageOffset)))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "pull_requests")
+}
+
+func (h *Handler) GetPRsByReviewers(c *gin.Context) {
+	// Duplicates are dropped, mode=all counts distinct users
+	var userIDs []string
+	for _, userID := range strings.Split(c.Query("user_ids"), ",") {
+		if userID = strings.TrimSpace(userID); userID != "" && !slices.Contains(userIDs, userID) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "user_ids is required"))
+		return
+	}
+
+	mode := c.DefaultQuery("mode", models.ReviewersMatchAny)
+
+	page, err := parsePage(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
+		return
+	}
+
+	response, err := h.service.GetPRsByReviewers(c.Request.Context(), userIDs, mode, page)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidMode:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "mode must be all or any"))
+		case service.ErrInvalidUserList:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT",
+				fmt.Sprintf("user_ids must list at most %d users", service.MaxReviewersFilter)))
+		case service.ErrOffsetTooLarge:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT",
+				fmt.Sprintf("offset must be at most %d, page with cursor=<next_cursor> instead", h.cfg.MaxPageOffset)))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "pull_requests")
+}
+
+func (h *Handler) ListPRs(c *gin.Context) {
+	status := c.Query("status")
+	authorID := c.Query("author_id")
+
+	countOnly, ok := reviewersCountMode(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("stream") == "true" {
+		h.streamPRs(c, status, authorID, countOnly)
+		return
+	}
+
+	response := models.PRListResponse{PullRequests: []models.PullRequest{}}
+	err := h.service.ListPRs(c.Request.Context(), status, authorID, func(pr models.PullRequest) error {
+		response.PullRequests = append(response.PullRequests, pr)
+		return nil
+	})
+	if err != nil {
+		writeListPRsError(c, err)
+		return
+	}
+
+	if countOnly {
+		counted := make([]models.PullRequestReviewerCount, len(response.PullRequests))
+		for i, pr := range response.PullRequests {
+			counted[i] = models.NewPullRequestReviewerCount(pr)
+		}
+		h.writeProjectedList(c, http.StatusOK, gin.H{"pull_requests": counted}, "pull_requests")
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "pull_requests")
+}
+
+// reviewersCountMode reads reviewers_mode, reporting whether only reviewer
+// counts are wanted. An unknown mode writes 400 and returns ok=false
+func reviewersCountMode(c *gin.Context) (countOnly, ok bool) {
+	switch c.DefaultQuery("reviewers_mode", models.ReviewersModeFull) {
+	case models.ReviewersModeFull:
+		return false, true
+	case models.ReviewersModeCount:
+		return true, true
+	}
+	writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "reviewers_mode must be full or count"))
+	return false, false
+}
+
+// streamPRs writes the listing item by item as the DB pages through it.
+// A failure after the first item can't change the status any more, the
+// response is cut short instead so the client sees invalid JSON
+func (h *Handler) streamPRs(c *gin.Context, status, authorID string, countOnly bool) {
+	const flushEvery = 100
+
+	written := 0
+	err := h.service.ListPRs(c.Request.Context(), status, authorID, func(pr models.PullRequest) error {
+		var item any = pr
+		if countOnly {
+			item = models.NewPullRequestReviewerCount(pr)
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+
+		if written == 0 {
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			c.Status(http.StatusOK)
+			_, err = c.Writer.WriteString(`{"pull_requests":[`)
+		} else {
+			_, err = c.Writer.WriteString(",")
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := c.Writer.Write(data); err != nil {
+			return err
+		}
+
+		written++
+		if written%flushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	if err != nil {
+		if written == 0 {
+			writeListPRsError(c, err)
+			return
+		}
+		log.Println("PR listing stream aborted:", err)
+		c.Abort()
+		return
+	}
+
+	if written == 0 {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString(`{"pull_requests":[`)
+	}
+	c.Writer.WriteString("]}")
+}
+
+func writeListPRsError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrInvalidStatus:
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "status must be OPEN, MERGED or DRAFT"))
+	default:
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+	}
+}
+
+func (h *Handler) GetReassignments(c *gin.Context) {
+	prID := c.Query("pull_request_id")
+	if prID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "pull_request_id is required"))
+		return
+	}
+
+	response, err := h.service.GetReassignments(c.Request.Context(), prID)
+	if err != nil {
+		switch err {
+		case service.ErrPRNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "PR not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "reassignments")
+}
+
+func (h *Handler) GetCoverageGaps(c *gin.Context) {
+	page, err := parsePage(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", err.Error()))
+		return
+	}
+
+	response, err := h.service.GetCoverageGaps(c.Request.Context(), page)
+	if err != nil {
+		switch err {
+		case service.ErrOffsetTooLarge:
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT",
+				fmt.Sprintf("offset must be at most %d, page with cursor=<next_cursor> instead", h.cfg.MaxPageOffset)))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "pull_requests")
+}
+
+func (h *Handler) GetSoleReviewerPRs(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "user_id is required"))
+		return
+	}
+
+	response, err := h.service.GetSoleReviewerPRs(c.Request.Context(), userID)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "pull_requests")
+}
+
+// Pools pick reviewers for PRs of any team, so only unscoped API keys may
+// change them
+func (h *Handler) CreatePool(c *gin.Context) {
+	var req models.CreatePoolRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	pool, err := h.service.CreatePool(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrPoolExists:
+			writeError(c, http.StatusBadRequest, createError("POOL_EXISTS", "pool_name already exists"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"pool": pool})
+}
+
+func (h *Handler) GetPool(c *gin.Context) {
+	poolName := c.Query("pool_name")
+	if poolName == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "pool_name is required"))
+		return
+	}
+
+	pool, err := h.service.GetPool(c.Request.Context(), poolName)
+	if err != nil {
+		writeError(c, http.StatusNotFound, createError("NOT_FOUND", "pool not found"))
+		return
+	}
+
+	h.writeProjected(c, http.StatusOK, pool)
+}
+
+func (h *Handler) AddPoolMember(c *gin.Context) {
+	h.changePoolMember(c, h.service.AddPoolMember)
+}
+
+func (h *Handler) RemovePoolMember(c *gin.Context) {
+	h.changePoolMember(c, h.service.RemovePoolMember)
+}
+
+func (h *Handler) changePoolMember(c *gin.Context,
+	change func(context.Context, models.PoolMemberRequest) (*models.ReviewerPool, error)) {
+	var req models.PoolMemberRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	pool, err := change(c.Request.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrPoolNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "pool not found"))
+		case service.ErrUserNotFound:
+			writeError(c, http.StatusNotFound, createError("NOT_FOUND", "user not found"))
+		default:
+			writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pool": pool})
+}
+
+// ActorMiddleware attributes the request's changes to the principal of its
+// API key. X-Actor-ID is only trusted without API_KEYS or from an unscoped
+// key, e.g. a gateway passing on the end user; a team-scoped key can't
+// impersonate anyone. Changes without an actor are recorded as made by the
+// system
+func (h *Handler) ActorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID := c.GetString(apiKeyPrincipalKey)
+		if _, scoped := c.Get(apiKeyScopeKey); !scoped {
+			if header := strings.TrimSpace(c.GetHeader("X-Actor-ID")); header != "" {
+				actorID = header
+			}
+		}
+		if actorID != "" {
+			c.Request = c.Request.WithContext(service.WithActor(c.Request.Context(), actorID))
+		}
+		c.Next()
+	}
+}
+
+// ConcurrencyLimitMiddleware rejects requests beyond MAX_CONCURRENT_REQUESTS
+// in flight with 503 OVERLOADED instead of letting them queue for DB
+// connections. /health is never limited so probes keep working under load
+func (h *Handler) ConcurrencyLimitMiddleware() gin.HandlerFunc {
+	if h.cfg.MaxConcurrentRequests <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	slots := make(chan struct{}, h.cfg.MaxConcurrentRequests)
+	return func(c *gin.Context) {
+		if c.Request.URL.Path == "/health" {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			c.Next()
+		default:
+			c.Header("Retry-After", "1")
+			writeError(c, http.StatusServiceUnavailable, createError("OVERLOADED", "too many concurrent requests"))
+			c.Abort()
+		}
+	}
+}
+
+func (h *Handler) GetEvents(c *gin.Context) {
+	const defaultLimit, maxLimit = 100, 1000
+
+	limit := defaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", fmt.Sprintf("limit must be between 1 and %d", maxLimit)))
+			return
+		}
+		limit = parsed
+	}
+
+	response, err := h.service.GetEvents(c.Request.Context(), c.Query("pull_request_id"), limit)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "events")
+}
+
+func (h *Handler) GetDecisions(c *gin.Context) {
+	prID := c.Query("pull_request_id")
+	if prID == "" {
+		writeError(c, http.StatusBadRequest, createError("MISSING_PARAM", "pull_request_id is required"))
+		return
+	}
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	response, err := h.service.GetDecisions(c.Request.Context(), prID)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	h.writeProjectedList(c, http.StatusOK, response, "decisions")
+}
+
+func (h *Handler) GetFlags(c *gin.Context) {
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	flags := make([]models.FeatureFlag, 0, len(h.cfg.Flags))
+	for _, flag := range h.cfg.Flags {
+		flags = append(flags, models.FeatureFlag{Name: flag.Name, Enabled: flag.Enabled, Source: flag.Source})
+	}
+
+	h.writeProjectedList(c, http.StatusOK, models.FlagsResponse{Flags: flags}, "flags")
+}
+
+func (h *Handler) HealthCheck(c *gin.Context) {
+	err := h.service.CheckHealth(c.Request.Context())
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+	writeError(c, http.StatusServiceUnavailable, createError("INTERNAL_ERROR", err.Error()))
+}
+
+func (h *Handler) Version(c *gin.Context) {
+	c.JSON(http.StatusOK, version.Get())
+}
+
+// Metrics serves the query metrics for Prometheus to scrape
+func (h *Handler) Metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := h.service.WriteMetrics(c.Writer); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
+	}
+}
+
+func (h *Handler) Reconcile(c *gin.Context) {
+	var req models.ReconcileRequest
+	if err := h.bindJSON(c, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(c, http.StatusBadRequest, bindError(err))
+		return
+	}
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	report, err := h.service.Reconcile(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) GetSchema(c *gin.Context) {
+	info, err := h.service.GetSchemaInfo(c.Request.Context())
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// semanticStatus returns 422 for well-formed requests that break a business
+// rule when SEMANTIC_STATUS_422 is on, and the legacy status otherwise
+func (h *Handler) semanticStatus(legacy int) int {
+	if h.cfg.SemanticStatus422 {
+		return http.StatusUnprocessableEntity
+	}
+	return legacy
+}
+
+// problemMediaType is the RFC 7807 media type clients can ask for via Accept
+const problemMediaType = "application/problem+json"
+
+// writeError renders errResp as the regular ErrorResponse, or as RFC 7807
+// problem details when the client accepts application/problem+json. The
+// message is localized according to Accept-Language
+func writeError(c *gin.Context, status int, errResp models.ErrorResponse) {
+	locale, message := localizeMessage(c, errResp.Error.Code, errResp.Error.Message)
+	errResp.Error.Message = message
+	if locale != defaultLocale {
+		c.Header("Content-Language", locale)
+	}
+
+	if !strings.Contains(c.GetHeader("Accept"), problemMediaType) {
+		c.JSON(status, errResp)
+		return
+	}
+
+	c.Header("Content-Type", problemMediaType)
+	c.JSON(status, models.Problem{
+		Type:     "urn:review-service:error:" + strings.ToLower(errResp.Error.Code),
+		Title:    errResp.Error.Code,
+		Status:   status,
+		Detail:   errResp.Error.Message,
+		Instance: c.Request.URL.Path,
+		Code:     errResp.Error.Code,
+		Fields:   errResp.Error.Fields,
+
+		Suggestions: errResp.Error.Suggestions,
+		Reason:      errResp.Error.Reason,
+	})
+}
+
+// writeNoCandidate writes the NO_CANDIDATE conflict with the reason the
+// service computed, reporting false when err is something else
+func (h *Handler) writeNoCandidate(c *gin.Context, err error) bool {
+	var noCandidate *service.NoCandidateError
+	if !errors.As(err, &noCandidate) {
+		return false
+	}
+	errResp := createError("NO_CANDIDATE", noCandidate.Message())
+	errResp.Error.Reason = noCandidate.Reason
+	writeError(c, h.semanticStatus(http.StatusConflict), errResp)
+	return true
+}
+
+func createError(code, message string) models.ErrorResponse {
+	var errResp models.ErrorResponse
+	errResp.Error.Code = code
+	errResp.Error.Message = message
+	return errResp
+}
+
+// maxPageLimit caps the limit query parameter of paginated listings
+const maxPageLimit = 1000
+
+// parsePage reads the limit, offset and cursor query parameters
+func parsePage(c *gin.Context) (models.Page, error) {
+	var page models.Page
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return page, fmt.Errorf("offset must be a non-negative integer")
+		}
+		page.Offset = offset
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		if page.Offset > 0 {
+			return page, fmt.Errorf("offset and cursor can't be combined")
+		}
+		after, err := models.DecodePRCursor(raw)
+		if err != nil {
+			return page, err
+		}
+		page.After = after
+	}
+
+	return page, nil
+}
+
+// parseWindow accepts a number of days ("90d") or a Go duration ("36h")
+func parseWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		window = parsed
+	}
+
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be positive")
+	}
+	return window, nil
+}
+
+// bindError converts a binding error into INVALID_INPUT, listing every
+// invalid field when the error comes from struct validation
+func bindError(err error) models.ErrorResponse {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return createError("INVALID_INPUT", err.Error())
+	}
+
+	fields := make([]models.FieldError, 0, len(validationErrs))
+	names := make([]string, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		field := fieldPath(fe)
+		fields = append(fields, models.FieldError{
+			Field:   field,
+			Code:    strings.ToUpper(fe.Tag()),
+			Message: fieldMessage(field, fe),
+		})
+		names = append(names, field)
+	}
+
+	errResp := createError("INVALID_INPUT", "invalid fields: "+strings.Join(names, ", "))
+	errResp.Error.Fields = fields
+	return errResp
+}
+
+// fieldPath strips the request struct name from the validator namespace,
+// e.g. "CreateTeamRequest.members[0].user_id" -> "members[0].user_id"
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(field string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "max":
+		if fe.Kind() != reflect.String {
+			return field + " must be at most " + fe.Param()
+		}
+		return field + " must be at most " + fe.Param() + " characters"
+	case "url":
+		return field + " must be a valid URL"
+	case "oneof":
+		return field + " must be one of: " + fe.Param()
+	default:
+		return field + " failed " + fe.Tag() + " validation"
+	}
+}
+
+func init() {
+	// Report JSON field names instead of Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
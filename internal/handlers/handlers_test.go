@@ -0,0 +1,747 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"review-service/internal/config"
+	"review-service/internal/database"
+	"review-service/internal/models"
+	"review-service/internal/openapi"
+	"review-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakeStore keeps users and PRs in memory. Methods a test reaches without
+// them being implemented here panic through the nil embedded Store
+type fakeStore struct {
+	service.Store
+
+	mu     sync.Mutex
+	users  map[string]models.User
+	prs    map[string]*models.PullRequest
+	events []models.AuditEvent
+
+	// lookupErr fails user and PR lookups, like a lost connection
+	lookupErr error
+
+	// listed runs after ListPRs hands each PR to the caller, n counting from 1
+	listed func(n int)
+
+	// reviewerCap stands in for the pr_reviewers trigger when non-zero
+	reviewerCap int
+}
+
+func newFakeStore(users ...models.User) *fakeStore {
+	store := &fakeStore{
+		users: make(map[string]models.User),
+		prs:   make(map[string]*models.PullRequest),
+	}
+	for _, user := range users {
+		store.users[user.UserID] = user
+	}
+	return store
+}
+
+func (f *fakeStore) addPR(pr models.PullRequest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prs[pr.PullRequestID] = &pr
+}
+
+func (f *fakeStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lookupErr != nil {
+		return nil, f.lookupErr
+	}
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, database.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (f *fakeStore) GetUsersByIDs(ctx context.Context, userIDs []string) ([]models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []models.User
+	for _, userID := range userIDs {
+		if user, ok := f.users[userID]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeStore) GetActiveUsersByTeam(ctx context.Context, teamName string, excludeUserID string) ([]models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []models.User
+	for _, user := range f.users {
+		if user.TeamName == teamName && user.IsActive && user.UserID != excludeUserID {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	return users, nil
+}
+
+func (f *fakeStore) GetPRByID(ctx context.Context, prID string) (*models.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lookupErr != nil {
+		return nil, f.lookupErr
+	}
+	pr, ok := f.prs[prID]
+	if !ok {
+		return nil, database.ErrPRNotFound
+	}
+	copied := *pr
+	copied.AssignedReviewers = slices.Clone(pr.AssignedReviewers)
+	return &copied, nil
+}
+
+func (f *fakeStore) ListPRs(ctx context.Context, status, authorID string, fn func(models.PullRequest) error) error {
+	f.mu.Lock()
+	var prs []models.PullRequest
+	for _, pr := range f.prs {
+		if (status == "" || string(pr.Status) == status) && (authorID == "" || pr.AuthorID == authorID) {
+			prs = append(prs, *pr)
+		}
+	}
+	f.mu.Unlock()
+
+	sort.Slice(prs, func(i, j int) bool {
+		if !prs[i].CreatedAt.Equal(prs[j].CreatedAt.Time) {
+			return prs[i].CreatedAt.Before(prs[j].CreatedAt.Time)
+		}
+		return prs[i].PullRequestID < prs[j].PullRequestID
+	})
+	for i, pr := range prs {
+		if err := fn(pr); err != nil {
+			return err
+		}
+		if f.listed != nil {
+			f.listed(i + 1)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) MergePR(ctx context.Context, prID string) (time.Time, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, ok := f.prs[prID]
+	if !ok || pr.Status != models.PRStatusOpen {
+		return time.Time{}, false, nil
+	}
+	now := time.Now()
+	pr.Status = models.PRStatusMerged
+	pr.MergedAt = models.NewTimestamp(now)
+	return now, true, nil
+}
+
+func (f *fakeStore) CompleteReviews(ctx context.Context, prID string, at time.Time) error {
+	return nil
+}
+
+func (f *fakeStore) GetPRReviewerIDs(ctx context.Context, prID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.prs[prID].AssignedReviewers), nil
+}
+
+func (f *fakeStore) ReassignPRReviewers(ctx context.Context, prID string, reviewers []string, replacementID string, mergedAfter *time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Like pr_reviewers, a reviewer is stored once however often listed
+	distinct := slices.Compact(slices.Sorted(slices.Values(reviewers)))
+	if f.reviewerCap > 0 && len(distinct) > f.reviewerCap {
+		return false, fmt.Errorf("failed to assign reviewer to PR %s: %w", prID, database.ErrTooManyReviewers)
+	}
+	f.prs[prID].AssignedReviewers = slices.Clone(reviewers)
+	return true, nil
+}
+
+func (f *fakeStore) RecordEvent(ctx context.Context, event *models.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, *event)
+	return nil
+}
+
+func (f *fakeStore) RecordDecision(ctx context.Context, decision *models.AssignmentDecision) error {
+	return nil
+}
+
+func (f *fakeStore) PoolExists(ctx context.Context, name string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeStore) GetNotifiableUsers(ctx context.Context, userIDs []string, channel string) ([]string, error) {
+	return userIDs, nil
+}
+
+func (f *fakeStore) GetAuthorWebhookURL(ctx context.Context, authorID string) (string, error) {
+	return "", nil
+}
+
+func member(userID, teamName string) models.User {
+	return models.User{UserID: userID, Username: userID, TeamName: teamName, IsActive: true}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		MaxReviewers:       2,
+		AssignmentStrategy: config.StrategyRandom,
+	}
+}
+
+// newTestRouter registers the handlers the tests call behind the same
+// middleware as the server
+func newTestRouter(store service.Store, cfg *config.Config) *gin.Engine {
+	handler := NewHandler(service.NewService(store, cfg), cfg)
+
+	r := gin.New()
+	r.Use(handler.APIKeyMiddleware())
+	r.Use(handler.ActorMiddleware())
+	r.POST("/pullRequest/reassign", handler.ReassignReviewer)
+	r.POST("/pullRequest/merge", handler.MergePR)
+	r.POST("/pullRequest/delete", handler.DeletePR)
+	r.GET("/pullRequest/get", handler.GetPR)
+	r.GET("/pullRequest/list", handler.ListPRs)
+	r.GET("/admin/flags", handler.GetFlags)
+	r.POST("/pool/add", handler.CreatePool)
+	r.POST("/pool/addMember", handler.AddPoolMember)
+	r.POST("/pool/removeMember", handler.RemovePoolMember)
+	r.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	r.GET("/version", handler.Version)
+	return r
+}
+
+func doJSON(r http.Handler, method, path string, body any, header http.Header) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decodeError(t *testing.T, recorder *httptest.ResponseRecorder) models.ErrorResponse {
+	t.Helper()
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("decode error response %s: %v", recorder.Body, err)
+	}
+	return errResp
+}
+
+func TestReassignNoCandidate(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+	r := newTestRouter(store, testConfig())
+
+	recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", map[string]string{"pull_request_id": "pr-1", "old_user_id": "r1"}, nil)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", recorder.Code, recorder.Body)
+	}
+	errResp := decodeError(t, recorder)
+	if errResp.Error.Code != "NO_CANDIDATE" || errResp.Error.Reason != service.NoCandidateOnlyAuthorAndReviewers {
+		t.Errorf("error = %+v, want NO_CANDIDATE %s", errResp.Error, service.NoCandidateOnlyAuthorAndReviewers)
+	}
+}
+
+func TestMergeReportsAlreadyMerged(t *testing.T) {
+	store := newFakeStore(member("author", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen})
+	r := newTestRouter(store, testConfig())
+
+	for i, want := range []bool{false, true} {
+		recorder := doJSON(r, http.MethodPost, "/pullRequest/merge", map[string]string{"pull_request_id": "pr-1"}, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("merge %d: status = %d, want 200: %s", i+1, recorder.Code, recorder.Body)
+		}
+		var resp struct {
+			PR            models.PullRequest `json:"pr"`
+			AlreadyMerged bool               `json:"already_merged"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.AlreadyMerged != want || resp.PR.Status != models.PRStatusMerged {
+			t.Errorf("merge %d: already_merged = %v status %s, want %v MERGED", i+1, resp.AlreadyMerged, resp.PR.Status, want)
+		}
+	}
+}
+
+func TestPolicyInPRResponses(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	r := newTestRouter(store, testConfig())
+
+	// One reviewer of MAX_REVIEWERS 2 throughout, so every response reports
+	// the required count unmet
+	requests := []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, "/pullRequest/get?pull_request_id=pr-1", nil},
+		{http.MethodPost, "/pullRequest/reassign", map[string]string{"pull_request_id": "pr-1", "old_user_id": "r1"}},
+		{http.MethodPost, "/pullRequest/merge", map[string]string{"pull_request_id": "pr-1"}},
+	}
+	for _, req := range requests {
+		recorder := doJSON(r, req.method, req.path, req.body, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", req.path, recorder.Code, recorder.Body)
+		}
+		var resp struct {
+			PR models.PullRequest `json:"pr"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.PR.PolicySatisfied == nil || *resp.PR.PolicySatisfied {
+			t.Errorf("%s: policy_satisfied = %v, want false", req.path, resp.PR.PolicySatisfied)
+		}
+		if !slices.Equal(resp.PR.UnmetPolicies, []string{models.PolicyRequiredCount}) {
+			t.Errorf("%s: unmet_policies = %v, want [%s]", req.path, resp.PR.UnmetPolicies, models.PolicyRequiredCount)
+		}
+	}
+}
+
+func TestGetPRNotFound(t *testing.T) {
+	r := newTestRouter(newFakeStore(), testConfig())
+
+	recorder := doJSON(r, http.MethodGet, "/pullRequest/get?pull_request_id=missing", nil, nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", recorder.Code)
+	}
+	if code := decodeError(t, recorder).Error.Code; code != "NOT_FOUND" {
+		t.Errorf("code = %s, want NOT_FOUND", code)
+	}
+
+	recorder = doJSON(r, http.MethodGet, "/pullRequest/get", nil, nil)
+	if code := decodeError(t, recorder).Error.Code; recorder.Code != http.StatusBadRequest || code != "MISSING_PARAM" {
+		t.Errorf("without ID: %d %s, want 400 MISSING_PARAM", recorder.Code, code)
+	}
+}
+
+func TestGetFlags(t *testing.T) {
+	cfg := testConfig()
+	cfg.Flags = []config.Flag{
+		{Name: "ALLOW_SELF_REVIEW", Enabled: false, Source: config.FlagSourceDefault},
+		{Name: "SENIOR_COVERAGE", Enabled: true, Source: config.FlagSourceFile},
+	}
+	r := newTestRouter(newFakeStore(), cfg)
+
+	recorder := doJSON(r, http.MethodGet, "/admin/flags", nil, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	var resp models.FlagsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.FeatureFlag{
+		{Name: "ALLOW_SELF_REVIEW", Enabled: false, Source: "default"},
+		{Name: "SENIOR_COVERAGE", Enabled: true, Source: "file"},
+	}
+	if !slices.Equal(resp.Flags, want) {
+		t.Errorf("flags = %+v, want %+v", resp.Flags, want)
+	}
+}
+
+func TestAPIKeyRequired(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = map[string]config.APIKey{
+		"ops-key":  {Name: "ops"},
+		"team-key": {Name: "ci-bot", Teams: []string{"backend"}},
+	}
+	r := newTestRouter(newFakeStore(), cfg)
+
+	if recorder := doJSON(r, http.MethodGet, "/admin/flags", nil, nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("without key: status = %d, want 401", recorder.Code)
+	}
+	if recorder := doJSON(r, http.MethodGet, "/admin/flags", nil, http.Header{"X-Api-Key": {"ops-key"}}); recorder.Code != http.StatusOK {
+		t.Errorf("unscoped key: status = %d, want 200", recorder.Code)
+	}
+	if recorder := doJSON(r, http.MethodGet, "/admin/flags", nil, http.Header{"X-Api-Key": {"team-key"}}); recorder.Code != http.StatusForbidden {
+		t.Errorf("scoped key: status = %d, want 403", recorder.Code)
+	}
+	for _, path := range []string{"/health", "/version"} {
+		if recorder := doJSON(r, http.MethodGet, path, nil, nil); recorder.Code != http.StatusOK {
+			t.Errorf("%s without key: status = %d, want 200", path, recorder.Code)
+		}
+	}
+}
+
+func TestReassignAttributesActor(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = map[string]config.APIKey{
+		"ops-key":  {Name: "ops"},
+		"team-key": {Name: "ci-bot", Teams: []string{"backend"}},
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"scoped key", http.Header{"X-Api-Key": {"team-key"}}, "ci-bot"},
+		{"scoped key can't impersonate", http.Header{"X-Api-Key": {"team-key"}, "X-Actor-Id": {"mallory"}}, "ci-bot"},
+		{"unscoped key", http.Header{"X-Api-Key": {"ops-key"}}, "ops"},
+		{"unscoped key passes the end user on", http.Header{"X-Api-Key": {"ops-key"}, "X-Actor-Id": {"alice"}}, "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+			store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+			r := newTestRouter(store, cfg)
+
+			recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", map[string]string{"pull_request_id": "pr-1", "old_user_id": "r1"}, tt.header)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+			}
+			if len(store.events) != 1 || store.events[0].Type != models.EventReviewerReassign {
+				t.Fatalf("events = %+v, want one reassign", store.events)
+			}
+			if actor := store.events[0].ActorID; actor != tt.want {
+				t.Errorf("actor = %q, want %q", actor, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolChangesNeedUnscopedKey(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = map[string]config.APIKey{
+		"ops-key":  {Name: "ops"},
+		"team-key": {Name: "ci-bot", Teams: []string{"backend"}},
+	}
+	r := newTestRouter(newFakeStore(member("u1", "backend")), cfg)
+
+	requests := []struct {
+		path string
+		body any
+	}{
+		{"/pool/add", map[string]any{"pool_name": "security", "member_ids": []string{"u1"}}},
+		{"/pool/addMember", map[string]string{"pool_name": "security", "user_id": "u1"}},
+		{"/pool/removeMember", map[string]string{"pool_name": "security", "user_id": "u1"}},
+	}
+	for _, req := range requests {
+		recorder := doJSON(r, http.MethodPost, req.path, req.body, http.Header{"X-Api-Key": {"team-key"}})
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("%s with a scoped key: status = %d, want 403", req.path, recorder.Code)
+		}
+	}
+
+	// An unscoped key gets past authorization to the missing pool
+	recorder := doJSON(r, http.MethodPost, "/pool/addMember", map[string]string{"pool_name": "security", "user_id": "u1"}, http.Header{"X-Api-Key": {"ops-key"}})
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("unscoped key: status = %d, want 404", recorder.Code)
+	}
+}
+
+func TestScopeCheckFailsClosed(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = map[string]config.APIKey{"team-key": {Name: "ci-bot", Teams: []string{"backend"}}}
+	store := newFakeStore(member("author", "frontend"), member("r1", "frontend"), member("r2", "frontend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	r := newTestRouter(store, cfg)
+	header := http.Header{"X-Api-Key": {"team-key"}}
+	body := map[string]string{"pull_request_id": "pr-1", "old_user_id": "r1"}
+
+	if recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", body, header); recorder.Code != http.StatusForbidden {
+		t.Errorf("cross-team PR: status = %d, want 403", recorder.Code)
+	}
+
+	// An unknown PR passes the scope check and is reported as usual
+	missing := map[string]string{"pull_request_id": "missing", "old_user_id": "r1"}
+	if recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", missing, header); recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown PR: status = %d, want 404", recorder.Code)
+	}
+
+	store.lookupErr = errors.New("connection reset")
+	recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", body, header)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("failed lookup: status = %d, want 500", recorder.Code)
+	}
+	if len(store.events) != 0 {
+		t.Errorf("reassign went through after a failed scope check: %+v", store.events)
+	}
+}
+
+func TestDeletePRDatabaseError(t *testing.T) {
+	store := newFakeStore(member("author", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen})
+	r := newTestRouter(store, testConfig())
+
+	store.lookupErr = errors.New("connection reset")
+	recorder := doJSON(r, http.MethodPost, "/pullRequest/delete", map[string]string{"pull_request_id": "pr-1"}, nil)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestListPRsStream(t *testing.T) {
+	const total = 250
+	store := newFakeStore(member("author", "backend"))
+	start := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+	for i := range total {
+		// Pairs share created_at so the ID breaks the tie
+		store.addPR(models.PullRequest{
+			PullRequestID: fmt.Sprintf("pr-%03d", i),
+			AuthorID:      "author",
+			Status:        models.PRStatusOpen,
+			CreatedAt:     models.NewTimestamp(start.Add(time.Duration(i/2) * time.Minute)),
+		})
+	}
+	r := newTestRouter(store, testConfig())
+
+	recorder := httptest.NewRecorder()
+	// Items already handed over must reach the client before the rest is read
+	var flushedEarly bool
+	store.listed = func(n int) {
+		if n == total-1 {
+			flushedEarly = recorder.Flushed && strings.HasPrefix(recorder.Body.String(), `{"pull_requests":[`)
+		}
+	}
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/pullRequest/list?stream=true", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	if !flushedEarly {
+		t.Error("nothing was flushed before the listing finished")
+	}
+	var resp models.PRListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode stream: %v", err)
+	}
+	if len(resp.PullRequests) != total {
+		t.Fatalf("streamed %d PRs, want %d", len(resp.PullRequests), total)
+	}
+	for i, pr := range resp.PullRequests {
+		if want := fmt.Sprintf("pr-%03d", i); pr.PullRequestID != want {
+			t.Fatalf("item %d = %s, want %s", i, pr.PullRequestID, want)
+		}
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	r := newTestRouter(newFakeStore(), testConfig())
+
+	recorder := doJSON(r, http.MethodGet, "/pullRequest/get?pull_request_id=missing", nil,
+		http.Header{"Accept": {"application/problem+json"}})
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404: %s", recorder.Code, recorder.Body)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/problem+json") {
+		t.Errorf("Content-Type = %q, want application/problem+json", contentType)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	want := models.Problem{
+		Type:     "urn:review-service:error:not_found",
+		Title:    "NOT_FOUND",
+		Status:   http.StatusNotFound,
+		Detail:   "PR not found",
+		Instance: "/pullRequest/get",
+		Code:     "NOT_FOUND",
+	}
+	if !reflect.DeepEqual(problem, want) {
+		t.Errorf("problem = %+v, want %+v", problem, want)
+	}
+
+	// Without the Accept header the regular shape is kept
+	recorder = doJSON(r, http.MethodGet, "/pullRequest/get?pull_request_id=missing", nil, nil)
+	if errResp := decodeError(t, recorder); errResp.Error.Code != "NOT_FOUND" || errResp.Error.Message != "PR not found" {
+		t.Errorf("default error = %+v, want NOT_FOUND: PR not found", errResp.Error)
+	}
+}
+
+func TestLocalizedError(t *testing.T) {
+	store := newFakeStore(member("author", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusMerged})
+	r := newTestRouter(store, testConfig())
+
+	tests := []struct {
+		acceptLanguage, message, contentLanguage string
+	}{
+		{"ru-RU,ru;q=0.9,en;q=0.8", "PR не найден", "ru"},
+		{"en;q=0.5, ru", "PR не найден", "ru"},
+		{"de, en;q=0.9, ru;q=0.8", "PR not found", ""},
+		{"", "PR not found", ""},
+	}
+	for _, tt := range tests {
+		recorder := doJSON(r, http.MethodGet, "/pullRequest/get?pull_request_id=missing", nil, http.Header{"Accept-Language": {tt.acceptLanguage}})
+		errResp := decodeError(t, recorder)
+		if errResp.Error.Code != "NOT_FOUND" || errResp.Error.Message != tt.message {
+			t.Errorf("Accept-Language %q: error = %s %q, want NOT_FOUND %q", tt.acceptLanguage, errResp.Error.Code, errResp.Error.Message, tt.message)
+		}
+		if got := recorder.Header().Get("Content-Language"); got != tt.contentLanguage {
+			t.Errorf("Accept-Language %q: Content-Language = %q, want %q", tt.acceptLanguage, got, tt.contentLanguage)
+		}
+	}
+
+	// A code's generic translation covers messages without their own
+	recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", map[string]string{"pull_request_id": "pr-1", "old_user_id": "author"},
+		http.Header{"Accept-Language": {"ru"}})
+	if errResp := decodeError(t, recorder); errResp.Error.Code != "PR_MERGED" || errResp.Error.Message != "PR уже смёржен, изменения невозможны" {
+		t.Errorf("merged PR: error = %s %q, want the generic PR_MERGED translation", errResp.Error.Code, errResp.Error.Message)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxConcurrentRequests = 2
+	handler := NewHandler(service.NewService(newFakeStore(), cfg), cfg)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(handler.ConcurrencyLimitMiddleware())
+	r.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Fill every slot with a request that waits for release
+	var wg sync.WaitGroup
+	for range cfg.MaxConcurrentRequests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doJSON(r, http.MethodGet, "/slow", nil, nil)
+		}()
+		<-entered
+	}
+
+	recorder := doJSON(r, http.MethodGet, "/slow", nil, nil)
+	if recorder.Code != http.StatusServiceUnavailable || decodeError(t, recorder).Error.Code != "OVERLOADED" {
+		t.Errorf("overflow request: %d %s, want 503 OVERLOADED", recorder.Code, recorder.Body)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "1" {
+		t.Errorf("Retry-After = %q, want 1", retryAfter)
+	}
+	if recorder := doJSON(r, http.MethodGet, "/health", nil, nil); recorder.Code != http.StatusOK {
+		t.Errorf("/health under load: status = %d, want 200", recorder.Code)
+	}
+
+	close(release)
+	wg.Wait()
+
+	// Freed slots take requests again
+	go func() { <-entered }()
+	if recorder := doJSON(r, http.MethodGet, "/slow", nil, nil); recorder.Code != http.StatusOK {
+		t.Errorf("after release: status = %d, want 200", recorder.Code)
+	}
+}
+
+func TestReassignDuplicatedReviewer(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r1"}})
+	store.addPR(models.PullRequest{PullRequestID: "pr-2", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r1"}})
+	r := newTestRouter(store, testConfig())
+
+	// Only one of the two entries is replaced
+	recorder := doJSON(r, http.MethodPost, "/pullRequest/reassign", map[string]string{"pull_request_id": "pr-1", "old_user_id": "r1"}, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	var result models.ReassignResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.PR.AssignedReviewers, []string{"r2", "r1"}) {
+		t.Errorf("reviewers = %v, want [r2 r1]", result.PR.AssignedReviewers)
+	}
+
+	// Growing past the DB cap is a client-visible conflict, not a 500
+	store.reviewerCap = 1
+	recorder = doJSON(r, http.MethodPost, "/pullRequest/reassign", map[string]string{"pull_request_id": "pr-2", "old_user_id": "r1"}, nil)
+	if recorder.Code != http.StatusConflict || decodeError(t, recorder).Error.Code != "TOO_MANY_REVIEWERS" {
+		t.Errorf("over the cap: %d %s, want 409 TOO_MANY_REVIEWERS", recorder.Code, recorder.Body)
+	}
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	validator, err := openapi.Load("../../openapi.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	store := newFakeStore(member("author", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen})
+	cfg := testConfig()
+	handler := NewHandler(service.NewService(store, cfg), cfg)
+
+	r := gin.New()
+	r.Use(handler.OpenAPIValidationMiddleware(validator))
+	r.POST("/pullRequest/merge", handler.MergePR)
+	r.GET("/pullRequest/get", handler.GetPR)
+
+	tests := []struct {
+		name, method, path string
+		body               any
+		want               []models.FieldError
+	}{
+		{"missing body field", http.MethodPost, "/pullRequest/merge", map[string]any{}, []models.FieldError{
+			{Field: "pull_request_id", Code: "REQUIRED", Message: "pull_request_id is required"},
+		}},
+		{"wrong body type", http.MethodPost, "/pullRequest/merge", map[string]any{"pull_request_id": 1001}, []models.FieldError{
+			{Field: "pull_request_id", Code: "TYPE", Message: "pull_request_id must be string"},
+		}},
+		{"wrong query type", http.MethodGet, "/pullRequest/get?pull_request_id=pr-1&include_history=yes", nil, []models.FieldError{
+			{Field: "include_history", Code: "TYPE", Message: "include_history must be boolean"},
+		}},
+	}
+	for _, tt := range tests {
+		recorder := doJSON(r, tt.method, tt.path, tt.body, nil)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", tt.name, recorder.Code, recorder.Body)
+			continue
+		}
+		errResp := decodeError(t, recorder)
+		if errResp.Error.Code != "INVALID_INPUT" || !reflect.DeepEqual(errResp.Error.Fields, tt.want) {
+			t.Errorf("%s: %s fields %+v, want INVALID_INPUT %+v", tt.name, errResp.Error.Code, errResp.Error.Fields, tt.want)
+		}
+	}
+
+	// The rejected merge never reached the handler
+	if pr, _ := store.GetPRByID(context.Background(), "pr-1"); pr.Status != models.PRStatusOpen {
+		t.Errorf("status after rejected merges = %s, want OPEN", pr.Status)
+	}
+	if recorder := doJSON(r, http.MethodPost, "/pullRequest/merge", map[string]string{"pull_request_id": "pr-1"}, nil); recorder.Code != http.StatusOK {
+		t.Errorf("valid merge: status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+}
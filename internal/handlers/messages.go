@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLocale is used when Accept-Language names no catalog locale. Error
+// messages are written in it, so it needs no catalog
+const defaultLocale = "en"
+
+// catalog localizes error messages by locale and error code. Each code maps
+// the English message to its translation, the "" entry covers messages
+// without one of their own. The code itself is never localized
+var catalog = map[string]map[string]map[string]string{
+	"ru": {
+		"NOT_FOUND": {
+			"":                         "ресурс не найден",
+			"PR not found":             "PR не найден",
+			"user not found":           "пользователь не найден",
+			"team not found":           "команда не найдена",
+			"pool not found":           "пул ревьюверов не найден",
+			"reviewer pool not found":  "пул ревьюверов не найден",
+			"author/team not found":    "автор или команда не найдены",
+			"author's team not found":  "команда автора не найдена",
+			"user or mentor not found": "пользователь или ментор не найден",
+		},
+		"PR_MERGED": {
+			"": "PR уже смёржен, изменения невозможны",
+		},
+		"PR_EXISTS": {
+			"": "PR с таким id уже существует",
+		},
+		"TEAM_EXISTS": {
+			"": "команда с таким именем уже существует",
+		},
+		"POOL_EXISTS": {
+			"": "пул с таким именем уже существует",
+		},
+		"NOT_ASSIGNED": {
+			"": "пользователь не назначен ревьювером этого PR",
+		},
+		"ALREADY_ASSIGNED": {
+			"": "пользователь уже назначен ревьювером этого PR",
+		},
+		"NO_CANDIDATE": {
+			"": "нет активного кандидата на замену в команде",
+		},
+		"AUTHOR_REVIEW": {
+			"author cannot review their own PR": "автор не может ревьювить свой PR",
+			"user cannot be their own mentor":   "пользователь не может быть своим ментором",
+		},
+		"TOO_MANY_REVIEWERS": {
+			"": "у PR уже максимальное число ревьюверов",
+		},
+		"PR_DRAFT": {
+			"": "черновик нельзя смёржить, сначала отметьте PR готовым",
+			"draft PRs can't be approved, mark the PR ready first": "черновик нельзя одобрить, сначала отметьте PR готовым",
+		},
+		"AUTHOR_INACTIVE": {
+			"": "неактивный автор не может создавать PR",
+		},
+		"USER_IN_ANOTHER_TEAM": {
+			"": "участник уже состоит в другой команде, передайте move=true, чтобы перенести его",
+		},
+		"OVERLOADED": {
+			"": "сервис перегружен, повторите запрос позже",
+		},
+		"UNAUTHORIZED": {
+			"": "ключ API не указан или неизвестен",
+		},
+		"FORBIDDEN": {
+			"":                                 "ключ API не даёт доступа к этой команде",
+			"API key is limited to some teams": "ключ API ограничен отдельными командами",
+		},
+	},
+}
+
+// localizeMessage translates message into the best locale the client
+// accepts, returning the locale used and the message, unchanged when no
+// translation exists
+func localizeMessage(c *gin.Context, code, message string) (string, string) {
+	locale := preferredLocale(c.GetHeader("Accept-Language"))
+	messages, ok := catalog[locale][code]
+	if !ok {
+		return defaultLocale, message
+	}
+
+	if localized, ok := messages[message]; ok {
+		return locale, localized
+	}
+	if localized, ok := messages[""]; ok {
+		return locale, localized
+	}
+	return defaultLocale, message
+}
+
+// preferredLocale picks the highest weighted language of an Accept-Language
+// header that has a catalog, matching on the primary subtag ("ru-RU" -> "ru")
+func preferredLocale(header string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		primary, _, _ := strings.Cut(tag, "-")
+		langs = append(langs, weighted{lang: strings.ToLower(primary), q: q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	for _, l := range langs {
+		if l.q <= 0 {
+			break
+		}
+		if l.lang == defaultLocale {
+			return defaultLocale
+		}
+		if _, ok := catalog[l.lang]; ok {
+			return l.lang
+		}
+	}
+	return defaultLocale
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestedFields parses the comma-separated "fields" query parameter
+func requestedFields(c *gin.Context) []string {
+	var fields []string
+	for _, name := range strings.Split(c.Query("fields"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// pick keeps only the listed fields of a JSON object, returning the names
+// the object doesn't have as unknown
+func pick(full map[string]json.RawMessage, fields []string) (map[string]json.RawMessage, []string) {
+	projected := make(map[string]json.RawMessage, len(fields))
+	var unknown []string
+	for _, name := range fields {
+		value, ok := full[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		projected[name] = value
+	}
+	return projected, unknown
+}
+
+func toJSONObject(obj any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var full map[string]json.RawMessage
+	if err := json.Unmarshal(data, &full); err != nil {
+		return nil, err
+	}
+	return full, nil
+}
+
+// writeProjected writes obj limited to the top-level fields requested via
+// the "fields" query parameter, or the whole obj when it's absent
+func (h *Handler) writeProjected(c *gin.Context, status int, obj any) {
+	fields := requestedFields(c)
+	if len(fields) == 0 {
+		c.JSON(status, obj)
+		return
+	}
+
+	full, err := toJSONObject(obj)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	projected, unknown := pick(full, fields)
+	if len(unknown) > 0 && h.cfg.StrictFields {
+		writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "unknown fields: "+strings.Join(unknown, ", ")))
+		return
+	}
+
+	c.JSON(status, projected)
+}
+
+// writeProjectedList is writeProjected for list responses: the projection
+// applies to every item of the list stored under key, other keys are kept
+func (h *Handler) writeProjectedList(c *gin.Context, status int, obj any, key string) {
+	fields := requestedFields(c)
+	if len(fields) == 0 {
+		c.JSON(status, obj)
+		return
+	}
+
+	envelope, err := toJSONObject(obj)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(envelope[key], &items); err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+
+	projectedItems := make([]map[string]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		projected, unknown := pick(item, fields)
+		if len(unknown) > 0 && h.cfg.StrictFields {
+			writeError(c, http.StatusBadRequest, createError("INVALID_INPUT", "unknown fields: "+strings.Join(unknown, ", ")))
+			return
+		}
+		projectedItems = append(projectedItems, projected)
+	}
+
+	data, err := json.Marshal(projectedItems)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, createError("INTERNAL_ERROR", err.Error()))
+		return
+	}
+	envelope[key] = data
+
+	c.JSON(status, envelope)
+}
@@ -0,0 +1,11 @@
+package models
+
+// EmptyIfNil returns s, or an empty slice when s is nil. Every collection in
+// a response goes through it (or is built from an empty literal) so clients
+// always get [] and never null
+func EmptyIfNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// maxCursorLength bounds the cursor accepted from clients before decoding
+const maxCursorLength = 1024
+
+// ErrInvalidCursor is returned for cursors that weren't issued by the service
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PRCursor is the keyset position after the last PR of a page, ordered by
+// (At, pull_request_id). At is assigned_at in a reviewer's own listing and
+// created_at everywhere else. Clients only see it as an opaque token
+type PRCursor struct {
+	At            time.Time
+	PullRequestID string
+}
+
+type cursorPayload struct {
+	At            int64  `json:"t"`
+	PullRequestID string `json:"id"`
+}
+
+// NewPRCursor returns the cursor continuing after pr, by its AssignedAt
+// when the listing set it and by CreatedAt otherwise
+func NewPRCursor(pr PullRequest) *PRCursor {
+	cursor := &PRCursor{PullRequestID: pr.PullRequestID}
+	switch {
+	case pr.AssignedAt != nil:
+		cursor.At = pr.AssignedAt.Time
+	case pr.CreatedAt != nil:
+		cursor.At = pr.CreatedAt.Time
+	}
+	return cursor
+}
+
+// Encode returns the opaque token clients pass back as cursor. Microseconds
+// match the precision Postgres stores timestamps with
+func (c *PRCursor) Encode() string {
+	payload, _ := json.Marshal(cursorPayload{
+		At:            c.At.UnixMicro(),
+		PullRequestID: c.PullRequestID,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodePRCursor parses a token produced by Encode
+func DecodePRCursor(token string) (*PRCursor, error) {
+	if len(token) > maxCursorLength {
+		return nil, ErrInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.PullRequestID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &PRCursor{
+		At:            time.UnixMicro(payload.At).UTC(),
+		PullRequestID: payload.PullRequestID,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPRCursorRoundTrip(t *testing.T) {
+	created := time.Date(2025, 1, 10, 9, 15, 0, 123456789, time.UTC)
+	cursor := NewPRCursor(PullRequest{PullRequestID: "pr-1001", CreatedAt: NewTimestamp(created)})
+
+	decoded, err := DecodePRCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodePRCursor: %v", err)
+	}
+	if decoded.PullRequestID != "pr-1001" {
+		t.Errorf("PullRequestID = %q, want pr-1001", decoded.PullRequestID)
+	}
+	// Postgres keeps microseconds, so the cursor does too
+	if want := created.Truncate(time.Microsecond); !decoded.At.Equal(want) {
+		t.Errorf("At = %v, want %v", decoded.At, want)
+	}
+}
+
+func TestNewPRCursorPrefersAssignedAt(t *testing.T) {
+	created := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+	assigned := created.Add(48 * time.Hour)
+	pr := PullRequest{PullRequestID: "pr-1001", CreatedAt: NewTimestamp(created)}
+
+	if cursor := NewPRCursor(pr); !cursor.At.Equal(created) {
+		t.Errorf("without assigned_at: At = %v, want created_at %v", cursor.At, created)
+	}
+	pr.AssignedAt = NewTimestamp(assigned)
+	if cursor := NewPRCursor(pr); !cursor.At.Equal(assigned) {
+		t.Errorf("with assigned_at: At = %v, want %v", cursor.At, assigned)
+	}
+}
+
+func TestDecodePRCursorRejectsForeignTokens(t *testing.T) {
+	tests := map[string]string{
+		"not base64":  "%%%",
+		"not JSON":    base64.RawURLEncoding.EncodeToString([]byte("plain")),
+		"missing ID":  base64.RawURLEncoding.EncodeToString([]byte(`{"t":1}`)),
+		"over length": strings.Repeat("a", maxCursorLength+1),
+	}
+	for name, token := range tests {
+		if _, err := DecodePRCursor(token); err != ErrInvalidCursor {
+			t.Errorf("%s: error = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
@@ -1,83 +1,793 @@
-package models
-
-import "time"
-
-type ErrorResponse struct {
-	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
-}
-
-type TeamMember struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	IsActive bool   `json:"is_active"`
-}
-
-type Team struct {
-	TeamName string       `json:"team_name"`
-	Members  []TeamMember `json:"members"`
-}
-
-type User struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	TeamName string `json:"team_name"`
-	IsActive bool   `json:"is_active"`
-}
-
-type PullRequestStatus string
-
-const (
-	PRStatusOpen   PullRequestStatus = "OPEN"
-	PRStatusMerged PullRequestStatus = "MERGED"
-)
-
-type PullRequest struct {
-	PullRequestID     string            `json:"pull_request_id"`
-	PullRequestName   string            `json:"pull_request_name"`
-	AuthorID          string            `json:"author_id"`
-	Status            PullRequestStatus `json:"status"`
-	AssignedReviewers []string          `json:"assigned_reviewers"`
-	CreatedAt         *time.Time        `json:"created_at,omitempty"`
-	MergedAt          *time.Time        `json:"merged_at,omitempty"`
-}
-
-type PullRequestShort struct {
-	PullRequestID   string            `json:"pull_request_id"`
-	PullRequestName string            `json:"pull_request_name"`
-	AuthorID        string            `json:"author_id"`
-	Status          PullRequestStatus `json:"status"`
-}
-
-// Request structures
-type CreateTeamRequest struct {
-	TeamName string       `json:"team_name"`
-	Members  []TeamMember `json:"members"`
-}
-
-type SetUserActiveRequest struct {
-	UserID   string `json:"user_id"`
-	IsActive bool   `json:"is_active"`
-}
-
-type CreatePRRequest struct {
-	PullRequestID   string `json:"pull_request_id"`
-	PullRequestName string `json:"pull_request_name"`
-	AuthorID        string `json:"author_id"`
-}
-
-type MergePRRequest struct {
-	PullRequestID string `json:"pull_request_id"`
-}
-
-type ReassignReviewerRequest struct {
-	PullRequestID string `json:"pull_request_id"`
-	OldUserID     string `json:"old_user_id"`
-}
-
-type UserPRsResponse struct {
-	UserID       string             `json:"user_id"`
-	PullRequests []PullRequestShort `json:"pull_requests"`
-}
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ErrorResponse struct {
+	Error struct {
+		Code    string       `json:"code"`
+		Message string       `json:"message"`
+		Fields  []FieldError `json:"fields,omitempty"`
+
+		// Suggestions lists near-match names for a missed lookup, when enabled
+		Suggestions []string `json:"suggestions,omitempty"`
+
+		// Reason tells why NO_CANDIDATE found nobody, as a stable code
+		Reason string `json:"reason,omitempty"`
+	} `json:"error"`
+}
+
+// Problem is the RFC 7807 form of ErrorResponse, Code and Fields are
+// extension members carrying the same data
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Code     string       `json:"code"`
+	Fields   []FieldError `json:"fields,omitempty"`
+
+	Suggestions []string `json:"suggestions,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+}
+
+// FieldError describes a single invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type TeamMember struct {
+	UserID   string `json:"user_id" binding:"required"`
+	Username string `json:"username" binding:"required"`
+
+	// IsActive is nil when a request omits it, DEFAULT_MEMBER_ACTIVE
+	// applies then. Stored members always have it
+	IsActive *bool `json:"is_active"`
+
+	// ManagerID is the member's manager, who never reviews the member's PRs
+	// (nor the member the manager's)
+	ManagerID string `json:"manager_id,omitempty"`
+
+	// UTCOffsetMinutes is the member's time zone, used to prefer reviewers
+	// whose working day is on or about to be
+	UTCOffsetMinutes *int `json:"utc_offset_minutes,omitempty" binding:"omitempty,min=-720,max=840"`
+
+	// Seniority is junior or senior, empty when unknown. SENIOR_COVERAGE
+	// gives junior authors' PRs a senior reviewer
+	Seniority string `json:"seniority,omitempty" binding:"omitempty,oneof=junior senior"`
+}
+
+// Seniority levels of users
+const (
+	SeniorityJunior = "junior"
+	SenioritySenior = "senior"
+)
+
+type Team struct {
+	TeamName  string       `json:"team_name"`
+	Members   []TeamMember `json:"members"`
+	CreatedBy string       `json:"created_by,omitempty"`
+	CreatedAt *Timestamp   `json:"created_at,omitempty"`
+
+	// AssignmentSeed makes the team's reviewer selection reproducible per PR
+	AssignmentSeed *int64 `json:"assignment_seed,omitempty"`
+
+	// Review window in UTC hours [start, end), PRs created outside it may
+	// get their reviewers deferred until it opens
+	ReviewWindowStart *int `json:"review_window_start,omitempty"`
+	ReviewWindowEnd   *int `json:"review_window_end,omitempty"`
+
+	// WebhookURL receives the team's notifications instead of WEBHOOK_URL
+	WebhookURL string `json:"webhook_url,omitempty"`
+
+	// MoveMembers lets creation move members out of the team they belong to
+	MoveMembers bool `json:"-"`
+}
+
+// TeamMemberSummary counts the outcome of a team creation's member upserts
+type TeamMemberSummary struct {
+	MembersCreated int `json:"members_created"`
+	MembersUpdated int `json:"members_updated"`
+	ActiveMembers  int `json:"active_members"`
+}
+
+type ReviewerLoad struct {
+	UserID      string `json:"user_id"`
+	Username    string `json:"username"`
+	OpenReviews int    `json:"open_reviews"`
+}
+
+type TeamReviewerLoad struct {
+	TeamName string         `json:"team_name"`
+	Members  []ReviewerLoad `json:"members"`
+}
+
+// ReviewerCountBucket is the number of PRs that have exactly ReviewerCount reviewers
+type ReviewerCountBucket struct {
+	ReviewerCount int `json:"reviewer_count"`
+	PRCount       int `json:"pr_count"`
+}
+
+// ReviewerDistribution is a histogram of reviewers per PR for a team's PRs.
+// Only non-empty buckets are listed, ordered by reviewer count
+type ReviewerDistribution struct {
+	TeamName string                `json:"team_name"`
+	Status   string                `json:"status,omitempty"`
+	Buckets  []ReviewerCountBucket `json:"buckets"`
+}
+
+// TeamCapacity is a team's active headcount against its open review load.
+// LoadPerMember is nil for a team without active members
+type TeamCapacity struct {
+	TeamName      string   `json:"team_name"`
+	ActiveMembers int      `json:"active_members"`
+	OpenReviews   int      `json:"open_reviews"`
+	LoadPerMember *float64 `json:"load_per_member"`
+}
+
+type TeamCapacityResponse struct {
+	Teams []TeamCapacity `json:"teams"`
+}
+
+// ReviewerResponseTime summarizes how fast a reviewer approves after being
+// assigned. The averages are nil until the reviewer approved something
+type ReviewerResponseTime struct {
+	UserID                string   `json:"user_id"`
+	Username              string   `json:"username"`
+	Responded             int      `json:"responded"`
+	Pending               int      `json:"pending"`
+	AvgResponseSeconds    *float64 `json:"avg_response_seconds"`
+	MedianResponseSeconds *float64 `json:"median_response_seconds"`
+}
+
+// LabelUsage is a label with the number of PRs carrying it
+type LabelUsage struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+type LabelsResponse struct {
+	TeamName string       `json:"team_name,omitempty"`
+	Labels   []LabelUsage `json:"labels"`
+}
+
+type ResponseTimesResponse struct {
+	TeamName  string                 `json:"team_name,omitempty"`
+	Reviewers []ReviewerResponseTime `json:"reviewers"`
+}
+
+// Merge throughput bucket sizes
+const (
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// ThroughputBucket is the number of PRs merged in the bucket starting at Start
+type ThroughputBucket struct {
+	Start       *Timestamp `json:"start"`
+	MergedCount int        `json:"merged_count"`
+}
+
+// MergeThroughput lists every bucket of the window, empty ones included
+type MergeThroughput struct {
+	Bucket  string             `json:"bucket"`
+	Window  string             `json:"window"`
+	Buckets []ThroughputBucket `json:"buckets"`
+}
+
+// TeamUnassignedMembers lists active members never assigned as reviewers
+type TeamUnassignedMembers struct {
+	TeamName string `json:"team_name"`
+	Members  []User `json:"members"`
+}
+
+type User struct {
+	UserID       string     `json:"user_id"`
+	Username     string     `json:"username"`
+	TeamName     string     `json:"team_name"`
+	IsActive     bool       `json:"is_active"`
+	LastActiveAt *Timestamp `json:"last_active_at,omitempty"`
+	ManagerID    string     `json:"manager_id,omitempty"`
+
+	UTCOffsetMinutes *int `json:"utc_offset_minutes,omitempty"`
+
+	Seniority string `json:"seniority,omitempty"`
+
+	// AcceptingReviews is false while the user paused new assignments
+	// without becoming inactive
+	AcceptingReviews *bool `json:"accepting_reviews,omitempty"`
+
+	NotificationPrefs *NotificationPrefs `json:"notification_prefs,omitempty"`
+
+	// CalendarBusy is the busy window last pushed by a calendar integration.
+	// The user isn't picked as a reviewer while it lasts
+	CalendarBusy *BusyWindow `json:"calendar_busy,omitempty"`
+
+	// LastReviewCompletedAt is when a PR the user reviewed was last merged,
+	// only loaded for reviewer candidates
+	LastReviewCompletedAt *Timestamp `json:"-"`
+
+	// OpenReviews is the number of open PRs the user reviews, returned by
+	// setIsActive so a reactivated user isn't overloaded right away
+	OpenReviews *int `json:"open_reviews,omitempty"`
+}
+
+// Notification channels a user can subscribe to
+const (
+	ChannelWebhook = "webhook"
+)
+
+// NotificationPrefs controls which notifications mention the user and where
+// they're delivered. A user with Assignments off or no channels is skipped
+type NotificationPrefs struct {
+	Assignments bool     `json:"assignments"`
+	Channels    []string `json:"channels" binding:"dive,oneof=webhook"`
+}
+
+type PullRequestStatus string
+
+const (
+	PRStatusOpen   PullRequestStatus = "OPEN"
+	PRStatusMerged PullRequestStatus = "MERGED"
+
+	// PRStatusDraft PRs get no reviewers and can't merge until marked ready
+	PRStatusDraft PullRequestStatus = "DRAFT"
+)
+
+// IsValid reports whether s is a known status. Every status write and filter
+// checks it first
+func (s PullRequestStatus) IsValid() bool {
+	switch s {
+	case PRStatusOpen, PRStatusMerged, PRStatusDraft:
+		return true
+	}
+	return false
+}
+
+type PullRequest struct {
+	PullRequestID     string            `json:"pull_request_id"`
+	PullRequestName   string            `json:"pull_request_name"`
+	AuthorID          string            `json:"author_id"`
+	Status            PullRequestStatus `json:"status"`
+	AssignedReviewers []string          `json:"assigned_reviewers"`
+	CreatedAt         *Timestamp        `json:"created_at,omitempty"`
+	MergedAt          *Timestamp        `json:"merged_at,omitempty"`
+	DeletedAt         *Timestamp        `json:"deleted_at,omitempty"`
+	ReviewerPool      string            `json:"reviewer_pool,omitempty"`
+
+	// ReviewRequired is false for PRs created without reviewers, e.g. docs
+	ReviewRequired *bool `json:"review_required,omitempty"`
+
+	Labels []string `json:"labels,omitempty"`
+
+	// ReviewerTeams lists the distinct teams of the assigned reviewers,
+	// returned when requested with include_reviewer_teams=true
+	ReviewerTeams []string `json:"reviewer_teams,omitempty"`
+
+	// PendingAssignment is set while reviewer assignment is deferred to the worker
+	PendingAssignment bool `json:"pending_assignment,omitempty"`
+
+	// ReviewerReasons explains the selection, returned on create when requested
+	ReviewerReasons []ReviewerReason `json:"reviewer_reasons,omitempty"`
+
+	// AssignmentAlgorithm names the strategy that picked the reviewers,
+	// returned on create and reassign
+	AssignmentAlgorithm string `json:"assignment_algorithm,omitempty"`
+
+	// AssignmentShortfall is set on create when fewer reviewers than required
+	// could be assigned, e.g. the author is the only other team member
+	AssignmentShortfall *AssignmentShortfall `json:"assignment_shortfall,omitempty"`
+
+	// AssignmentTrace explains the selection step by step, returned on
+	// create with explain=true
+	AssignmentTrace *AssignmentTrace `json:"assignment_trace,omitempty"`
+
+	// ReviewerHistory lists every assignment including removed reviewers,
+	// returned by /pullRequest/get with include_history=true
+	ReviewerHistory []ReviewerHistoryEntry `json:"reviewer_history,omitempty"`
+
+	// AssignedAt is when the reviewer was assigned, set only in a reviewer's
+	// own listing, which it orders and pages
+	AssignedAt *Timestamp `json:"-"`
+
+	// PolicySatisfied tells whether the current reviewers meet the configured
+	// review policies, UnmetPolicies names those they don't. Returned with
+	// every single PR, not in listings
+	PolicySatisfied *bool    `json:"policy_satisfied,omitempty"`
+	UnmetPolicies   []string `json:"unmet_policies,omitempty"`
+}
+
+// Review policies reported in UnmetPolicies
+const (
+	PolicyRequiredCount  = "required-count"
+	PolicyDistinctTeams  = "distinct-teams"
+	PolicyRequiredGroups = "required-groups"
+	PolicySeniorCoverage = "senior-coverage"
+)
+
+// ReviewerHistoryEntry is one assignment of a reviewer to a PR, RemovedAt is
+// set once reassignment or unassignment took the reviewer off
+type ReviewerHistoryEntry struct {
+	UserID     string     `json:"user_id"`
+	AssignedAt *Timestamp `json:"assigned_at"`
+	RemovedAt  *Timestamp `json:"removed_at,omitempty"`
+}
+
+// MarshalJSON adds age_seconds, the whole seconds between created_at and the
+// moment the response is written. Both sides are absolute instants, so the
+// age doesn't depend on the server or database time zone
+func (pr PullRequest) MarshalJSON() ([]byte, error) {
+	type plain PullRequest
+	return json.Marshal(struct {
+		plain
+		AgeSeconds *int64 `json:"age_seconds,omitempty"`
+	}{plain: plain(pr), AgeSeconds: pr.ageSeconds()})
+}
+
+func (pr PullRequest) ageSeconds() *int64 {
+	if pr.CreatedAt == nil {
+		return nil
+	}
+	age := max(int64(time.Since(pr.CreatedAt.Time)/time.Second), 0)
+	return &age
+}
+
+// AssignmentShortfall compares the required reviewer count with the actual one
+type AssignmentShortfall struct {
+	Required int `json:"required"`
+	Actual   int `json:"actual"`
+}
+
+type ReviewerReason struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+// AssignmentTrace lists every member of the candidate pool, why those that
+// weren't chosen were dropped, and the final choice
+type AssignmentTrace struct {
+	// Pool is "team:<name>" or "pool:<name>"
+	Pool       string           `json:"pool"`
+	Candidates []string         `json:"candidates"`
+	Excluded   []ReviewerReason `json:"excluded"`
+	Selected   []ReviewerReason `json:"selected"`
+}
+
+// Reviewer is a reviewer's assignment to a PR
+type Reviewer struct {
+	UserID     string     `json:"user_id"`
+	Note       string     `json:"note,omitempty"`
+	AssignedAt *Timestamp `json:"assigned_at,omitempty"`
+	ApprovedAt *Timestamp `json:"approved_at,omitempty"`
+
+	// ResponseSeconds is the time from assignment to the first approval
+	ResponseSeconds *int64 `json:"response_seconds,omitempty"`
+}
+
+type PRReviewersResponse struct {
+	PullRequestID string     `json:"pull_request_id"`
+	Reviewers     []Reviewer `json:"reviewers"`
+}
+
+type PullRequestShort struct {
+	PullRequestID   string            `json:"pull_request_id"`
+	PullRequestName string            `json:"pull_request_name"`
+	AuthorID        string            `json:"author_id"`
+	Status          PullRequestStatus `json:"status"`
+}
+
+// ReviewerPool is a named set of reviewers independent of teams
+type ReviewerPool struct {
+	PoolName string `json:"pool_name"`
+	Members  []User `json:"members"`
+}
+
+// Request structures
+type CreateTeamRequest struct {
+	TeamName  string       `json:"team_name" binding:"required"`
+	Members   []TeamMember `json:"members" binding:"dive"`
+	CreatedBy string       `json:"created_by,omitempty"`
+
+	AssignmentSeed *int64 `json:"assignment_seed,omitempty"`
+
+	ReviewWindowStart *int `json:"review_window_start,omitempty" binding:"omitempty,min=0,max=23,required_with=ReviewWindowEnd"`
+	ReviewWindowEnd   *int `json:"review_window_end,omitempty" binding:"omitempty,min=0,max=23,required_with=ReviewWindowStart"`
+
+	WebhookURL string `json:"webhook_url,omitempty" binding:"omitempty,url"`
+
+	// Move allows members that belong to another team to be moved into this
+	// one, without it such members fail the request
+	Move bool `json:"move,omitempty"`
+}
+
+type CreateTeamsBatchRequest struct {
+	Teams []CreateTeamRequest `json:"teams" binding:"required,dive"`
+}
+
+// Outcomes of a single team in a batch creation
+const (
+	BatchStatusCreated    = "created"
+	BatchStatusTeamExists = "TEAM_EXISTS"
+	BatchStatusError      = "error"
+)
+
+type TeamBatchResult struct {
+	TeamName string `json:"team_name"`
+	Status   string `json:"status"`
+	Team     *Team  `json:"team,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SetUserActiveRequest struct {
+	UserID   string `json:"user_id" binding:"required"`
+	IsActive bool   `json:"is_active"`
+}
+
+// SetMentorRequest sets the reviewer always assigned to the user's PRs,
+// an empty MentorID removes the rule
+type SetMentorRequest struct {
+	UserID   string `json:"user_id" binding:"required"`
+	MentorID string `json:"mentor_id"`
+}
+
+type AuthorMentor struct {
+	UserID   string `json:"user_id"`
+	MentorID string `json:"mentor_id,omitempty"`
+}
+
+type SetAcceptingReviewsRequest struct {
+	UserID           string `json:"user_id" binding:"required"`
+	AcceptingReviews bool   `json:"accepting_reviews"`
+}
+
+// BusyWindow is a period a user is busy or out of office, ending at End
+type BusyWindow struct {
+	Start Timestamp `json:"start"`
+	End   Timestamp `json:"end"`
+}
+
+// Covers tells whether t falls within the window
+func (w *BusyWindow) Covers(t time.Time) bool {
+	return !t.Before(w.Start.Time) && t.Before(w.End.Time)
+}
+
+// SetCalendarAvailabilityRequest replaces the user's calendar busy window,
+// a nil Busy clears it
+type SetCalendarAvailabilityRequest struct {
+	UserID string      `json:"user_id" binding:"required"`
+	Busy   *BusyWindow `json:"busy"`
+}
+
+type SetNotificationPrefsRequest struct {
+	UserID            string             `json:"user_id" binding:"required"`
+	NotificationPrefs *NotificationPrefs `json:"notification_prefs" binding:"required"`
+}
+
+type CreatePRRequest struct {
+	// PullRequestID is generated by the server when omitted
+	PullRequestID   string `json:"pull_request_id,omitempty"`
+	PullRequestName string `json:"pull_request_name" binding:"required"`
+	AuthorID        string `json:"author_id" binding:"required"`
+	ReviewerPool    string `json:"reviewer_pool,omitempty"`
+
+	// ReviewRequired defaults to true, false creates the PR without reviewers
+	ReviewRequired *bool `json:"review_required,omitempty"`
+
+	// Labels name the areas the PR touches
+	Labels []string `json:"labels,omitempty" binding:"max=20,dive,required,max=100"`
+
+	// Draft opens the PR as a draft, reviewers are assigned on markReady
+	Draft bool `json:"draft,omitempty"`
+
+	// DryRun is set from the X-Dry-Run header, nothing is persisted
+	DryRun bool `json:"-"`
+
+	// Explain is set from the explain query parameter
+	Explain bool `json:"-"`
+}
+
+type MergePRRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+}
+
+type MarkReadyRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+}
+
+type AssignReviewerRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+	UserID        string `json:"user_id" binding:"required"`
+	Note          string `json:"note,omitempty" binding:"max=500"`
+}
+
+type DeletePRRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+}
+
+type ReassignReviewerRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+	OldUserID     string `json:"old_user_id" binding:"required"`
+}
+
+// ReassignResult is the reassign response. CoReviewerFallback marks a
+// RELAXED_REASSIGN result where ReplacedBy was already a reviewer of the PR,
+// ReviewerRemoved a DROP_UNREPLACEABLE_REVIEWER one with nobody replacing
+type ReassignResult struct {
+	PR                 *PullRequest `json:"pr"`
+	ReplacedBy         string       `json:"replaced_by"`
+	ReplacementTeam    string       `json:"replacement_team,omitempty"`
+	CoReviewerFallback bool         `json:"co_reviewer_fallback,omitempty"`
+	ReviewerRemoved    bool         `json:"reviewer_removed,omitempty"`
+}
+
+// ApproveResult is the approve response, Reviewer being the approving
+// reviewer's assignment with its approved_at
+type ApproveResult struct {
+	PR       *PullRequest `json:"pr"`
+	Reviewer *Reviewer    `json:"reviewer,omitempty"`
+}
+
+type UnassignReviewerRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+	UserID        string `json:"user_id" binding:"required"`
+}
+
+type CreatePoolRequest struct {
+	PoolName  string   `json:"pool_name" binding:"required"`
+	MemberIDs []string `json:"member_ids"`
+}
+
+type PoolMemberRequest struct {
+	PoolName string `json:"pool_name" binding:"required"`
+	UserID   string `json:"user_id" binding:"required"`
+}
+
+type ApprovePRRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+	UserID        string `json:"user_id" binding:"required"`
+}
+
+// ApprovalRate summarizes how a reviewer handled assignments on merged PRs
+type ApprovalRate struct {
+	UserID                string  `json:"user_id"`
+	Window                string  `json:"window"`
+	MergedAssignments     int     `json:"merged_assignments"`
+	Approved              int     `json:"approved"`
+	MergedWithoutApproval int     `json:"merged_without_approval"`
+	ApprovalRate          float64 `json:"approval_rate"`
+}
+
+type PRListResponse struct {
+	PullRequests []PullRequest `json:"pull_requests"`
+}
+
+// Values of the reviewers_mode query parameter of /pullRequest/list
+const (
+	ReviewersModeFull  = "full"
+	ReviewersModeCount = "count"
+)
+
+// PullRequestReviewerCount is a listed PR with reviewers_mode=count: the
+// reviewer IDs are left out and only their number is returned
+type PullRequestReviewerCount struct {
+	PullRequest
+}
+
+func NewPullRequestReviewerCount(pr PullRequest) PullRequestReviewerCount {
+	return PullRequestReviewerCount{PullRequest: pr}
+}
+
+// MarshalJSON encodes the PR like PullRequest does, with reviewer_count in
+// place of assigned_reviewers
+func (pr PullRequestReviewerCount) MarshalJSON() ([]byte, error) {
+	type plain PullRequest
+	return json.Marshal(struct {
+		plain
+
+		// AssignedReviewers shadows the embedded IDs so they aren't encoded
+		AssignedReviewers *struct{} `json:"assigned_reviewers,omitempty"`
+		ReviewerCount     int       `json:"reviewer_count"`
+		AgeSeconds        *int64    `json:"age_seconds,omitempty"`
+	}{plain: plain(pr.PullRequest), ReviewerCount: len(pr.AssignedReviewers), AgeSeconds: pr.ageSeconds()})
+}
+
+// WarningReviewerInactive flags PRs still reviewed by an inactive user
+const WarningReviewerInactive = "REVIEWER_INACTIVE"
+
+type UserPRsResponse struct {
+	UserID       string             `json:"user_id"`
+	PullRequests []PullRequestShort `json:"pull_requests"`
+
+	// IsActive is the user's state, returned by /users/getReview for known
+	// users; Warning is set for inactive ones per INACTIVE_REVIEWER_PRS
+	IsActive *bool  `json:"is_active,omitempty"`
+	Warning  string `json:"warning,omitempty"`
+
+	// NextCursor continues a paginated listing, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Modes of /pullRequest/byReviewers: PRs reviewed by all of the users or by
+// any of them
+const (
+	ReviewersMatchAll = "all"
+	ReviewersMatchAny = "any"
+)
+
+// ReviewersPRsResponse lists the PRs matched by /pullRequest/byReviewers
+type ReviewersPRsResponse struct {
+	UserIDs      []string           `json:"user_ids"`
+	Mode         string             `json:"mode"`
+	PullRequests []PullRequestShort `json:"pull_requests"`
+
+	// NextCursor continues a paginated listing, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CoverageGap is an open PR without a single active reviewer
+type CoverageGap struct {
+	PullRequestID   string            `json:"pull_request_id"`
+	PullRequestName string            `json:"pull_request_name"`
+	AuthorID        string            `json:"author_id"`
+	Status          PullRequestStatus `json:"status"`
+	CreatedAt       *Timestamp        `json:"created_at,omitempty"`
+	TeamName        string            `json:"team_name"`
+
+	// InactiveReviewers are the assigned reviewers, empty if none ever were
+	InactiveReviewers []string `json:"inactive_reviewers"`
+}
+
+type CoverageGapsResponse struct {
+	PullRequests []CoverageGap `json:"pull_requests"`
+
+	// NextCursor continues a paginated listing, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page selects a slice of a listing. Limit 0 means no limit; After is a
+// keyset cursor and can't be combined with Offset
+type Page struct {
+	Limit  int
+	Offset int
+	After  *PRCursor
+}
+
+// SchemaMigration is a migration recorded in schema_migrations
+type SchemaMigration struct {
+	Version   string     `json:"version"`
+	AppliedAt *Timestamp `json:"applied_at"`
+}
+
+// SchemaInfo is the effective schema version with the applied migrations
+type SchemaInfo struct {
+	Version    string            `json:"version"`
+	Migrations []SchemaMigration `json:"migrations"`
+}
+
+// ReconcileRequest is the /admin/reconcile request, an empty body only reports
+type ReconcileRequest struct {
+	// Fix recreates the missing teams of orphaned users and removes reviewer
+	// rows of missing users. PRs of missing authors are only reported
+	Fix bool `json:"fix"`
+}
+
+// ReconcileReport lists rows whose references no longer resolve, and what
+// /admin/reconcile fixed
+type ReconcileReport struct {
+	OrphanedUsers     []OrphanedUser     `json:"orphaned_users"`
+	OrphanedPRs       []OrphanedPR       `json:"orphaned_prs"`
+	OrphanedReviewers []OrphanedReviewer `json:"orphaned_reviewers"`
+
+	Fixed            bool     `json:"fixed"`
+	TeamsCreated     []string `json:"teams_created,omitempty"`
+	ReviewersRemoved int64    `json:"reviewers_removed,omitempty"`
+}
+
+// OrphanedUser is a user whose team doesn't exist
+type OrphanedUser struct {
+	UserID   string `json:"user_id"`
+	TeamName string `json:"team_name"`
+}
+
+// OrphanedPR is a PR whose author doesn't exist
+type OrphanedPR struct {
+	PullRequestID string `json:"pull_request_id"`
+	AuthorID      string `json:"author_id"`
+}
+
+// OrphanedReviewer is a reviewer row of a user that doesn't exist
+type OrphanedReviewer struct {
+	PullRequestID string `json:"pull_request_id"`
+	ReviewerID    string `json:"reviewer_id"`
+}
+
+// Audit event types
+const (
+	EventPRCreated          = "pr_created"
+	EventPRMerged           = "pr_merged"
+	EventPRMarkedReady      = "pr_marked_ready"
+	EventReviewerReassign   = "reviewer_reassigned"
+	EventReviewersAssigned  = "reviewers_assigned"
+	EventReviewerUnassigned = "reviewer_unassigned"
+	EventUserActiveChanged  = "user_active_changed"
+	EventReconciled         = "reconciled"
+)
+
+// AuditEvent records a mutating action and who performed it
+// DigestItem is a notification waiting for the next digest of its team
+type DigestItem struct {
+	TeamName   string
+	WebhookURL string
+	Payload    []byte
+}
+
+// Kinds of assignment decisions
+const (
+	DecisionAssign   = "assign"
+	DecisionReassign = "reassign"
+)
+
+// AssignmentDecision records what reviewer selection was given and what it
+// chose. PoolSize counts the candidates left to choose from; Fallback names
+// the RELAXED_REASSIGN/DROP_UNREPLACEABLE_REVIEWER outcome when nobody new
+// could be picked
+type AssignmentDecision struct {
+	ID             int64      `json:"id"`
+	PullRequestID  string     `json:"pull_request_id"`
+	Kind           string     `json:"kind"`
+	Strategy       string     `json:"strategy"`
+	PoolSize       int        `json:"candidate_pool_size"`
+	Selected       []string   `json:"selected"`
+	ReplacedUserID string     `json:"replaced_user_id,omitempty"`
+	Fallback       string     `json:"fallback,omitempty"`
+	ActorID        string     `json:"actor_id"`
+	CreatedAt      *Timestamp `json:"created_at,omitempty"`
+}
+
+type DecisionsResponse struct {
+	Decisions []AssignmentDecision `json:"decisions"`
+}
+
+// FeatureFlag is the effective value of a boolean setting, source being
+// env, file or default
+type FeatureFlag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+type FlagsResponse struct {
+	Flags []FeatureFlag `json:"flags"`
+}
+
+type AuditEvent struct {
+	ID            int64             `json:"id"`
+	Type          string            `json:"event_type"`
+	PullRequestID string            `json:"pull_request_id,omitempty"`
+	UserID        string            `json:"user_id,omitempty"`
+	ActorID       string            `json:"actor_id"`
+	Details       map[string]string `json:"details,omitempty"`
+	CreatedAt     *Timestamp        `json:"created_at"`
+}
+
+// Reassignment is one reviewer replacement recorded in the audit log
+type Reassignment struct {
+	OldUserID    string     `json:"old_user_id"`
+	NewUserID    string     `json:"new_user_id"`
+	ActorID      string     `json:"actor_id"`
+	ReassignedAt *Timestamp `json:"reassigned_at"`
+}
+
+type PRReassignmentsResponse struct {
+	PullRequestID string         `json:"pull_request_id"`
+	Reassignments []Reassignment `json:"reassignments"`
+}
+
+type EventsResponse struct {
+	Events []AuditEvent `json:"events"`
+}
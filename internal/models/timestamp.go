@@ -0,0 +1,43 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Layouts for the supported timestamp precisions
+const (
+	LayoutNano   = time.RFC3339Nano
+	LayoutMilli  = "2006-01-02T15:04:05.000Z07:00"
+	LayoutSecond = time.RFC3339
+)
+
+var timestampLayout = LayoutNano
+
+// SetTimestampLayout sets the layout used when serializing every Timestamp
+func SetTimestampLayout(layout string) {
+	timestampLayout = layout
+}
+
+// Timestamp is a time.Time serialized with the configured precision
+type Timestamp struct {
+	time.Time
+}
+
+func NewTimestamp(t time.Time) *Timestamp {
+	return &Timestamp{Time: t}
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Format(timestampLayout))
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	return t.Time.UnmarshalJSON(data)
+}
+
+// Value lets Timestamp be passed directly as a query argument
+func (t Timestamp) Value() (driver.Value, error) {
+	return t.Time, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampLayout(t *testing.T) {
+	t.Cleanup(func() { SetTimestampLayout(LayoutNano) })
+	created := time.Date(2025, 1, 10, 9, 15, 30, 123456789, time.UTC)
+	pr := PullRequest{PullRequestID: "pr-1001", CreatedAt: NewTimestamp(created)}
+
+	tests := []struct {
+		layout, want string
+	}{
+		{LayoutNano, "2025-01-10T09:15:30.123456789Z"},
+		{LayoutMilli, "2025-01-10T09:15:30.123Z"},
+		{LayoutSecond, "2025-01-10T09:15:30Z"},
+	}
+	for _, tt := range tests {
+		SetTimestampLayout(tt.layout)
+		data, err := json.Marshal(pr)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var got struct {
+			CreatedAt string `json:"created_at"`
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.CreatedAt != tt.want {
+			t.Errorf("layout %s: created_at = %q, want %q", tt.layout, got.CreatedAt, tt.want)
+		}
+	}
+}
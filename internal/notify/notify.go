@@ -0,0 +1,194 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"review-service/internal/models"
+)
+
+// Event types sent to webhooks
+const (
+	EventReviewersAssigned = "reviewers_assigned"
+	EventReviewerReplaced  = "reviewer_replaced"
+	EventPRMerged          = "pr_merged"
+	EventDigest            = "digest"
+)
+
+// Event is the JSON payload posted to a webhook
+type Event struct {
+	Type          string    `json:"event"`
+	PullRequestID string    `json:"pull_request_id"`
+	AuthorID      string    `json:"author_id"`
+	Reviewers     []string  `json:"reviewers,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Digest is the JSON payload summarizing a team's assignment and replacement
+// events since the previous digest
+type Digest struct {
+	Type     string         `json:"event"`
+	TeamName string         `json:"team_name"`
+	Counts   map[string]int `json:"counts"`
+	Events   []Event        `json:"events"`
+	SentAt   time.Time      `json:"sent_at"`
+}
+
+// WebhookResolver looks up the webhook configured for the author's team and
+// which reviewers want to be notified through it
+type WebhookResolver interface {
+	GetAuthorWebhookURL(ctx context.Context, authorID string) (string, error)
+	GetNotifiableUsers(ctx context.Context, userIDs []string, channel string) ([]string, error)
+}
+
+// DigestStore queues assignment events until the next digest is flushed
+type DigestStore interface {
+	AddDigestItem(ctx context.Context, authorID, webhookURL string, payload []byte) error
+	TakeDigestItems(ctx context.Context) ([]models.DigestItem, error)
+}
+
+// Dispatcher posts events to the author's team webhook, falling back to the
+// global webhook when the team has none
+type Dispatcher struct {
+	resolver  WebhookResolver
+	digest    DigestStore
+	globalURL string
+	client    *http.Client
+}
+
+// NewDispatcher returns a dispatcher queueing assignment events in digest for
+// FlushDigest, or sending them right away when digest is nil
+func NewDispatcher(resolver WebhookResolver, digest DigestStore, globalURL string) *Dispatcher {
+	return &Dispatcher{
+		resolver:  resolver,
+		digest:    digest,
+		globalURL: globalURL,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Dispatch resolves the webhook URL and delivers the event in the background.
+// Assignment events only list reviewers who didn't opt out and are dropped
+// when none are left. Delivery failures are logged and never fail the
+// request that caused them
+func (d *Dispatcher) Dispatch(ctx context.Context, event Event) {
+	if isAssignment(event.Type) {
+		reviewers, err := d.resolver.GetNotifiableUsers(ctx, event.Reviewers, models.ChannelWebhook)
+		if err != nil {
+			log.Printf("Notification prefs for %s on %s not resolved: %v", event.Type, event.PullRequestID, err)
+			return
+		}
+		if len(reviewers) == 0 {
+			return
+		}
+		event.Reviewers = reviewers
+	}
+
+	url, err := d.resolveURL(ctx, event.AuthorID)
+	if err != nil {
+		log.Printf("Webhook for %s on %s not resolved: %v", event.Type, event.PullRequestID, err)
+		return
+	}
+	if url == "" {
+		return
+	}
+
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	if d.digest != nil && isAssignment(event.Type) {
+		payload, err := json.Marshal(event)
+		if err == nil {
+			err = d.digest.AddDigestItem(ctx, event.AuthorID, url, payload)
+		}
+		if err != nil {
+			log.Printf("Digest item %s for %s not queued: %v", event.Type, event.PullRequestID, err)
+		}
+		return
+	}
+
+	go func() {
+		if err := d.send(url, event); err != nil {
+			log.Printf("Webhook %s for %s failed: %v", event.Type, event.PullRequestID, err)
+		}
+	}()
+}
+
+// FlushDigest sends every queued event as one digest per team and webhook.
+// Items are taken off the queue first, so a failed delivery is logged and
+// not retried, the same as for single events
+func (d *Dispatcher) FlushDigest(ctx context.Context) (int, error) {
+	items, err := d.digest.TakeDigestItems(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	type target struct{ team, url string }
+	var order []target
+	digests := make(map[target]*Digest)
+	for _, item := range items {
+		var event Event
+		if err := json.Unmarshal(item.Payload, &event); err != nil {
+			log.Printf("Digest item for team %s dropped: %v", item.TeamName, err)
+			continue
+		}
+
+		key := target{team: item.TeamName, url: item.WebhookURL}
+		digest, ok := digests[key]
+		if !ok {
+			digest = &Digest{Type: EventDigest, TeamName: item.TeamName, Counts: map[string]int{}}
+			digests[key] = digest
+			order = append(order, key)
+		}
+		digest.Counts[event.Type]++
+		digest.Events = append(digest.Events, event)
+	}
+
+	for _, key := range order {
+		digest := digests[key]
+		digest.SentAt = time.Now()
+		if err := d.send(key.url, digest); err != nil {
+			log.Printf("Webhook digest for team %s failed: %v", key.team, err)
+		}
+	}
+	return len(order), nil
+}
+
+func isAssignment(eventType string) bool {
+	return eventType == EventReviewersAssigned || eventType == EventReviewerReplaced
+}
+
+func (d *Dispatcher) resolveURL(ctx context.Context, authorID string) (string, error) {
+	url, err := d.resolver.GetAuthorWebhookURL(ctx, authorID)
+	if err != nil {
+		return "", err
+	}
+	if url != "" {
+		return url, nil
+	}
+	return d.globalURL, nil
+}
+
+func (d *Dispatcher) send(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"review-service/internal/models"
+)
+
+// fakeResolver gives every author the same webhook and lets every reviewer
+// be notified
+type fakeResolver struct {
+	url string
+}
+
+func (f *fakeResolver) GetAuthorWebhookURL(ctx context.Context, authorID string) (string, error) {
+	return f.url, nil
+}
+
+func (f *fakeResolver) GetNotifiableUsers(ctx context.Context, userIDs []string, channel string) ([]string, error) {
+	return userIDs, nil
+}
+
+// fakeDigestStore queues items in memory, teams looked up by author
+type fakeDigestStore struct {
+	teams map[string]string
+	items []models.DigestItem
+}
+
+func (f *fakeDigestStore) AddDigestItem(ctx context.Context, authorID, webhookURL string, payload []byte) error {
+	f.items = append(f.items, models.DigestItem{TeamName: f.teams[authorID], WebhookURL: webhookURL, Payload: payload})
+	return nil
+}
+
+func (f *fakeDigestStore) TakeDigestItems(ctx context.Context) ([]models.DigestItem, error) {
+	items := f.items
+	f.items = nil
+	return items, nil
+}
+
+func TestFlushDigest(t *testing.T) {
+	var mu sync.Mutex
+	var received []Digest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var digest Digest
+		if err := json.NewDecoder(r.Body).Decode(&digest); err != nil {
+			t.Errorf("decode digest: %v", err)
+		}
+		mu.Lock()
+		received = append(received, digest)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	store := &fakeDigestStore{teams: map[string]string{"alice": "backend", "bob": "backend"}}
+	dispatcher := NewDispatcher(&fakeResolver{url: server.URL}, store, "")
+	ctx := context.Background()
+
+	dispatcher.Dispatch(ctx, Event{Type: EventReviewersAssigned, PullRequestID: "pr-1", AuthorID: "alice", Reviewers: []string{"r1", "r2"}})
+	dispatcher.Dispatch(ctx, Event{Type: EventReviewersAssigned, PullRequestID: "pr-2", AuthorID: "bob", Reviewers: []string{"r1"}})
+	dispatcher.Dispatch(ctx, Event{Type: EventReviewerReplaced, PullRequestID: "pr-1", AuthorID: "alice", Reviewers: []string{"r3"}})
+	if len(store.items) != 3 {
+		t.Fatalf("queued %d items, want 3", len(store.items))
+	}
+
+	sent, err := dispatcher.FlushDigest(ctx)
+	if err != nil {
+		t.Fatalf("FlushDigest: %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("sent %d digests, want 1", sent)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("webhook got %d payloads, want 1", len(received))
+	}
+	digest := received[0]
+	if digest.Type != EventDigest || digest.TeamName != "backend" {
+		t.Errorf("digest = %s for %q, want %s for backend", digest.Type, digest.TeamName, EventDigest)
+	}
+	if digest.Counts[EventReviewersAssigned] != 2 || digest.Counts[EventReviewerReplaced] != 1 {
+		t.Errorf("counts = %v, want 2 assigned and 1 replaced", digest.Counts)
+	}
+	var prs []string
+	for _, event := range digest.Events {
+		prs = append(prs, event.PullRequestID)
+	}
+	if !slices.Equal(prs, []string{"pr-1", "pr-2", "pr-1"}) {
+		t.Errorf("digest events for %v, want pr-1, pr-2, pr-1 in order", prs)
+	}
+
+	// The queue was emptied, so the next flush has nothing to send
+	if sent, err := dispatcher.FlushDigest(ctx); err != nil || sent != 0 {
+		t.Errorf("second FlushDigest = %d, %v, want 0", sent, err)
+	}
+}
@@ -0,0 +1,377 @@
+// Package openapi checks requests against the served OpenAPI spec. It covers
+// the part of OpenAPI 3.0 the spec uses: query, header and path parameters
+// and JSON request bodies, with schemas built from $ref, type, nullable,
+// enum, required, properties, additionalProperties, items, minimum, maximum,
+// minLength, maxLength, minItems, maxItems and pattern
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Violation is a part of a request that doesn't match the spec. Codes follow
+// the binding errors: REQUIRED, TYPE, ENUM, MIN, MAX, PATTERN
+type Violation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// Request holds what Validate checks of an HTTP request
+type Request struct {
+	Method string
+
+	// Route is the router pattern, e.g. /pullRequest/:id/reviewers
+	Route      string
+	PathParams map[string]string
+	Query      url.Values
+	Header     http.Header
+
+	// Body is the decoded JSON body, HasBody is false when there is none
+	Body    any
+	HasBody bool
+}
+
+// Validator checks requests against the operations of a spec
+type Validator struct {
+	components map[string]any
+	operations map[string]map[string]any
+	patterns   map[string]*regexp.Regexp
+}
+
+// Load reads the spec at path
+func Load(path string) (*Validator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec map[string]any
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	v := &Validator{
+		components: asMap(spec["components"]),
+		operations: make(map[string]map[string]any),
+		patterns:   make(map[string]*regexp.Regexp),
+	}
+	for path, item := range asMap(spec["paths"]) {
+		item := asMap(item)
+		shared := asSlice(item["parameters"])
+		for method, operation := range item {
+			operation := asMap(operation)
+			if method == "parameters" || operation == nil {
+				continue
+			}
+			// Operation parameters come last, so they override shared ones
+			operation["parameters"] = append(slices.Clone(shared), asSlice(operation["parameters"])...)
+			v.operations[operationKey(method, routePattern(path))] = operation
+		}
+	}
+
+	if err := v.compilePatterns(spec); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate returns the request's violations, none for routes the spec
+// doesn't describe
+func (v *Validator) Validate(req Request) []Violation {
+	operation, ok := v.operations[operationKey(req.Method, req.Route)]
+	if !ok {
+		return nil
+	}
+
+	var violations []Violation
+	for _, param := range asSlice(operation["parameters"]) {
+		param := v.resolve(asMap(param))
+		name, _ := param["name"].(string)
+
+		var raw string
+		var present bool
+		switch param["in"] {
+		case "query":
+			present = req.Query.Has(name)
+			raw = req.Query.Get(name)
+		case "header":
+			raw = req.Header.Get(name)
+			present = raw != ""
+		case "path":
+			raw, present = req.PathParams[name]
+		default:
+			continue
+		}
+
+		if !present {
+			if param["required"] == true {
+				violations = append(violations, Violation{Field: name, Code: "REQUIRED", Message: name + " is required"})
+			}
+			continue
+		}
+		v.checkParam(asMap(param["schema"]), name, raw, &violations)
+	}
+
+	body := asMap(operation["requestBody"])
+	if body == nil {
+		return violations
+	}
+	if !req.HasBody {
+		if body["required"] == true {
+			violations = append(violations, Violation{Field: "body", Code: "REQUIRED", Message: "request body is required"})
+		}
+		return violations
+	}
+	schema := asMap(asMap(asMap(body["content"])["application/json"])["schema"])
+	if schema != nil {
+		v.checkValue(schema, "", req.Body, &violations)
+	}
+	return violations
+}
+
+// checkParam converts the parameter's text to the schema type and checks it
+func (v *Validator) checkParam(schema map[string]any, name, raw string, violations *[]Violation) {
+	schema = v.resolve(schema)
+
+	var value any = raw
+	switch schema["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			*violations = append(*violations, typeViolation(name, schema["type"]))
+			return
+		}
+		value = json.Number(raw)
+	case "boolean":
+		if raw != "true" && raw != "false" {
+			*violations = append(*violations, typeViolation(name, "boolean"))
+			return
+		}
+		value = raw == "true"
+	}
+	v.checkValue(schema, name, value, violations)
+}
+
+// checkValue checks a decoded JSON value against the schema, field being
+// its path in the body, e.g. members[0].user_id
+func (v *Validator) checkValue(schema map[string]any, field string, value any, violations *[]Violation) {
+	schema = v.resolve(schema)
+	if value == nil {
+		if schema["nullable"] != true && schema["type"] != nil {
+			*violations = append(*violations, Violation{Field: fieldName(field), Code: "TYPE", Message: fieldName(field) + " must not be null"})
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			*violations = append(*violations, typeViolation(field, "object"))
+			return
+		}
+		v.checkObject(schema, field, obj, violations)
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			*violations = append(*violations, typeViolation(field, "array"))
+			return
+		}
+		checkBounds(schema, field, float64(len(items)), "minItems", "maxItems", " items", violations)
+		for i, item := range items {
+			v.checkValue(asMap(schema["items"]), fmt.Sprintf("%s[%d]", field, i), item, violations)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			*violations = append(*violations, typeViolation(field, "string"))
+			return
+		}
+		checkBounds(schema, field, float64(utf8.RuneCountInString(s)), "minLength", "maxLength", " characters", violations)
+		if pattern, ok := schema["pattern"].(string); ok && !v.patterns[pattern].MatchString(s) {
+			*violations = append(*violations, Violation{Field: fieldName(field), Code: "PATTERN", Message: fieldName(field) + " must match " + pattern})
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			*violations = append(*violations, typeViolation(field, schema["type"]))
+			return
+		}
+		f, err := n.Float64()
+		if err != nil || schema["type"] == "integer" && strings.ContainsAny(n.String(), ".eE") {
+			*violations = append(*violations, typeViolation(field, schema["type"]))
+			return
+		}
+		checkBounds(schema, field, f, "minimum", "maximum", "", violations)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*violations = append(*violations, typeViolation(field, "boolean"))
+			return
+		}
+	}
+
+	if enum := asSlice(schema["enum"]); enum != nil {
+		text := fmt.Sprint(value)
+		if !slices.ContainsFunc(enum, func(allowed any) bool { return fmt.Sprint(allowed) == text }) {
+			*violations = append(*violations, Violation{Field: fieldName(field), Code: "ENUM", Message: fmt.Sprintf("%s must be one of %v", fieldName(field), enum)})
+		}
+	}
+}
+
+func (v *Validator) checkObject(schema map[string]any, field string, obj map[string]any, violations *[]Violation) {
+	properties := asMap(schema["properties"])
+	for _, name := range asSlice(schema["required"]) {
+		name, _ := name.(string)
+		if _, ok := obj[name]; ok {
+			continue
+		}
+		// Server-set fields are listed as required for responses only
+		if v.resolve(asMap(properties[name]))["readOnly"] == true {
+			continue
+		}
+		path := joinField(field, name)
+		*violations = append(*violations, Violation{Field: path, Code: "REQUIRED", Message: path + " is required"})
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	extra := asMap(schema["additionalProperties"])
+	for _, name := range names {
+		property, ok := properties[name]
+		if !ok {
+			if extra == nil {
+				continue
+			}
+			property = extra
+		}
+		v.checkValue(asMap(property), joinField(field, name), obj[name], violations)
+	}
+}
+
+// checkBounds checks size against the schema's min and max keywords
+func checkBounds(schema map[string]any, field string, size float64, minKey, maxKey, unit string, violations *[]Violation) {
+	if limit, ok := toFloat(schema[minKey]); ok && size < limit {
+		*violations = append(*violations, Violation{Field: fieldName(field), Code: "MIN", Message: fmt.Sprintf("%s must be at least %v%s", fieldName(field), limit, unit)})
+	}
+	if limit, ok := toFloat(schema[maxKey]); ok && size > limit {
+		*violations = append(*violations, Violation{Field: fieldName(field), Code: "MAX", Message: fmt.Sprintf("%s must be at most %v%s", fieldName(field), limit, unit)})
+	}
+}
+
+// resolve follows a local $ref such as #/components/schemas/User
+func (v *Validator) resolve(node map[string]any) map[string]any {
+	for node != nil {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		node = v.components
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/components/"), "/") {
+			node = asMap(node[part])
+		}
+	}
+	return nil
+}
+
+// compilePatterns compiles every pattern of the spec up front, so a bad one
+// fails Load instead of a request
+func (v *Validator) compilePatterns(node any) error {
+	switch node := node.(type) {
+	case map[string]any:
+		for key, value := range node {
+			if pattern, ok := value.(string); ok && key == "pattern" {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+				}
+				v.patterns[pattern] = re
+				continue
+			}
+			if err := v.compilePatterns(value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range node {
+			if err := v.compilePatterns(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func typeViolation(field string, typ any) Violation {
+	return Violation{Field: fieldName(field), Code: "TYPE", Message: fmt.Sprintf("%s must be %s", fieldName(field), typ)}
+}
+
+// fieldName names the body itself when field is the empty root path
+func fieldName(field string) string {
+	if field == "" {
+		return "body"
+	}
+	return field
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// routePattern turns /pullRequest/{id}/reviewers into the router's
+// /pullRequest/:id/reviewers
+func routePattern(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = ":" + part[1:len(part)-1]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func operationKey(method, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
+
+func asMap(node any) map[string]any {
+	m, _ := node.(map[string]any)
+	return m
+}
+
+func asSlice(node any) []any {
+	s, _ := node.([]any)
+	return s
+}
+
+func toFloat(node any) (float64, bool) {
+	switch n := node.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
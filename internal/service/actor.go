@@ -0,0 +1,21 @@
+package service
+
+import "context"
+
+// SystemActor is recorded for changes made by background jobs
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor returns a context attributing the changes made with it to actorID
+func WithActor(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+// actorFrom returns the actor stored by WithActor, SystemActor if none
+func actorFrom(ctx context.Context) string {
+	if actorID, ok := ctx.Value(actorKey{}).(string); ok && actorID != "" {
+		return actorID
+	}
+	return SystemActor
+}
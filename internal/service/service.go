@@ -1,260 +1,2553 @@
-package service
-
-import (
-	"context"
-	"errors"
-	"math/rand"
-	"review-service/internal/database"
-	"review-service/internal/models"
-	"time"
-)
-
-type Service struct {
-	db *database.DB
-}
-
-func NewService(db *database.DB) *Service {
-	return &Service{db: db}
-}
-
-// Team methods
-func (s *Service) CreateTeam(ctx context.Context, req models.CreateTeamRequest) (*models.Team, error) {
-	// Check if team already exists
-	existingTeam, _ := s.db.GetTeamByName(ctx, req.TeamName)
-	if existingTeam != nil {
-		return nil, ErrTeamExists
-	}
-
-	// Create team
-	team := &models.Team{
-		TeamName: req.TeamName,
-		Members:  req.Members,
-	}
-
-	if err := s.db.CreateTeam(ctx, team); err != nil {
-		return nil, err
-	}
-
-	// Create/update users
-	for _, member := range req.Members {
-		user := &models.User{
-			UserID:   member.UserID,
-			Username: member.Username,
-			TeamName: req.TeamName,
-			IsActive: member.IsActive,
-		}
-		if err := s.db.CreateOrUpdateUser(ctx, user); err != nil {
-			return nil, err
-		}
-	}
-
-	return team, nil
-}
-
-func (s *Service) GetTeam(ctx context.Context, teamName string) (*models.Team, error) {
-	team, err := s.db.GetTeamByName(ctx, teamName)
-	if err != nil {
-		return nil, ErrTeamNotFound
-	}
-	return team, nil
-}
-
-// User methods
-func (s *Service) SetUserActive(ctx context.Context, req models.SetUserActiveRequest) (*models.User, error) {
-	user, err := s.db.GetUserByID(ctx, req.UserID)
-	if err != nil {
-		return nil, ErrUserNotFound
-	}
-
-	user.IsActive = req.IsActive
-	if err := s.db.UpdateUser(ctx, user); err != nil {
-		return nil, err
-	}
-
-	return user, nil
-}
-
-// PR methods
-func (s *Service) CreatePR(ctx context.Context, req models.CreatePRRequest) (*models.PullRequest, error) {
-	// Check if PR already exists
-	existingPR, _ := s.db.GetPRByID(ctx, req.PullRequestID)
-	if existingPR != nil {
-		return nil, ErrPRExists
-	}
-
-	// Get author
-	author, err := s.db.GetUserByID(ctx, req.AuthorID)
-	if err != nil {
-		return nil, ErrUserNotFound
-	}
-
-	// Get team members for reviewers
-	teamMembers, err := s.db.GetActiveUsersByTeam(ctx, author.TeamName, author.UserID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Select up to 2 random reviewers
-	var reviewers []string
-	if len(teamMembers) > 0 {
-		rand.Shuffle(len(teamMembers), func(i, j int) {
-			teamMembers[i], teamMembers[j] = teamMembers[j], teamMembers[i]
-		})
-
-		count := min(2, len(teamMembers))
-		for i := 0; i < count; i++ {
-			reviewers = append(reviewers, teamMembers[i].UserID)
-		}
-	}
-
-	now := time.Now()
-	pr := &models.PullRequest{
-		PullRequestID:     req.PullRequestID,
-		PullRequestName:   req.PullRequestName,
-		AuthorID:          req.AuthorID,
-		Status:            models.PRStatusOpen,
-		AssignedReviewers: reviewers,
-		CreatedAt:         &now,
-	}
-
-	if err := s.db.CreatePR(ctx, pr); err != nil {
-		return nil, err
-	}
-
-	return pr, nil
-}
-
-func (s *Service) MergePR(ctx context.Context, prID string) (*models.PullRequest, error) {
-	pr, err := s.db.GetPRByID(ctx, prID)
-	if err != nil {
-		return nil, ErrPRNotFound
-	}
-
-	// Idempotent - if already merged, return current state
-	if pr.Status == models.PRStatusMerged {
-		return pr, nil
-	}
-
-	now := time.Now()
-	pr.Status = models.PRStatusMerged
-	pr.MergedAt = &now
-
-	if err := s.db.UpdatePR(ctx, pr); err != nil {
-		return nil, err
-	}
-
-	return pr, nil
-}
-
-func (s *Service) ReassignReviewer(ctx context.Context, req models.ReassignReviewerRequest) (*models.PullRequest, string, error) {
-	pr, err := s.db.GetPRByID(ctx, req.PullRequestID)
-	if err != nil {
-		return nil, "", ErrPRNotFound
-	}
-
-	if pr.Status == models.PRStatusMerged {
-		return nil, "", ErrPRMerged
-	}
-
-	// Check if old reviewer is assigned
-	found := false
-	for _, reviewer := range pr.AssignedReviewers {
-		if reviewer == req.OldUserID {
-			found = true
-			break
-		}
-	}
-	if !found {
-		return nil, "", ErrReviewerNotAssigned
-	}
-
-	// Get old reviewer's team
-	oldReviewer, err := s.db.GetUserByID(ctx, req.OldUserID)
-	if err != nil {
-		return nil, "", ErrUserNotFound
-	}
-
-	// Get available replacement candidates
-	candidates, err := s.db.GetActiveUsersByTeam(ctx, oldReviewer.TeamName, pr.AuthorID)
-	if err != nil {
-		return nil, "", err
-	}
-
-	// Filter out current reviewers and old reviewer
-	var available []models.User
-	for _, candidate := range candidates {
-		isCurrent := false
-		for _, reviewer := range pr.AssignedReviewers {
-			if candidate.UserID == reviewer {
-				isCurrent = true
-				break
-			}
-		}
-		if !isCurrent && candidate.UserID != req.OldUserID {
-			available = append(available, candidate)
-		}
-	}
-
-	if len(available) == 0 {
-		return nil, "", ErrNoCandidate
-	}
-
-	// Select random replacement
-	newReviewer := available[rand.Intn(len(available))]
-
-	// Replace reviewer
-	newReviewers := make([]string, len(pr.AssignedReviewers))
-	for i, reviewer := range pr.AssignedReviewers {
-		if reviewer == req.OldUserID {
-			newReviewers[i] = newReviewer.UserID
-		} else {
-			newReviewers[i] = reviewer
-		}
-	}
-	pr.AssignedReviewers = newReviewers
-
-	if err := s.db.UpdatePRReviewers(ctx, pr.PullRequestID, newReviewers); err != nil {
-		return nil, "", err
-	}
-
-	return pr, newReviewer.UserID, nil
-}
-
-func (s *Service) GetUserPRs(ctx context.Context, userID string) (*models.UserPRsResponse, error) {
-	prs, err := s.db.GetPRsByReviewer(ctx, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert to short format
-	var shortPRs []models.PullRequestShort
-	for _, pr := range prs {
-		shortPRs = append(shortPRs, models.PullRequestShort{
-			PullRequestID:   pr.PullRequestID,
-			PullRequestName: pr.PullRequestName,
-			AuthorID:        pr.AuthorID,
-			Status:          pr.Status,
-		})
-	}
-
-	return &models.UserPRsResponse{
-		UserID:       userID,
-		PullRequests: shortPRs,
-	}, nil
-}
-
-func (s *Service) CheckHealth(ctx context.Context) error {
-	return s.db.HealthCheck(ctx)
-}
-
-// Errors matching OpenAPI spec
-var (
-	ErrTeamExists          = errors.New("TEAM_EXISTS")
-	ErrTeamNotFound        = errors.New("NOT_FOUND")
-	ErrUserNotFound        = errors.New("NOT_FOUND")
-	ErrPRExists            = errors.New("PR_EXISTS")
-	ErrPRNotFound          = errors.New("NOT_FOUND")
-	ErrPRMerged            = errors.New("PR_MERGED")
-	ErrReviewerNotAssigned = errors.New("NOT_ASSIGNED")
-	ErrNoCandidate         = errors.New("NO_CANDIDATE")
-)
+package service
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math/rand"
+	"review-service/internal/config"
+	"review-service/internal/database"
+	"review-service/internal/models"
+	"review-service/internal/notify"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	db       Store
+	cfg      *config.Config
+	notifier *notify.Dispatcher
+}
+
+func NewService(db Store, cfg *config.Config) *Service {
+	var digest notify.DigestStore
+	if cfg.NotificationDigestInterval > 0 {
+		digest = db
+	}
+	return &Service{db: db, cfg: cfg, notifier: notify.NewDispatcher(db, digest, cfg.WebhookURL)}
+}
+
+// Team methods
+func (s *Service) CreateTeam(ctx context.Context, req models.CreateTeamRequest) (*models.Team, *models.TeamMemberSummary, error) {
+	// Check if team already exists
+	existingTeam, _ := s.db.GetTeamByName(ctx, req.TeamName)
+	if existingTeam != nil {
+		return nil, nil, ErrTeamExists
+	}
+
+	// Create team
+	team := &models.Team{
+		TeamName:          req.TeamName,
+		Members:           s.withDefaultActive(req.Members),
+		CreatedBy:         req.CreatedBy,
+		AssignmentSeed:    req.AssignmentSeed,
+		ReviewWindowStart: req.ReviewWindowStart,
+		ReviewWindowEnd:   req.ReviewWindowEnd,
+		WebhookURL:        req.WebhookURL,
+		MoveMembers:       req.Move,
+	}
+
+	// Create team with its users
+	summary, err := s.db.CreateTeam(ctx, team)
+	if err != nil {
+		if errors.Is(err, database.ErrUserInAnotherTeam) {
+			return nil, nil, ErrUserInAnotherTeam
+		}
+		return nil, nil, err
+	}
+
+	// Respond with what was stored, so the response matches a later /team/get
+	stored, err := s.db.GetTeamByName(ctx, req.TeamName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stored, summary, nil
+}
+
+func (s *Service) CreateTeams(ctx context.Context, req models.CreateTeamsBatchRequest) ([]models.TeamBatchResult, error) {
+	if len(req.Teams) > s.cfg.MaxTeamBatch {
+		return nil, ErrBatchTooLarge
+	}
+
+	teams := make([]models.Team, 0, len(req.Teams))
+	for _, teamReq := range req.Teams {
+		teams = append(teams, models.Team{
+			TeamName:          teamReq.TeamName,
+			Members:           s.withDefaultActive(teamReq.Members),
+			CreatedBy:         teamReq.CreatedBy,
+			AssignmentSeed:    teamReq.AssignmentSeed,
+			ReviewWindowStart: teamReq.ReviewWindowStart,
+			ReviewWindowEnd:   teamReq.ReviewWindowEnd,
+			WebhookURL:        teamReq.WebhookURL,
+			MoveMembers:       teamReq.Move,
+		})
+	}
+
+	return s.db.CreateTeams(ctx, teams)
+}
+
+// withDefaultActive copies the members, setting DEFAULT_MEMBER_ACTIVE on
+// those the request left without is_active
+func (s *Service) withDefaultActive(members []models.TeamMember) []models.TeamMember {
+	result := make([]models.TeamMember, len(members))
+	for i, member := range members {
+		if member.IsActive == nil {
+			member.IsActive = &s.cfg.DefaultMemberActive
+		}
+		result[i] = member
+	}
+	return result
+}
+
+func (s *Service) GetTeam(ctx context.Context, teamName string) (*models.Team, error) {
+	team, err := s.db.GetTeamByName(ctx, teamName)
+	if err != nil {
+		return nil, ErrTeamNotFound
+	}
+	return team, nil
+}
+
+// maxTeamSuggestions bounds the near matches returned for a missed team
+const maxTeamSuggestions = 5
+
+// SuggestTeams returns existing team names close to a missed one, nil unless
+// TEAM_SUGGESTIONS is on since it reveals other teams' names
+func (s *Service) SuggestTeams(ctx context.Context, teamName string) ([]string, error) {
+	if !s.cfg.TeamSuggestions {
+		return nil, nil
+	}
+	return s.db.SuggestTeamNames(ctx, teamName, maxTeamSuggestions)
+}
+
+func (s *Service) GetTeamReviewerLoad(ctx context.Context, teamName string) (*models.TeamReviewerLoad, error) {
+	exists, err := s.db.TeamExists(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrTeamNotFound
+	}
+
+	loads, err := s.db.GetTeamReviewerLoad(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TeamReviewerLoad{
+		TeamName: teamName,
+		Members:  models.EmptyIfNil(loads),
+	}, nil
+}
+
+// GetReviewerDistribution returns how many of the team's PRs have 0, 1, 2...
+// reviewers, optionally only PRs with the given status
+func (s *Service) GetReviewerDistribution(ctx context.Context, teamName, status string) (*models.ReviewerDistribution, error) {
+	if status != "" && !models.PullRequestStatus(status).IsValid() {
+		return nil, ErrInvalidStatus
+	}
+
+	exists, err := s.db.TeamExists(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrTeamNotFound
+	}
+
+	buckets, err := s.db.GetReviewerDistribution(ctx, teamName, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ReviewerDistribution{
+		TeamName: teamName,
+		Status:   status,
+		Buckets:  models.EmptyIfNil(buckets),
+	}, nil
+}
+
+// GetResponseTimes returns how fast reviewers approve after assignment,
+// slowest first, for one team or everyone when teamName is empty
+func (s *Service) GetResponseTimes(ctx context.Context, teamName string) (*models.ResponseTimesResponse, error) {
+	if teamName != "" {
+		exists, err := s.db.TeamExists(ctx, teamName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrTeamNotFound
+		}
+	}
+
+	times, err := s.db.GetResponseTimes(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ResponseTimesResponse{
+		TeamName:  teamName,
+		Reviewers: models.EmptyIfNil(times),
+	}, nil
+}
+
+// GetLabels returns the labels in use with their PR counts, for the team's
+// authors only when teamName is set
+func (s *Service) GetLabels(ctx context.Context, teamName string) (*models.LabelsResponse, error) {
+	if teamName != "" {
+		exists, err := s.db.TeamExists(ctx, teamName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrTeamNotFound
+		}
+	}
+
+	labels, err := s.db.GetLabels(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LabelsResponse{
+		TeamName: teamName,
+		Labels:   models.EmptyIfNil(labels),
+	}, nil
+}
+
+// GetMergeThroughput returns merged PR counts per day or week over the window
+// ending now
+func (s *Service) GetMergeThroughput(ctx context.Context, bucket string, window time.Duration, windowText string) (*models.MergeThroughput, error) {
+	if bucket != models.BucketDay && bucket != models.BucketWeek {
+		return nil, ErrInvalidBucket
+	}
+
+	now := time.Now()
+	buckets, err := s.db.GetMergeThroughput(ctx, bucket, now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MergeThroughput{
+		Bucket:  bucket,
+		Window:  windowText,
+		Buckets: models.EmptyIfNil(buckets),
+	}, nil
+}
+
+// GetTeamCapacity returns each team's open reviews per active member, to spot
+// understaffed teams
+func (s *Service) GetTeamCapacity(ctx context.Context) (*models.TeamCapacityResponse, error) {
+	teams, err := s.db.GetTeamCapacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range teams {
+		if teams[i].ActiveMembers > 0 {
+			load := float64(teams[i].OpenReviews) / float64(teams[i].ActiveMembers)
+			teams[i].LoadPerMember = &load
+		}
+	}
+
+	return &models.TeamCapacityResponse{Teams: models.EmptyIfNil(teams)}, nil
+}
+
+// GetTeamUnassignedMembers returns active members who have never been assigned
+// as a reviewer, so leads can ramp them in
+func (s *Service) GetTeamUnassignedMembers(ctx context.Context, teamName string) (*models.TeamUnassignedMembers, error) {
+	exists, err := s.db.TeamExists(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrTeamNotFound
+	}
+
+	members, err := s.db.GetNeverAssignedMembers(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TeamUnassignedMembers{
+		TeamName: teamName,
+		Members:  models.EmptyIfNil(members),
+	}, nil
+}
+
+// UserTeam returns the team the user belongs to. Only an unknown user is
+// ErrUserNotFound, a failed lookup returns its error
+func (s *Service) UserTeam(ctx context.Context, userID string) (string, error) {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.TeamName, nil
+}
+
+// PRTeam returns the team of the PR's author, the team owning the PR. Only
+// an unknown PR is ErrPRNotFound, a failed lookup returns its error
+func (s *Service) PRTeam(ctx context.Context, prID string) (string, error) {
+	pr, err := s.db.GetPRByID(ctx, prID)
+	if errors.Is(err, database.ErrPRNotFound) {
+		return "", ErrPRNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.UserTeam(ctx, pr.AuthorID)
+}
+
+// User methods
+func (s *Service) SetUserActive(ctx context.Context, req models.SetUserActiveRequest) (*models.User, error) {
+	user, err := s.db.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	user.IsActive = req.IsActive
+	if err := s.db.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Reactivation counts as activity, otherwise MAX_IDLE would keep excluding the user
+	if req.IsActive {
+		if err := s.db.TouchUser(ctx, user.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:    models.EventUserActiveChanged,
+		UserID:  user.UserID,
+		Details: map[string]string{"is_active": strconv.FormatBool(req.IsActive)},
+	})
+
+	openReviews, err := s.db.CountOpenReviews(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user.OpenReviews = &openReviews
+
+	return user, nil
+}
+
+// SetMentor makes mentorID a reviewer of every new PR by the user. The mentor
+// must be active when the rule is set and is skipped while unavailable later
+func (s *Service) SetMentor(ctx context.Context, req models.SetMentorRequest) (*models.AuthorMentor, error) {
+	if _, err := s.db.GetUserByID(ctx, req.UserID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if req.MentorID != "" {
+		if req.MentorID == req.UserID {
+			return nil, ErrAuthorReview
+		}
+		mentor, err := s.db.GetUserByID(ctx, req.MentorID)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		if !mentor.IsActive {
+			return nil, ErrMentorInactive
+		}
+	}
+
+	if err := s.db.SetAuthorMentor(ctx, req.UserID, req.MentorID); err != nil {
+		return nil, err
+	}
+
+	return &models.AuthorMentor{UserID: req.UserID, MentorID: req.MentorID}, nil
+}
+
+// SetAcceptingReviews pauses or resumes new assignments for the user. Unlike
+// deactivation it keeps the user's current reviews and team stats untouched
+func (s *Service) SetAcceptingReviews(ctx context.Context, req models.SetAcceptingReviewsRequest) (*models.User, error) {
+	user, err := s.db.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.db.SetAcceptingReviews(ctx, user.UserID, req.AcceptingReviews); err != nil {
+		return nil, err
+	}
+	user.AcceptingReviews = &req.AcceptingReviews
+
+	return user, nil
+}
+
+// SetCalendarAvailability stores the busy window pushed by a calendar
+// integration. It expires on its own: once the window ends the user is
+// picked as usual again, without anyone resetting it
+func (s *Service) SetCalendarAvailability(ctx context.Context, req models.SetCalendarAvailabilityRequest) (*models.User, error) {
+	if req.Busy != nil && (req.Busy.Start.IsZero() || !req.Busy.End.After(req.Busy.Start.Time)) {
+		return nil, ErrInvalidBusyWindow
+	}
+
+	user, err := s.db.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.db.SetCalendarBusy(ctx, user.UserID, req.Busy); err != nil {
+		return nil, err
+	}
+	user.CalendarBusy = req.Busy
+
+	return user, nil
+}
+
+// SetNotificationPrefs replaces which notifications the user receives and
+// on which channels
+func (s *Service) SetNotificationPrefs(ctx context.Context, req models.SetNotificationPrefsRequest) (*models.User, error) {
+	user, err := s.db.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	prefs := *req.NotificationPrefs
+	prefs.Channels = models.EmptyIfNil(prefs.Channels)
+	if err := s.db.SetNotificationPrefs(ctx, user.UserID, prefs); err != nil {
+		return nil, err
+	}
+	user.NotificationPrefs = &prefs
+
+	return user, nil
+}
+
+// PR methods
+func (s *Service) CreatePR(ctx context.Context, req models.CreatePRRequest) (*models.PullRequest, error) {
+	name, err := s.fitPRName(req.PullRequestName)
+	if err != nil {
+		return nil, err
+	}
+	req.PullRequestName = name
+
+	if req.PullRequestID == "" {
+		id, err := s.generatePRID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.PullRequestID = id
+	}
+
+	// Check if PR already exists, soft-deleted PRs keep their IDs
+	taken, err := s.db.PRIDTaken(ctx, req.PullRequestID)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrPRExists
+	}
+
+	// Get author
+	author, err := s.db.GetUserByID(ctx, req.AuthorID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if s.cfg.BlockInactiveAuthors && !author.IsActive {
+		return nil, ErrAuthorInactive
+	}
+	if s.cfg.StrictAuthorTeam {
+		exists, err := s.db.TeamExists(ctx, author.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrTeamNotFound
+		}
+	}
+
+	if req.ReviewerPool != "" {
+		exists, err := s.db.PoolExists(ctx, req.ReviewerPool)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrPoolNotFound
+		}
+	}
+
+	reviewRequired := req.ReviewRequired == nil || *req.ReviewRequired
+
+	now := time.Now()
+	pr := &models.PullRequest{
+		PullRequestID:     req.PullRequestID,
+		PullRequestName:   req.PullRequestName,
+		AuthorID:          req.AuthorID,
+		Status:            models.PRStatusOpen,
+		AssignedReviewers: []string{},
+		CreatedAt:         models.NewTimestamp(now),
+		ReviewerPool:      req.ReviewerPool,
+		ReviewRequired:    &reviewRequired,
+		Labels:            normalizeLabels(req.Labels),
+	}
+	if req.Draft {
+		pr.Status = models.PRStatusDraft
+	}
+
+	// Outside the team's review window assignment is left to the worker
+	inWindow, err := s.inTeamReviewWindow(ctx, author.TeamName, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// poolSize stays -1 unless reviewers are picked now
+	poolSize := -1
+	switch {
+	case req.Draft:
+		// Reviewers are assigned once the PR is marked ready
+	case !reviewRequired:
+		// Nobody to assign, now or later
+	case s.cfg.AssignmentGracePeriod > 0, s.cfg.DeferOutsideReviewWindow && !inWindow:
+		pr.PendingAssignment = true
+	default:
+		if poolSize, err = s.assignNewPR(ctx, author, pr, nil, req.Explain); err != nil {
+			return nil, err
+		}
+	}
+
+	// A dry run reports the would-be result after every check and the full
+	// selection, but writes nothing and notifies no one
+	if req.DryRun {
+		return pr, nil
+	}
+
+	// A reviewer concurrently deactivated or no longer accepting reviews is
+	// replaced by picking again without them, up to ASSIGNMENT_RETRIES times
+	var skipped []string
+	for retry := 0; ; retry++ {
+		unavailable, err := s.db.CreatePR(ctx, pr)
+		if errors.Is(err, database.ErrInvalidStatus) {
+			return nil, ErrInvalidStatus
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(unavailable) == 0 {
+			break
+		}
+		if retry == s.cfg.AssignmentRetries {
+			return nil, noCandidate(NoCandidateCandidatesUnavailable)
+		}
+
+		skipped = append(skipped, unavailable...)
+		if poolSize, err = s.assignNewPR(ctx, author, pr, skipped, req.Explain); err != nil {
+			return nil, err
+		}
+	}
+
+	// Re-read reviewers so the response uses the same order as every other read
+	if pr.AssignedReviewers, err = s.db.GetPRReviewerIDs(ctx, pr.PullRequestID); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.TouchUser(ctx, author.UserID); err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:          models.EventPRCreated,
+		PullRequestID: pr.PullRequestID,
+		UserID:        pr.AuthorID,
+	})
+	if poolSize >= 0 {
+		s.recordDecision(ctx, models.AssignmentDecision{
+			PullRequestID: pr.PullRequestID,
+			Kind:          models.DecisionAssign,
+			Strategy:      s.assignStrategy(pr),
+			PoolSize:      poolSize,
+			Selected:      pr.AssignedReviewers,
+		})
+	}
+
+	if reviewRequired && !req.Draft && !pr.PendingAssignment {
+		s.notifier.Dispatch(ctx, notify.Event{
+			Type:          notify.EventReviewersAssigned,
+			PullRequestID: pr.PullRequestID,
+			AuthorID:      pr.AuthorID,
+			Reviewers:     pr.AssignedReviewers,
+		})
+	}
+
+	return pr, nil
+}
+
+// recordDecision writes the assignment decision log entry. Like recordEvent
+// it runs after the change is committed, so a failure is only logged
+func (s *Service) recordDecision(ctx context.Context, decision models.AssignmentDecision) {
+	decision.ActorID = actorFrom(ctx)
+	if decision.Strategy == "" {
+		decision.Strategy = s.cfg.AssignmentStrategy
+	}
+	if err := s.db.RecordDecision(ctx, &decision); err != nil {
+		log.Printf("Failed to record %s decision for %s: %v", decision.Kind, decision.PullRequestID, err)
+	}
+}
+
+// distinctTeams reports whether the PR's reviewers must come from different
+// teams. Only a reviewer pool spans teams, a PR without one picks from the
+// author's team
+func (s *Service) distinctTeams(pr *models.PullRequest) bool {
+	return s.cfg.DistinctReviewerTeams && pr.ReviewerPool != ""
+}
+
+// assignStrategy is the strategy assignReviewers picks the PR's reviewers with
+func (s *Service) assignStrategy(pr *models.PullRequest) string {
+	if s.distinctTeams(pr) {
+		return ReasonDistinctTeam
+	}
+	return s.cfg.AssignmentStrategy
+}
+
+// GetDecisions returns the PR's assignment decisions, oldest first
+func (s *Service) GetDecisions(ctx context.Context, prID string) (*models.DecisionsResponse, error) {
+	decisions, err := s.db.GetDecisions(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DecisionsResponse{Decisions: models.EmptyIfNil(decisions)}, nil
+}
+
+// recordEvent writes an audit event attributed to the context's actor. The
+// change it describes is already committed, so a failure is only logged
+func (s *Service) recordEvent(ctx context.Context, event models.AuditEvent) {
+	event.ActorID = actorFrom(ctx)
+	if err := s.db.RecordEvent(ctx, &event); err != nil {
+		log.Printf("Failed to record %s event: %v", event.Type, err)
+	}
+}
+
+// GetEvents returns the latest audit events, optionally for one PR only
+func (s *Service) GetEvents(ctx context.Context, prID string, limit int) (*models.EventsResponse, error) {
+	events, err := s.db.GetEvents(ctx, prID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.EventsResponse{Events: models.EmptyIfNil(events)}, nil
+}
+
+// fitPRName applies MAX_PR_NAME_LENGTH: a longer name is rejected, or with
+// PR_NAME_OVERFLOW=truncate cut to the limit ending with an ellipsis
+func (s *Service) fitPRName(name string) (string, error) {
+	runes := []rune(name)
+	if len(runes) <= s.cfg.MaxPRNameLength {
+		return name, nil
+	}
+	if s.cfg.PRNameOverflow != config.OverflowTruncate {
+		return "", ErrPRNameTooLong
+	}
+	return string(runes[:s.cfg.MaxPRNameLength-1]) + "…", nil
+}
+
+// generatePRID returns a random unused PR ID with the configured prefix.
+// The primary key still guards against a concurrent insert of the same ID
+func (s *Service) generatePRID(ctx context.Context) (string, error) {
+	const attempts = 3
+
+	buf := make([]byte, 8)
+	for i := 0; i < attempts; i++ {
+		if _, err := crand.Read(buf); err != nil {
+			return "", err
+		}
+		id := s.cfg.PRIDPrefix + hex.EncodeToString(buf)
+
+		taken, err := s.db.PRIDTaken(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return id, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to generate a unique PR id after %d attempts", attempts)
+}
+
+// assignNewPR picks the reviewers of a PR being created, leaving out the
+// skipped users, and reports a shortfall. explain attaches the trace. It
+// returns the size of the candidate pool the reviewers were picked from
+func (s *Service) assignNewPR(ctx context.Context, author *models.User, pr *models.PullRequest, skip []string, explain bool) (int, error) {
+	var trace *models.AssignmentTrace
+	if explain {
+		trace = &models.AssignmentTrace{}
+	}
+
+	var poolSize int
+	var err error
+	pr.AssignedReviewers, pr.ReviewerReasons, poolSize, err = s.assignReviewers(ctx, author, pr, skip, trace)
+	if err != nil {
+		return 0, err
+	}
+	pr.AssignmentAlgorithm = s.cfg.AssignmentStrategy
+	pr.AssignmentTrace = trace
+
+	// Creation still succeeds, the client decides how to handle the gap
+	pr.AssignmentShortfall = nil
+	if len(pr.AssignedReviewers) < s.cfg.MaxReviewers {
+		pr.AssignmentShortfall = &models.AssignmentShortfall{
+			Required: s.cfg.MaxReviewers,
+			Actual:   len(pr.AssignedReviewers),
+		}
+	}
+	return poolSize, nil
+}
+
+// assignReviewers selects up to MAX_REVIEWERS reviewers for a new PR: from the
+// PR's reviewer pool if it has one, from the author's team otherwise. Skipped
+// users are never picked. A non-nil trace is filled in with how the selection
+// was made. The pool size counts the candidates left to choose from once the
+// unavailable ones are dropped
+func (s *Service) assignReviewers(ctx context.Context, author *models.User, pr *models.PullRequest, skip []string, trace *models.AssignmentTrace) ([]string, []models.ReviewerReason, int, error) {
+	// The author never reviews their own PR unless self-review is enabled for demos
+	excludeUserID := author.UserID
+	if s.cfg.AllowSelfReview {
+		excludeUserID = ""
+	}
+
+	var candidates []models.User
+	var err error
+	if pr.ReviewerPool != "" {
+		candidates, err = s.db.GetActiveUsersByPool(ctx, pr.ReviewerPool, excludeUserID)
+	} else {
+		candidates, err = s.db.GetActiveUsersByTeam(ctx, author.TeamName, excludeUserID)
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if trace != nil {
+		if err := s.traceCandidatePool(ctx, trace, author, pr, candidates); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	if len(skip) > 0 {
+		available := slices.DeleteFunc(slices.Clone(candidates), func(candidate models.User) bool {
+			return slices.Contains(skip, candidate.UserID)
+		})
+		traceExcluded(trace, candidates, available, ExcludedUnavailable)
+		candidates = available
+	}
+	candidates = s.eligibleCandidates(candidates, trace)
+	if candidates, err = s.belowDailyCap(ctx, candidates, trace); err != nil {
+		return nil, nil, 0, err
+	}
+	eligible := candidates
+	candidates = excludeReportingLine(author, candidates)
+	traceExcluded(trace, eligible, candidates, ExcludedReportingLine)
+	poolSize := len(candidates)
+
+	rng, err := s.assignmentRand(ctx, author.TeamName, pr.PullRequestID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Reserved seats go to the author's mentor and then to a recent author in
+	// the PR's area, the rest are picked by the strategy
+	distinct := s.distinctTeams(pr)
+	pool := slices.Clone(candidates)
+	var reserved []models.User
+	var reasons []models.ReviewerReason
+	reserve := func(user models.User, reason string) {
+		reserved = append(reserved, user)
+		reasons = append(reasons, models.ReviewerReason{UserID: user.UserID, Reason: reason})
+		candidates = slices.DeleteFunc(candidates, func(candidate models.User) bool {
+			if candidate.UserID == user.UserID {
+				return true
+			}
+			sameTeam := distinct && candidate.TeamName == user.TeamName
+			if sameTeam && trace != nil {
+				trace.Excluded = append(trace.Excluded, models.ReviewerReason{UserID: candidate.UserID, Reason: ExcludedSameTeam})
+			}
+			return sameTeam
+		})
+	}
+
+	mentor, err := s.db.GetAvailableMentor(ctx, author.UserID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if mentor != nil && mentor.UserID != author.UserID && !slices.Contains(skip, mentor.UserID) {
+		reserve(*mentor, ReasonMentor)
+	}
+
+	// Without a senior candidate a junior's PR gets its reviewers as usual
+	isSenior := func(user models.User) bool { return user.Seniority == models.SenioritySenior }
+	if s.cfg.SeniorCoverage && author.Seniority == models.SeniorityJunior &&
+		len(reserved) < s.cfg.MaxReviewers && !slices.ContainsFunc(reserved, isSenior) {
+		if senior := pickSenior(rng, candidates); senior != nil {
+			reserve(*senior, ReasonSeniorCoverage)
+		}
+	}
+
+	if len(reserved) < s.cfg.MaxReviewers {
+		areaAuthor, err := s.pickAreaAuthor(ctx, rng, candidates, pr.Labels)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if areaAuthor != nil {
+			reserve(*areaAuthor, ReasonRecentAreaAuthor)
+		}
+	}
+
+	var reviewers []string
+	var reason string
+	if distinct {
+		reviewers, reason = pickDistinctTeams(rng, candidates, s.cfg.MaxReviewers-len(reserved)), ReasonDistinctTeam
+		if len(reserved)+len(reviewers) < s.cfg.MaxReviewers {
+			// Distinct teams are only to blame if picking regardless of team
+			// would have filled every seat
+			unreserved := slices.DeleteFunc(pool, func(candidate models.User) bool {
+				return slices.ContainsFunc(reserved, func(user models.User) bool { return user.UserID == candidate.UserID })
+			})
+			if len(reserved)+len(unreserved) < s.cfg.MaxReviewers {
+				return nil, nil, 0, noCandidate(NoCandidateNotEnoughCandidates)
+			}
+			return nil, nil, 0, noCandidate(NoCandidateNotEnoughTeams)
+		}
+	} else {
+		reviewers, reason, err = s.selectReviewers(ctx, rng, candidates, s.cfg.MaxReviewers-len(reserved))
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	// A swap could put two reviewers of one team on the PR
+	var onlineSoonID string
+	if s.cfg.TimezoneBalancing && !distinct {
+		reviewers, onlineSoonID = balanceTimezones(rng, candidates, reviewers, reserved, time.Now())
+	}
+
+	for _, reviewer := range reviewers {
+		if reviewer == onlineSoonID {
+			reasons = append(reasons, models.ReviewerReason{UserID: reviewer, Reason: ReasonOnlineSoon})
+		} else {
+			reasons = append(reasons, models.ReviewerReason{UserID: reviewer, Reason: reason})
+		}
+	}
+
+	selected := make([]string, 0, len(reserved)+len(reviewers))
+	for _, user := range reserved {
+		selected = append(selected, user.UserID)
+	}
+	selected = append(selected, reviewers...)
+
+	if trace != nil {
+		for _, candidate := range candidates {
+			if !slices.Contains(selected, candidate.UserID) {
+				trace.Excluded = append(trace.Excluded, models.ReviewerReason{UserID: candidate.UserID, Reason: ExcludedCapped})
+			}
+		}
+		trace.Selected = reasons
+	}
+	return selected, reasons, poolSize, nil
+}
+
+// Reasons reported by the assignment trace for candidates that weren't chosen
+const (
+	ExcludedAuthor        = "author"
+	ExcludedInactive      = "inactive"
+	ExcludedNotAccepting  = "not-accepting-reviews"
+	ExcludedIdle          = "idle"
+	ExcludedCooldown      = "cooldown"
+	ExcludedReportingLine = "reporting-line"
+	ExcludedSameTeam      = "same-team"
+	ExcludedCapped        = "capped"
+	ExcludedUnavailable   = "unavailable"
+	ExcludedDailyCap      = "daily-cap"
+	ExcludedCalendarBusy  = "calendar-busy"
+)
+
+// traceCandidatePool starts the trace with the whole pool and the members the
+// candidate query already left out
+func (s *Service) traceCandidatePool(ctx context.Context, trace *models.AssignmentTrace, author *models.User, pr *models.PullRequest, candidates []models.User) error {
+	trace.Pool = "team:" + author.TeamName
+	if pr.ReviewerPool != "" {
+		trace.Pool = "pool:" + pr.ReviewerPool
+	}
+
+	members, err := s.db.GetCandidatePool(ctx, author.TeamName, pr.ReviewerPool)
+	if err != nil {
+		return err
+	}
+
+	trace.Candidates = []string{}
+	trace.Excluded = []models.ReviewerReason{}
+	for _, member := range members {
+		trace.Candidates = append(trace.Candidates, member.UserID)
+		if slices.ContainsFunc(candidates, func(candidate models.User) bool { return candidate.UserID == member.UserID }) {
+			continue
+		}
+
+		reason := ExcludedNotAccepting
+		switch {
+		case member.UserID == author.UserID:
+			reason = ExcludedAuthor
+		case !member.IsActive:
+			reason = ExcludedInactive
+		}
+		trace.Excluded = append(trace.Excluded, models.ReviewerReason{UserID: member.UserID, Reason: reason})
+	}
+	return nil
+}
+
+// traceExcluded records the candidates a filtering step dropped, if tracing
+func traceExcluded(trace *models.AssignmentTrace, before, after []models.User, reason string) {
+	if trace == nil {
+		return
+	}
+	for _, candidate := range before {
+		if !slices.ContainsFunc(after, func(kept models.User) bool { return kept.UserID == candidate.UserID }) {
+			trace.Excluded = append(trace.Excluded, models.ReviewerReason{UserID: candidate.UserID, Reason: reason})
+		}
+	}
+}
+
+// AddReviewerTeams fills in the distinct teams of the PR's reviewers, so
+// clients can check a cross-team review spans the right teams
+func (s *Service) AddReviewerTeams(ctx context.Context, pr *models.PullRequest) error {
+	teams, err := s.db.GetTeamsOfUsers(ctx, pr.AssignedReviewers)
+	if err != nil {
+		return err
+	}
+	pr.ReviewerTeams = models.EmptyIfNil(teams)
+	return nil
+}
+
+// normalizeLabels sorts the labels and drops duplicates, matching the order
+// labels are read back in
+func normalizeLabels(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	labels = slices.Clone(labels)
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
+
+// pickSenior returns a random senior candidate, nil when there is none
+func pickSenior(rng *rand.Rand, candidates []models.User) *models.User {
+	var seniors []models.User
+	for _, candidate := range candidates {
+		if candidate.Seniority == models.SenioritySenior {
+			seniors = append(seniors, candidate)
+		}
+	}
+	if len(seniors) == 0 {
+		return nil
+	}
+	return &seniors[rng.Intn(len(seniors))]
+}
+
+// pickAreaAuthor returns a random candidate who recently authored a PR with
+// one of the labels, nil when AREA_AFFINITY_WINDOW is off or nobody matches
+func (s *Service) pickAreaAuthor(ctx context.Context, rng *rand.Rand, candidates []models.User, labels []string) (*models.User, error) {
+	if s.cfg.AreaAffinityWindow <= 0 || len(labels) == 0 || len(candidates) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.UserID
+	}
+	authors, err := s.db.GetRecentAreaAuthors(ctx, ids, labels, time.Now().Add(-s.cfg.AreaAffinityWindow))
+	if err != nil || len(authors) == 0 {
+		return nil, err
+	}
+
+	pick := authors[rng.Intn(len(authors))]
+	for i := range candidates {
+		if candidates[i].UserID == pick {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// inTeamReviewWindow reports whether t falls into the team's review window.
+// Teams without a configured window are always open
+func (s *Service) inTeamReviewWindow(ctx context.Context, teamName string, t time.Time) (bool, error) {
+	start, end, err := s.db.GetTeamReviewWindow(ctx, teamName)
+	if err != nil {
+		return false, err
+	}
+	if start == nil || end == nil {
+		return true, nil
+	}
+	return inReviewWindow(*start, *end, t), nil
+}
+
+// inReviewWindow reports whether the UTC hour of t is within [start, end).
+// Windows may wrap midnight (start > end), start == end means all day
+func inReviewWindow(start, end int, t time.Time) bool {
+	hour := t.UTC().Hour()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return hour >= start && hour < end
+	default:
+		return hour >= start || hour < end
+	}
+}
+
+// AssignPendingPRs assigns reviewers to deferred PRs once their grace period
+// is over and their team's review window is open, returning how many were
+// assigned. PRs that got reviewers manually meanwhile are left as they are
+func (s *Service) AssignPendingPRs(ctx context.Context) (int, error) {
+	prs, err := s.db.GetPendingAssignmentPRs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	assigned := 0
+	for i := range prs {
+		pr := &prs[i]
+
+		// Within the grace period reviewers are left to volunteer
+		if s.cfg.AssignmentGracePeriod > 0 && now.Before(pr.CreatedAt.Add(s.cfg.AssignmentGracePeriod)) {
+			continue
+		}
+
+		// Someone assigned themselves, nothing left to do
+		current, err := s.db.GetPRReviewerIDs(ctx, pr.PullRequestID)
+		if err != nil {
+			return assigned, err
+		}
+		if len(current) > 0 {
+			if _, err := s.db.CompletePendingAssignment(ctx, pr.PullRequestID, nil); err != nil {
+				return assigned, err
+			}
+			continue
+		}
+
+		author, err := s.db.GetUserByID(ctx, pr.AuthorID)
+		if err != nil {
+			return assigned, err
+		}
+
+		inWindow, err := s.inTeamReviewWindow(ctx, author.TeamName, now)
+		if err != nil {
+			return assigned, err
+		}
+		if !inWindow {
+			continue
+		}
+
+		reviewers, _, poolSize, err := s.assignReviewers(ctx, author, pr, nil, nil)
+		var noCandidate *NoCandidateError
+		if errors.As(err, &noCandidate) {
+			// Stays pending, the team or pool may have candidates later
+			log.Printf("No reviewers for PR %s: %s", pr.PullRequestID, noCandidate.Message())
+			continue
+		}
+		if err != nil {
+			return assigned, err
+		}
+
+		done, err := s.db.CompletePendingAssignment(ctx, pr.PullRequestID, reviewers)
+		if err != nil {
+			return assigned, err
+		}
+		if done {
+			assigned++
+			s.recordEvent(ctx, models.AuditEvent{
+				Type:          models.EventReviewersAssigned,
+				PullRequestID: pr.PullRequestID,
+				Details:       map[string]string{"reviewers": strings.Join(reviewers, ",")},
+			})
+			s.recordDecision(ctx, models.AssignmentDecision{
+				PullRequestID: pr.PullRequestID,
+				Kind:          models.DecisionAssign,
+				Strategy:      s.assignStrategy(pr),
+				PoolSize:      poolSize,
+				Selected:      reviewers,
+			})
+			s.notifier.Dispatch(ctx, notify.Event{
+				Type:          notify.EventReviewersAssigned,
+				PullRequestID: pr.PullRequestID,
+				AuthorID:      pr.AuthorID,
+				Reviewers:     reviewers,
+			})
+		}
+	}
+
+	return assigned, nil
+}
+
+// RunAssignmentWorker calls AssignPendingPRs every interval until ctx is done
+func (s *Service) RunAssignmentWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			assigned, err := s.AssignPendingPRs(ctx)
+			if err != nil {
+				log.Println("Pending assignment worker failed:", err)
+			}
+			if assigned > 0 {
+				log.Printf("Pending assignment worker assigned reviewers to %d PRs", assigned)
+			}
+		}
+	}
+}
+
+// RunDigestWorker sends the queued notification digests every interval until
+// ctx is done
+func (s *Service) RunDigestWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.notifier.FlushDigest(ctx)
+			if err != nil {
+				log.Println("Notification digest worker failed:", err)
+			}
+			if sent > 0 {
+				log.Printf("Notification digest worker sent %d digests", sent)
+			}
+		}
+	}
+}
+
+// RunPurgeWorker hard-deletes PRs soft-deleted longer than retention ago,
+// every interval until ctx is done
+func (s *Service) RunPurgeWorker(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.db.PurgeDeletedPRs(ctx, time.Now().Add(-retention))
+			if err != nil {
+				log.Println("Soft-delete purge worker failed:", err)
+			}
+			if purged > 0 {
+				log.Printf("Soft-delete purge worker removed %d PRs", purged)
+			}
+		}
+	}
+}
+
+// Local working hours used by TIMEZONE_BALANCING, a reviewer whose day starts
+// within onlineSoonLead also counts as online soon
+const (
+	workdayStartHour = 9
+	workdayEndHour   = 18
+	onlineSoonLead   = 2 * time.Hour
+)
+
+// balanceTimezones makes sure at least one reviewer is online or about to be
+// at t: if neither the selection nor a reserved reviewer is, the last
+// selected reviewer is swapped for a random candidate who is. It returns the
+// new selection and the swapped-in reviewer, empty if nothing changed
+func balanceTimezones(rng *rand.Rand, candidates []models.User, reviewers []string, reserved []models.User, t time.Time) ([]string, string) {
+	if len(reviewers) == 0 || slices.ContainsFunc(reserved, func(user models.User) bool { return onlineSoon(user, t) }) {
+		return reviewers, ""
+	}
+
+	selected := make(map[string]bool, len(reviewers))
+	for _, reviewer := range reviewers {
+		selected[reviewer] = true
+	}
+
+	var online []models.User
+	for _, candidate := range candidates {
+		if !onlineSoon(candidate, t) {
+			continue
+		}
+		if selected[candidate.UserID] {
+			return reviewers, ""
+		}
+		online = append(online, candidate)
+	}
+
+	if len(online) == 0 {
+		return reviewers, ""
+	}
+
+	pick := online[rng.Intn(len(online))].UserID
+	balanced := slices.Clone(reviewers)
+	balanced[len(balanced)-1] = pick
+	return balanced, pick
+}
+
+// onlineSoon reports whether it's the user's working hours at t or they start
+// within onlineSoonLead. Users without a known time zone never qualify
+func onlineSoon(user models.User, t time.Time) bool {
+	if user.UTCOffsetMinutes == nil {
+		return false
+	}
+
+	local := t.UTC().Add(time.Duration(*user.UTCOffsetMinutes) * time.Minute)
+	sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	start := workdayStartHour*time.Hour - onlineSoonLead
+	return sinceMidnight >= start && sinceMidnight < workdayEndHour*time.Hour
+}
+
+// excludeReportingLine drops the author's manager and direct reports to avoid
+// conflict-of-interest reviews. If nobody else is left the candidates are
+// returned unchanged, a review from the reporting line beats no review
+func excludeReportingLine(author *models.User, candidates []models.User) []models.User {
+	var filtered []models.User
+	for _, candidate := range candidates {
+		if candidate.UserID == author.ManagerID || candidate.ManagerID == author.UserID {
+			continue
+		}
+		filtered = append(filtered, candidate)
+	}
+
+	if len(filtered) == 0 {
+		return candidates
+	}
+	return filtered
+}
+
+// eligibleCandidates drops active users that still can't get new assignments:
+// those inside a calendar busy window, those idle for longer than MAX_IDLE
+// and, unless nobody else is left, those still in their REVIEW_COOLDOWN.
+// Drops are recorded in a non-nil trace
+func (s *Service) eligibleCandidates(candidates []models.User, trace *models.AssignmentTrace) []models.User {
+	now := time.Now()
+
+	var free []models.User
+	for _, candidate := range candidates {
+		if candidate.CalendarBusy != nil && candidate.CalendarBusy.Covers(now) {
+			continue
+		}
+		free = append(free, candidate)
+	}
+	traceExcluded(trace, candidates, free, ExcludedCalendarBusy)
+	candidates = free
+
+	if s.cfg.MaxIdle > 0 {
+		cutoff := now.Add(-s.cfg.MaxIdle)
+		var eligible []models.User
+		for _, candidate := range candidates {
+			if candidate.LastActiveAt != nil && candidate.LastActiveAt.Before(cutoff) {
+				continue
+			}
+			eligible = append(eligible, candidate)
+		}
+		traceExcluded(trace, candidates, eligible, ExcludedIdle)
+		candidates = eligible
+	}
+
+	if s.cfg.ReviewCooldown > 0 {
+		cutoff := now.Add(-s.cfg.ReviewCooldown)
+		var rested []models.User
+		for _, candidate := range candidates {
+			if candidate.LastReviewCompletedAt != nil && candidate.LastReviewCompletedAt.After(cutoff) {
+				continue
+			}
+			rested = append(rested, candidate)
+		}
+		if len(rested) > 0 {
+			traceExcluded(trace, candidates, rested, ExcludedCooldown)
+			candidates = rested
+		}
+	}
+
+	return candidates
+}
+
+// belowDailyCap drops candidates already assigned MAX_DAILY_REVIEWS reviews
+// on the current UTC day, unless that leaves nobody: then getting reviewers
+// matters more than spreading the load. Drops are recorded in a non-nil trace
+func (s *Service) belowDailyCap(ctx context.Context, candidates []models.User, trace *models.AssignmentTrace) ([]models.User, error) {
+	if s.cfg.MaxDailyReviews <= 0 || len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.UserID
+	}
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	counts, err := s.db.GetAssignmentCountsSince(ctx, ids, dayStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var below []models.User
+	for _, candidate := range candidates {
+		if counts[candidate.UserID] < s.cfg.MaxDailyReviews {
+			below = append(below, candidate)
+		}
+	}
+	if len(below) == 0 {
+		return candidates, nil
+	}
+	traceExcluded(trace, candidates, below, ExcludedDailyCap)
+	return below, nil
+}
+
+// assignmentRand returns the RNG for selecting the PR's reviewers. Teams with an
+// assignment seed get an RNG derived from the seed and the PR ID, so the same PR
+// always yields the same reviewers; other teams get non-reproducible randomness
+func (s *Service) assignmentRand(ctx context.Context, teamName, prID string) (*rand.Rand, error) {
+	seed, err := s.db.GetTeamAssignmentSeed(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if seed == nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano())), nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(prID))
+	return rand.New(rand.NewSource(*seed ^ int64(h.Sum64()))), nil
+}
+
+// Reasons reported for why reviewers were chosen
+const (
+	ReasonRandom              = "random"
+	ReasonNeverPaired         = "never-paired"
+	ReasonLeastRecentlyPaired = "least-recently-paired"
+	ReasonFallback            = "fallback"
+	ReasonMentor              = "mentor"
+	ReasonOnlineSoon          = "online-soon"
+	ReasonRecentAreaAuthor    = "recent-area-author"
+	ReasonDistinctTeam        = "distinct-team"
+	ReasonSeniorCoverage      = "senior-coverage"
+)
+
+// selectReviewers picks up to count reviewers using the configured strategy
+// and reports why they were chosen
+func (s *Service) selectReviewers(ctx context.Context, rng *rand.Rand, candidates []models.User, count int) ([]string, string, error) {
+	if s.cfg.AssignmentStrategy == config.StrategyFreshPairs {
+		if count == 2 && len(candidates) > 2 {
+			return s.pickFreshPair(ctx, rng, candidates)
+		}
+		// Too few candidates to choose a pair from
+		return pickReviewers(rng, candidates, count), ReasonFallback, nil
+	}
+	return pickReviewers(rng, candidates, count), ReasonRandom, nil
+}
+
+// pickFreshPair prefers a pair of candidates that never reviewed together,
+// then the least recently paired one. Ties are broken randomly
+func (s *Service) pickFreshPair(ctx context.Context, rng *rand.Rand, candidates []models.User) ([]string, string, error) {
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.UserID
+	}
+
+	pairings, err := s.db.GetLastPairings(ctx, ids)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var best []string
+	var bestPaired time.Time
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			key := [2]string{ids[i], ids[j]}
+			if key[0] > key[1] {
+				key[0], key[1] = key[1], key[0]
+			}
+
+			lastPaired, paired := pairings[key]
+			if !paired {
+				return []string{ids[i], ids[j]}, ReasonNeverPaired, nil
+			}
+			if best == nil || lastPaired.Before(bestPaired) {
+				best = []string{ids[i], ids[j]}
+				bestPaired = lastPaired
+			}
+		}
+	}
+
+	return best, ReasonLeastRecentlyPaired, nil
+}
+
+// pickReviewers returns up to count random user IDs from candidates
+func pickReviewers(rng *rand.Rand, candidates []models.User, count int) []string {
+	reviewers := []string{}
+	if len(candidates) > 0 {
+		rng.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+
+		count = min(count, len(candidates))
+		for i := 0; i < count; i++ {
+			reviewers = append(reviewers, candidates[i].UserID)
+		}
+	}
+	return reviewers
+}
+
+// pickDistinctTeams picks up to count random reviewers, no two from one team
+func pickDistinctTeams(rng *rand.Rand, candidates []models.User, count int) []string {
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	reviewers := []string{}
+	teams := make(map[string]bool)
+	for _, candidate := range candidates {
+		if len(reviewers) == count {
+			break
+		}
+		if teams[candidate.TeamName] {
+			continue
+		}
+		teams[candidate.TeamName] = true
+		reviewers = append(reviewers, candidate.UserID)
+	}
+	return reviewers
+}
+
+// MergePR marks the PR merged. The bool reports that it was merged before
+// the call, in which case nothing changes
+func (s *Service) MergePR(ctx context.Context, prID string) (*models.PullRequest, bool, error) {
+	pr, err := s.db.GetPRByID(ctx, prID)
+	if err != nil {
+		return nil, false, ErrPRNotFound
+	}
+
+	// Idempotent - if already merged, return current state and say so, so
+	// only the caller that merged reacts to it
+	if pr.Status == models.PRStatusMerged {
+		return pr, true, nil
+	}
+	if pr.Status == models.PRStatusDraft {
+		return nil, false, ErrPRDraft
+	}
+
+	// The status is checked again by the update itself, so of concurrent
+	// merges only one gets here with merged set
+	now, merged, err := s.db.MergePR(ctx, prID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !merged {
+		current, err := s.db.GetPRByID(ctx, prID)
+		if err != nil {
+			return nil, false, ErrPRNotFound
+		}
+		switch current.Status {
+		case models.PRStatusMerged:
+			return current, true, nil
+		case models.PRStatusDraft:
+			return nil, false, ErrPRDraft
+		}
+		return nil, false, ErrPRNotFound
+	}
+	pr.Status = models.PRStatusMerged
+	pr.MergedAt = models.NewTimestamp(now)
+
+	if err := s.db.CompleteReviews(ctx, pr.PullRequestID, now); err != nil {
+		return nil, false, err
+	}
+
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:          models.EventPRMerged,
+		PullRequestID: pr.PullRequestID,
+	})
+
+	s.notifier.Dispatch(ctx, notify.Event{
+		Type:          notify.EventPRMerged,
+		PullRequestID: pr.PullRequestID,
+		AuthorID:      pr.AuthorID,
+		Reviewers:     pr.AssignedReviewers,
+		OccurredAt:    now,
+	})
+
+	return pr, false, nil
+}
+
+// MarkReady opens a draft PR and assigns its reviewers as if it was created
+// now: deferred to the worker under ASSIGNMENT_GRACE_PERIOD or outside the
+// review window, skipped if reviewers were already added by hand. Marking an
+// open PR ready changes nothing
+func (s *Service) MarkReady(ctx context.Context, prID string) (*models.PullRequest, error) {
+	pr, err := s.db.GetPRByID(ctx, prID)
+	if err != nil {
+		return nil, ErrPRNotFound
+	}
+
+	switch pr.Status {
+	case models.PRStatusMerged:
+		return nil, ErrPRMerged
+	case models.PRStatusOpen:
+		return pr, nil
+	}
+
+	author, err := s.db.GetUserByID(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	inWindow, err := s.inTeamReviewWindow(ctx, author.TeamName, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// poolSize stays -1 unless reviewers are picked now
+	var reviewers []string
+	poolSize := -1
+	pending := false
+	switch {
+	case pr.ReviewRequired != nil && !*pr.ReviewRequired, len(pr.AssignedReviewers) > 0:
+		// Nobody to assign
+	case s.cfg.AssignmentGracePeriod > 0, s.cfg.DeferOutsideReviewWindow && !inWindow:
+		pending = true
+	default:
+		reviewers, _, poolSize, err = s.assignReviewers(ctx, author, pr, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ready, err := s.db.MarkPRReady(ctx, prID, pending, reviewers)
+	if errors.Is(err, database.ErrTooManyReviewers) {
+		return nil, ErrTooManyReviewers
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ready {
+		// Marked ready or merged concurrently, report what's stored now
+		return s.GetPR(ctx, prID, false)
+	}
+
+	pr.Status = models.PRStatusOpen
+	pr.PendingAssignment = pending
+	if pr.AssignedReviewers, err = s.db.GetPRReviewerIDs(ctx, prID); err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:          models.EventPRMarkedReady,
+		PullRequestID: prID,
+		Details:       map[string]string{"reviewers": strings.Join(reviewers, ",")},
+	})
+	if poolSize >= 0 {
+		s.recordDecision(ctx, models.AssignmentDecision{
+			PullRequestID: prID,
+			Kind:          models.DecisionAssign,
+			Strategy:      s.assignStrategy(pr),
+			PoolSize:      poolSize,
+			Selected:      models.EmptyIfNil(reviewers),
+		})
+	}
+
+	if len(reviewers) > 0 {
+		s.notifier.Dispatch(ctx, notify.Event{
+			Type:          notify.EventReviewersAssigned,
+			PullRequestID: prID,
+			AuthorID:      pr.AuthorID,
+			Reviewers:     reviewers,
+		})
+	}
+
+	return pr, nil
+}
+
+// GetPR returns the PR with its current reviewers, and with includeHistory
+// also every reviewer it ever had
+func (s *Service) GetPR(ctx context.Context, prID string, includeHistory bool) (*models.PullRequest, error) {
+	pr, err := s.db.GetPRByID(ctx, prID)
+	if err != nil {
+		return nil, ErrPRNotFound
+	}
+
+	if includeHistory {
+		history, err := s.db.GetReviewerHistory(ctx, prID)
+		if err != nil {
+			return nil, err
+		}
+		pr.ReviewerHistory = models.EmptyIfNil(history)
+	}
+
+	return pr, nil
+}
+
+// EvaluatePolicies checks the PR's current reviewers against the configured
+// policies: MAX_REVIEWERS active reviewers, with DISTINCT_REVIEWER_TEAMS no
+// two of them from one team, an active member of every
+// REQUIRED_REVIEWER_POOLS pool among them and with SENIOR_COVERAGE an active
+// senior among them when the author is junior. A PR not requiring review
+// meets them all
+func (s *Service) EvaluatePolicies(ctx context.Context, pr *models.PullRequest) error {
+	unmet := []string{}
+	if pr.ReviewRequired == nil || *pr.ReviewRequired {
+		users, err := s.db.GetUsersByIDs(ctx, append(slices.Clone(pr.AssignedReviewers), pr.AuthorID))
+		if err != nil {
+			return err
+		}
+
+		active := 0
+		distinct := true
+		juniorAuthor, seniorReviewer := false, false
+		teams := make(map[string]bool, len(users))
+		for _, user := range users {
+			if user.UserID == pr.AuthorID {
+				juniorAuthor = user.Seniority == models.SeniorityJunior
+			}
+			if !slices.Contains(pr.AssignedReviewers, user.UserID) {
+				continue
+			}
+			if user.IsActive {
+				active++
+				seniorReviewer = seniorReviewer || user.Seniority == models.SenioritySenior
+			}
+			if teams[user.TeamName] {
+				distinct = false
+			}
+			teams[user.TeamName] = true
+		}
+
+		if active < s.cfg.MaxReviewers {
+			unmet = append(unmet, models.PolicyRequiredCount)
+		}
+		if s.distinctTeams(pr) && !distinct {
+			unmet = append(unmet, models.PolicyDistinctTeams)
+		}
+		if len(s.cfg.RequiredReviewerPools) > 0 {
+			covered, err := s.coveredPools(ctx, users, pr.AssignedReviewers)
+			if err != nil {
+				return err
+			}
+			for _, pool := range s.cfg.RequiredReviewerPools {
+				if !covered[pool] {
+					unmet = append(unmet, models.PolicyRequiredGroups)
+					break
+				}
+			}
+		}
+		if s.cfg.SeniorCoverage && juniorAuthor && !seniorReviewer {
+			unmet = append(unmet, models.PolicySeniorCoverage)
+		}
+	}
+
+	satisfied := len(unmet) == 0
+	pr.PolicySatisfied = &satisfied
+	pr.UnmetPolicies = unmet
+	return nil
+}
+
+// coveredPools returns the reviewer pools with an active member among the
+// given reviewers
+func (s *Service) coveredPools(ctx context.Context, users []models.User, reviewers []string) (map[string]bool, error) {
+	var active []string
+	for _, user := range users {
+		if user.IsActive && slices.Contains(reviewers, user.UserID) {
+			active = append(active, user.UserID)
+		}
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+
+	pools, err := s.db.GetPoolsOfUsers(ctx, active)
+	if err != nil {
+		return nil, err
+	}
+	covered := make(map[string]bool)
+	for _, names := range pools {
+		for _, name := range names {
+			covered[name] = true
+		}
+	}
+	return covered, nil
+}
+
+func (s *Service) DeletePR(ctx context.Context, prID string) (*models.PullRequest, error) {
+	pr, err := s.db.GetPRByID(ctx, prID)
+	if errors.Is(err, database.ErrPRNotFound) {
+		return nil, ErrPRNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get PR %s: %w", prID, err)
+	}
+
+	// Not found here means it was deleted concurrently
+	deletedAt, err := s.db.SoftDeletePR(ctx, prID)
+	if errors.Is(err, database.ErrPRNotFound) {
+		return nil, ErrPRNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("soft-delete PR %s: %w", prID, err)
+	}
+	pr.DeletedAt = models.NewTimestamp(deletedAt)
+
+	return pr, nil
+}
+
+func (s *Service) AssignReviewer(ctx context.Context, req models.AssignReviewerRequest) (*models.PRReviewersResponse, error) {
+	pr, err := s.db.GetPRByID(ctx, req.PullRequestID)
+	if err != nil {
+		return nil, ErrPRNotFound
+	}
+
+	if pr.Status == models.PRStatusMerged {
+		return nil, ErrPRMerged
+	}
+
+	exists, err := s.db.UserExists(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	if req.UserID == pr.AuthorID && !s.cfg.AllowSelfReview {
+		return nil, ErrAuthorReview
+	}
+
+	for _, reviewer := range pr.AssignedReviewers {
+		if reviewer == req.UserID {
+			return nil, ErrAlreadyAssigned
+		}
+	}
+
+	if len(pr.AssignedReviewers) >= s.cfg.MaxReviewers {
+		return nil, ErrTooManyReviewers
+	}
+
+	// The DB enforces the cap too, in case a concurrent assign won the race
+	if err := s.db.AddReviewer(ctx, pr.PullRequestID, req.UserID, req.Note); err != nil {
+		if errors.Is(err, database.ErrTooManyReviewers) {
+			return nil, ErrTooManyReviewers
+		}
+		return nil, err
+	}
+
+	return s.GetPRReviewers(ctx, pr.PullRequestID)
+}
+
+func (s *Service) GetPRReviewers(ctx context.Context, prID string) (*models.PRReviewersResponse, error) {
+	exists, err := s.db.PRExists(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrPRNotFound
+	}
+
+	reviewers, err := s.db.GetPRReviewers(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PRReviewersResponse{
+		PullRequestID: prID,
+		Reviewers:     models.EmptyIfNil(reviewers),
+	}, nil
+}
+
+// withinReassignAfterMerge reports whether the merged PR is still inside
+// REASSIGN_AFTER_MERGE_WINDOW, where a last-minute reviewer mistake can be fixed
+func (s *Service) withinReassignAfterMerge(pr *models.PullRequest, now time.Time) bool {
+	if s.cfg.ReassignAfterMergeWindow <= 0 || pr.MergedAt == nil {
+		return false
+	}
+	return now.Sub(pr.MergedAt.Time) <= s.cfg.ReassignAfterMergeWindow
+}
+
+// reassignMergedAfter is the earliest merge a reassign may still change,
+// nil when merged PRs can't be reassigned at all
+func (s *Service) reassignMergedAfter() *time.Time {
+	if s.cfg.ReassignAfterMergeWindow <= 0 {
+		return nil
+	}
+	mergedAfter := time.Now().Add(-s.cfg.ReassignAfterMergeWindow)
+	return &mergedAfter
+}
+
+// reviewerChangeError maps the errors of a locked reviewer change, so a PR
+// merged or deleted concurrently reports the same code as one found so upfront
+func reviewerChangeError(err error) error {
+	switch {
+	case errors.Is(err, database.ErrTooManyReviewers):
+		return ErrTooManyReviewers
+	case errors.Is(err, database.ErrPRMerged):
+		return ErrPRMerged
+	case errors.Is(err, database.ErrPRNotFound):
+		return ErrPRNotFound
+	}
+	return err
+}
+
+func (s *Service) ReassignReviewer(ctx context.Context, req models.ReassignReviewerRequest) (*models.ReassignResult, error) {
+	pr, err := s.db.GetPRByID(ctx, req.PullRequestID)
+	if err != nil {
+		return nil, ErrPRNotFound
+	}
+
+	if pr.Status == models.PRStatusMerged && !s.withinReassignAfterMerge(pr, time.Now()) {
+		return nil, ErrPRMerged
+	}
+
+	// Check if old reviewer is assigned
+	found := false
+	for _, reviewer := range pr.AssignedReviewers {
+		if reviewer == req.OldUserID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrReviewerNotAssigned
+	}
+
+	available, reason, err := s.replacementCandidates(ctx, pr, req.OldUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(available) == 0 {
+		if s.cfg.RelaxedReassign {
+			result, err := s.reassignToCoReviewer(ctx, pr, req.OldUserID)
+			if !errors.Is(err, ErrNoCandidate) || !s.cfg.DropUnreplaceableReviewer {
+				return result, err
+			}
+		}
+		if s.cfg.DropUnreplaceableReviewer {
+			return s.dropUnreplaceableReviewer(ctx, pr, req.OldUserID)
+		}
+		return nil, noCandidate(reason)
+	}
+
+	for _, newReviewer := range available {
+		// Only the first occurrence is replaced, so a reviewer listed twice
+		// can't turn one replacement into two
+		newReviewers := slices.Clone(pr.AssignedReviewers)
+		newReviewers[slices.Index(newReviewers, req.OldUserID)] = newReviewer.UserID
+
+		replaced, err := s.db.ReassignPRReviewers(ctx, pr.PullRequestID, newReviewers, newReviewer.UserID, s.reassignMergedAfter())
+		if err != nil {
+			return nil, reviewerChangeError(err)
+		}
+		if replaced {
+			if pr.AssignedReviewers, err = s.db.GetPRReviewerIDs(ctx, pr.PullRequestID); err != nil {
+				return nil, err
+			}
+			pr.AssignmentAlgorithm = s.cfg.AssignmentStrategy
+			s.recordEvent(ctx, models.AuditEvent{
+				Type:          models.EventReviewerReassign,
+				PullRequestID: pr.PullRequestID,
+				UserID:        newReviewer.UserID,
+				Details:       map[string]string{"old_user_id": req.OldUserID},
+			})
+			s.recordDecision(ctx, models.AssignmentDecision{
+				PullRequestID:  pr.PullRequestID,
+				Kind:           models.DecisionReassign,
+				PoolSize:       len(available),
+				Selected:       []string{newReviewer.UserID},
+				ReplacedUserID: req.OldUserID,
+			})
+			s.notifier.Dispatch(ctx, notify.Event{
+				Type:          notify.EventReviewerReplaced,
+				PullRequestID: pr.PullRequestID,
+				AuthorID:      pr.AuthorID,
+				Reviewers:     pr.AssignedReviewers,
+			})
+			return &models.ReassignResult{
+				PR:              pr,
+				ReplacedBy:      newReviewer.UserID,
+				ReplacementTeam: newReviewer.TeamName,
+			}, nil
+		}
+	}
+
+	// Every candidate was deactivated concurrently
+	return nil, noCandidate(NoCandidateCandidatesUnavailable)
+}
+
+// reassignToCoReviewer is the RELAXED_REASSIGN last resort when nobody new
+// can take over: the old reviewer is dropped and an existing co-reviewer
+// covers for them, flagged in the result
+func (s *Service) reassignToCoReviewer(ctx context.Context, pr *models.PullRequest, oldUserID string) (*models.ReassignResult, error) {
+	remaining := slices.DeleteFunc(slices.Clone(pr.AssignedReviewers), func(reviewer string) bool {
+		return reviewer == oldUserID
+	})
+	if len(remaining) == 0 {
+		return nil, noCandidate(NoCandidateNoCoReviewer)
+	}
+	coReviewer := remaining[0]
+
+	if _, err := s.db.ReassignPRReviewers(ctx, pr.PullRequestID, remaining, "", s.reassignMergedAfter()); err != nil {
+		return nil, reviewerChangeError(err)
+	}
+
+	var err error
+	if pr.AssignedReviewers, err = s.db.GetPRReviewerIDs(ctx, pr.PullRequestID); err != nil {
+		return nil, err
+	}
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:          models.EventReviewerReassign,
+		PullRequestID: pr.PullRequestID,
+		UserID:        coReviewer,
+		Details:       map[string]string{"old_user_id": oldUserID, "fallback": "co_reviewer"},
+	})
+	s.recordDecision(ctx, models.AssignmentDecision{
+		PullRequestID:  pr.PullRequestID,
+		Kind:           models.DecisionReassign,
+		Selected:       []string{coReviewer},
+		ReplacedUserID: oldUserID,
+		Fallback:       "co_reviewer",
+	})
+
+	return &models.ReassignResult{PR: pr, ReplacedBy: coReviewer, CoReviewerFallback: true}, nil
+}
+
+// dropUnreplaceableReviewer is the DROP_UNREPLACEABLE_REVIEWER outcome of a
+// reassign nobody can take over: the old reviewer is removed without a
+// replacement, even when that leaves the PR with no reviewers
+func (s *Service) dropUnreplaceableReviewer(ctx context.Context, pr *models.PullRequest, oldUserID string) (*models.ReassignResult, error) {
+	remaining := slices.DeleteFunc(slices.Clone(pr.AssignedReviewers), func(reviewer string) bool {
+		return reviewer == oldUserID
+	})
+	if _, err := s.db.ReassignPRReviewers(ctx, pr.PullRequestID, remaining, "", s.reassignMergedAfter()); err != nil {
+		return nil, reviewerChangeError(err)
+	}
+
+	var err error
+	if pr.AssignedReviewers, err = s.db.GetPRReviewerIDs(ctx, pr.PullRequestID); err != nil {
+		return nil, err
+	}
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:          models.EventReviewerReassign,
+		PullRequestID: pr.PullRequestID,
+		Details:       map[string]string{"old_user_id": oldUserID, "fallback": "removed"},
+	})
+	s.recordDecision(ctx, models.AssignmentDecision{
+		PullRequestID:  pr.PullRequestID,
+		Kind:           models.DecisionReassign,
+		Selected:       []string{},
+		ReplacedUserID: oldUserID,
+		Fallback:       "removed",
+	})
+
+	return &models.ReassignResult{PR: pr, ReviewerRemoved: true}, nil
+}
+
+// replacementCandidates returns, in random order, the users who could replace
+// oldUserID on the PR: active members of the old reviewer's team who aren't on
+// the PR yet. With no users it returns the NO_CANDIDATE reason instead. The
+// chosen user must still be rechecked under a row lock, as they may be
+// deactivated after the candidates were read
+func (s *Service) replacementCandidates(ctx context.Context, pr *models.PullRequest, oldUserID string) ([]models.User, string, error) {
+	oldReviewer, err := s.db.GetUserByID(ctx, oldUserID)
+	if err != nil {
+		return nil, "", ErrUserNotFound
+	}
+
+	excludeUserID := pr.AuthorID
+	if s.cfg.AllowSelfReview {
+		excludeUserID = ""
+	}
+	candidates, err := s.db.GetActiveUsersByTeam(ctx, oldReviewer.TeamName, excludeUserID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(candidates) == 0 {
+		return nil, NoCandidateNoActiveMembers, nil
+	}
+	eligible := s.eligibleCandidates(candidates, nil)
+	if len(eligible) == 0 {
+		// The calendar busy window is checked before MAX_IDLE, so the idle
+		// check only emptied the set when someone was free per the calendar
+		now := time.Now()
+		free := slices.ContainsFunc(candidates, func(candidate models.User) bool {
+			return candidate.CalendarBusy == nil || !candidate.CalendarBusy.Covers(now)
+		})
+		if !free {
+			return nil, NoCandidateCalendarBusy, nil
+		}
+		return nil, NoCandidateAllIdle, nil
+	}
+	candidates = eligible
+
+	// Filter out everyone already on the PR (the old reviewer included), so a
+	// reassign can never pick a current reviewer and cycle between them
+	excluded := make(map[string]bool, len(pr.AssignedReviewers)+1)
+	for _, reviewer := range pr.AssignedReviewers {
+		excluded[reviewer] = true
+	}
+	excluded[oldUserID] = true
+
+	var available []models.User
+	for _, candidate := range candidates {
+		if !excluded[candidate.UserID] {
+			available = append(available, candidate)
+		}
+	}
+	if len(available) == 0 {
+		return nil, NoCandidateOnlyAuthorAndReviewers, nil
+	}
+
+	author, err := s.db.GetUserByID(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, "", ErrUserNotFound
+	}
+	available = excludeReportingLine(author, available)
+
+	rand.Shuffle(len(available), func(i, j int) {
+		available[i], available[j] = available[j], available[i]
+	})
+	return available, "", nil
+}
+
+// UnassignReviewer removes the reviewer from the PR. With
+// AUTO_REFILL_ON_UNASSIGN, a PR left with fewer than MAX_REVIEWERS reviewers
+// gets a replacement picked like a reassign in the same step; the replacement
+// is returned, empty when there was none. Either way the change is written
+// with the PR locked, so a PR merged or deleted meanwhile is left alone.
+// Unlike reassign there is no after-merge window
+func (s *Service) UnassignReviewer(ctx context.Context, req models.UnassignReviewerRequest) (*models.PullRequest, string, error) {
+	pr, err := s.db.GetPRByID(ctx, req.PullRequestID)
+	if err != nil {
+		return nil, "", ErrPRNotFound
+	}
+
+	if pr.Status == models.PRStatusMerged {
+		return nil, "", ErrPRMerged
+	}
+
+	if !slices.Contains(pr.AssignedReviewers, req.UserID) {
+		return nil, "", ErrReviewerNotAssigned
+	}
+
+	remaining := slices.DeleteFunc(slices.Clone(pr.AssignedReviewers), func(reviewer string) bool {
+		return reviewer == req.UserID
+	})
+
+	var refilledBy string
+	if s.cfg.AutoRefillOnUnassign && len(remaining) < s.cfg.MaxReviewers {
+		available, _, err := s.replacementCandidates(ctx, pr, req.UserID)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, newReviewer := range available {
+			replaced, err := s.db.ReassignPRReviewers(ctx, pr.PullRequestID, append(slices.Clone(remaining), newReviewer.UserID), newReviewer.UserID, nil)
+			if err != nil {
+				return nil, "", reviewerChangeError(err)
+			}
+			if replaced {
+				refilledBy = newReviewer.UserID
+				break
+			}
+		}
+	}
+
+	// Without a replacement the PR is left short-staffed
+	if refilledBy == "" {
+		if _, err := s.db.ReassignPRReviewers(ctx, pr.PullRequestID, remaining, "", nil); err != nil {
+			return nil, "", reviewerChangeError(err)
+		}
+	}
+
+	if pr.AssignedReviewers, err = s.db.GetPRReviewerIDs(ctx, pr.PullRequestID); err != nil {
+		return nil, "", err
+	}
+
+	details := map[string]string{}
+	if refilledBy != "" {
+		details["refilled_by"] = refilledBy
+	}
+	s.recordEvent(ctx, models.AuditEvent{
+		Type:          models.EventReviewerUnassigned,
+		PullRequestID: pr.PullRequestID,
+		UserID:        req.UserID,
+		Details:       details,
+	})
+
+	if refilledBy != "" {
+		pr.AssignmentAlgorithm = s.cfg.AssignmentStrategy
+		s.notifier.Dispatch(ctx, notify.Event{
+			Type:          notify.EventReviewerReplaced,
+			PullRequestID: pr.PullRequestID,
+			AuthorID:      pr.AuthorID,
+			Reviewers:     pr.AssignedReviewers,
+		})
+	}
+
+	return pr, refilledBy, nil
+}
+
+// ApprovePR records the reviewer's approval and returns the PR as stored
+// afterwards together with the reviewer's assignment
+func (s *Service) ApprovePR(ctx context.Context, req models.ApprovePRRequest) (*models.ApproveResult, error) {
+	pr, err := s.db.GetPRByID(ctx, req.PullRequestID)
+	if errors.Is(err, database.ErrPRNotFound) {
+		return nil, ErrPRNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch pr.Status {
+	case models.PRStatusMerged:
+		return nil, ErrPRMerged
+	case models.PRStatusDraft:
+		return nil, ErrPRDraft
+	}
+
+	err = s.db.ApproveReview(ctx, pr.PullRequestID, req.UserID)
+	if errors.Is(err, database.ErrReviewerNotAssigned) {
+		return nil, ErrReviewerNotAssigned
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.TouchUser(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	pr, err = s.db.GetPRByID(ctx, req.PullRequestID)
+	if errors.Is(err, database.ErrPRNotFound) {
+		return nil, ErrPRNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	reviewers, err := s.db.GetPRReviewers(ctx, req.PullRequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ApproveResult{PR: pr}
+	for _, reviewer := range reviewers {
+		if reviewer.UserID == req.UserID {
+			result.Reviewer = &reviewer
+		}
+	}
+	return result, nil
+}
+
+func (s *Service) GetApprovalRate(ctx context.Context, userID string, window time.Duration) (*models.ApprovalRate, error) {
+	exists, err := s.db.UserExists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	assigned, approved, err := s.db.GetApprovalStats(ctx, userID, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	rate := &models.ApprovalRate{
+		UserID:                userID,
+		MergedAssignments:     assigned,
+		Approved:              approved,
+		MergedWithoutApproval: assigned - approved,
+	}
+	if assigned > 0 {
+		rate.ApprovalRate = float64(approved) / float64(assigned)
+	}
+
+	return rate, nil
+}
+
+func (s *Service) GetUserPRs(ctx context.Context, userID string, page models.Page) (*models.UserPRsResponse, error) {
+	// Deep offsets make Postgres scan and discard every skipped row
+	if page.Offset > s.cfg.MaxPageOffset {
+		return nil, ErrOffsetTooLarge
+	}
+
+	// Unknown users keep getting an empty list, without is_active
+	users, err := s.db.GetUsersByIDs(ctx, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+	inactive := len(users) == 1 && !users[0].IsActive
+
+	var prs []models.PullRequest
+	if !inactive || s.cfg.InactiveReviewerPRs != config.InactiveReviewerHide {
+		if prs, err = s.db.GetPRsByReviewer(ctx, userID, page); err != nil {
+			return nil, err
+		}
+	}
+
+	response := userPRsResponse(userID, prs, page)
+	if len(users) == 1 {
+		response.IsActive = &users[0].IsActive
+	}
+	if inactive && s.cfg.InactiveReviewerPRs != config.InactiveReviewerShow {
+		response.Warning = models.WarningReviewerInactive
+	}
+	return response, nil
+}
+
+// GetPendingReviews is GetUserPRs limited to the reviewer's to-do list: open
+// PRs they haven't approved yet
+func (s *Service) GetPendingReviews(ctx context.Context, userID string, page models.Page) (*models.UserPRsResponse, error) {
+	if page.Offset > s.cfg.MaxPageOffset {
+		return nil, ErrOffsetTooLarge
+	}
+
+	exists, err := s.db.UserExists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	prs, err := s.db.GetPendingReviews(ctx, userID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return userPRsResponse(userID, prs, page), nil
+}
+
+// userPRsResponse converts a page of the user's PRs to the short format
+func userPRsResponse(userID string, prs []models.PullRequest, page models.Page) *models.UserPRsResponse {
+	return &models.UserPRsResponse{
+		UserID:       userID,
+		PullRequests: shortPRs(prs),
+		NextCursor:   nextCursor(prs, page),
+	}
+}
+
+// shortPRs converts PRs to the short format
+func shortPRs(prs []models.PullRequest) []models.PullRequestShort {
+	short := []models.PullRequestShort{}
+	for _, pr := range prs {
+		short = append(short, models.PullRequestShort{
+			PullRequestID:   pr.PullRequestID,
+			PullRequestName: pr.PullRequestName,
+			AuthorID:        pr.AuthorID,
+			Status:          pr.Status,
+		})
+	}
+	return short
+}
+
+// nextCursor returns the cursor continuing after a full page, "" after the last
+func nextCursor(prs []models.PullRequest, page models.Page) string {
+	if page.Limit > 0 && len(prs) == page.Limit {
+		return models.NewPRCursor(prs[len(prs)-1]).Encode()
+	}
+	return ""
+}
+
+// MaxReviewersFilter bounds the users of a /pullRequest/byReviewers query
+const MaxReviewersFilter = 50
+
+// GetPRsByReviewers returns the PRs reviewed by all (mode "all") or any (mode
+// "any") of the users, oldest first. userIDs must be free of duplicates
+func (s *Service) GetPRsByReviewers(ctx context.Context, userIDs []string, mode string, page models.Page) (*models.ReviewersPRsResponse, error) {
+	if mode != models.ReviewersMatchAll && mode != models.ReviewersMatchAny {
+		return nil, ErrInvalidMode
+	}
+	if len(userIDs) == 0 || len(userIDs) > MaxReviewersFilter {
+		return nil, ErrInvalidUserList
+	}
+	if page.Offset > s.cfg.MaxPageOffset {
+		return nil, ErrOffsetTooLarge
+	}
+
+	prs, err := s.db.GetPRsByReviewers(ctx, userIDs, mode == models.ReviewersMatchAll, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ReviewersPRsResponse{
+		UserIDs:      userIDs,
+		Mode:         mode,
+		PullRequests: shortPRs(prs),
+		NextCursor:   nextCursor(prs, page),
+	}, nil
+}
+
+// ListPRs calls fn for every PR matching the filters, oldest first
+func (s *Service) ListPRs(ctx context.Context, status, authorID string, fn func(models.PullRequest) error) error {
+	if status != "" && !models.PullRequestStatus(status).IsValid() {
+		return ErrInvalidStatus
+	}
+	return s.db.ListPRs(ctx, status, authorID, fn)
+}
+
+// GetReassignments returns the PR's reviewer replacements in the order they happened
+func (s *Service) GetReassignments(ctx context.Context, prID string) (*models.PRReassignmentsResponse, error) {
+	exists, err := s.db.PRExists(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrPRNotFound
+	}
+
+	reassignments, err := s.db.GetReassignments(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PRReassignmentsResponse{
+		PullRequestID: prID,
+		Reassignments: models.EmptyIfNil(reassignments),
+	}, nil
+}
+
+// GetCoverageGaps lists open PRs across the org left without an active reviewer
+func (s *Service) GetCoverageGaps(ctx context.Context, page models.Page) (*models.CoverageGapsResponse, error) {
+	if page.Offset > s.cfg.MaxPageOffset {
+		return nil, ErrOffsetTooLarge
+	}
+
+	gaps, err := s.db.GetCoverageGaps(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.CoverageGapsResponse{PullRequests: models.EmptyIfNil(gaps)}
+	if page.Limit > 0 && len(gaps) == page.Limit {
+		last := gaps[len(gaps)-1]
+		response.NextCursor = models.NewPRCursor(models.PullRequest{
+			PullRequestID: last.PullRequestID,
+			CreatedAt:     last.CreatedAt,
+		}).Encode()
+	}
+	return response, nil
+}
+
+// GetSoleReviewerPRs returns open PRs where the user is the only reviewer,
+// so leads can add a backup before that person is away
+func (s *Service) GetSoleReviewerPRs(ctx context.Context, userID string) (*models.UserPRsResponse, error) {
+	exists, err := s.db.UserExists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	prs, err := s.db.GetSoleReviewerPRs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserPRsResponse{
+		UserID:       userID,
+		PullRequests: models.EmptyIfNil(prs),
+	}, nil
+}
+
+// Pool methods
+func (s *Service) CreatePool(ctx context.Context, req models.CreatePoolRequest) (*models.ReviewerPool, error) {
+	exists, err := s.db.PoolExists(ctx, req.PoolName)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrPoolExists
+	}
+
+	pool := &models.ReviewerPool{PoolName: req.PoolName}
+	for _, userID := range req.MemberIDs {
+		user, err := s.db.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		pool.Members = append(pool.Members, *user)
+	}
+
+	if err := s.db.CreatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	return s.db.GetPoolByName(ctx, req.PoolName)
+}
+
+func (s *Service) GetPool(ctx context.Context, poolName string) (*models.ReviewerPool, error) {
+	pool, err := s.db.GetPoolByName(ctx, poolName)
+	if err != nil {
+		return nil, ErrPoolNotFound
+	}
+	return pool, nil
+}
+
+func (s *Service) AddPoolMember(ctx context.Context, req models.PoolMemberRequest) (*models.ReviewerPool, error) {
+	exists, err := s.db.PoolExists(ctx, req.PoolName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+
+	exists, err = s.db.UserExists(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.db.AddPoolMember(ctx, req.PoolName, req.UserID); err != nil {
+		return nil, err
+	}
+
+	return s.db.GetPoolByName(ctx, req.PoolName)
+}
+
+func (s *Service) RemovePoolMember(ctx context.Context, req models.PoolMemberRequest) (*models.ReviewerPool, error) {
+	exists, err := s.db.PoolExists(ctx, req.PoolName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+
+	if err := s.db.RemovePoolMember(ctx, req.PoolName, req.UserID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return s.db.GetPoolByName(ctx, req.PoolName)
+}
+
+// WriteMetrics writes the service metrics in the Prometheus text format
+func (s *Service) WriteMetrics(w io.Writer) error {
+	return s.db.WriteQueryMetrics(w)
+}
+
+// Reconcile reports rows whose references no longer resolve and, with
+// req.Fix, repairs what can be repaired. A fix is audited
+func (s *Service) Reconcile(ctx context.Context, req models.ReconcileRequest) (*models.ReconcileReport, error) {
+	report, err := s.db.Reconcile(ctx, req.Fix)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Fixed {
+		s.recordEvent(ctx, models.AuditEvent{
+			Type: models.EventReconciled,
+			Details: map[string]string{
+				"teams_created":     strings.Join(report.TeamsCreated, ","),
+				"reviewers_removed": strconv.FormatInt(report.ReviewersRemoved, 10),
+			},
+		})
+	}
+	return report, nil
+}
+
+// GetSchemaInfo returns the latest applied migration and the full list
+func (s *Service) GetSchemaInfo(ctx context.Context) (*models.SchemaInfo, error) {
+	migrations, err := s.db.GetAppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &models.SchemaInfo{Migrations: migrations}
+	if len(migrations) > 0 {
+		info.Version = migrations[len(migrations)-1].Version
+	}
+
+	return info, nil
+}
+
+func (s *Service) CheckHealth(ctx context.Context) error {
+	return s.db.HealthCheck(ctx)
+}
+
+// Errors matching OpenAPI spec
+var (
+	ErrTeamExists          = errors.New("TEAM_EXISTS")
+	ErrTeamNotFound        = errors.New("NOT_FOUND")
+	ErrUserNotFound        = errors.New("NOT_FOUND")
+	ErrPRExists            = errors.New("PR_EXISTS")
+	ErrPRNotFound          = errors.New("NOT_FOUND")
+	ErrPRMerged            = errors.New("PR_MERGED")
+	ErrReviewerNotAssigned = errors.New("NOT_ASSIGNED")
+	ErrNoCandidate         = errors.New("NO_CANDIDATE")
+	ErrPoolExists          = errors.New("POOL_EXISTS")
+	ErrPoolNotFound        = errors.New("NOT_FOUND")
+	ErrBatchTooLarge       = errors.New("INVALID_INPUT")
+	ErrAuthorReview        = errors.New("AUTHOR_REVIEW")
+	ErrAlreadyAssigned     = errors.New("ALREADY_ASSIGNED")
+	ErrTooManyReviewers    = errors.New("TOO_MANY_REVIEWERS")
+	ErrInvalidStatus       = errors.New("INVALID_INPUT")
+	ErrMentorInactive      = errors.New("INVALID_INPUT")
+	ErrOffsetTooLarge      = errors.New("INVALID_INPUT")
+	ErrInvalidBucket       = errors.New("INVALID_INPUT")
+	ErrUserInAnotherTeam   = errors.New("USER_IN_ANOTHER_TEAM")
+	ErrAuthorInactive      = errors.New("AUTHOR_INACTIVE")
+	ErrPRDraft             = errors.New("PR_DRAFT")
+	ErrInvalidMode         = errors.New("INVALID_INPUT")
+	ErrInvalidUserList     = errors.New("INVALID_INPUT")
+	ErrInvalidBusyWindow   = errors.New("INVALID_INPUT")
+	ErrPRNameTooLong       = errors.New("INVALID_INPUT")
+)
+
+// Reasons reported with NO_CANDIDATE for why nobody could be picked
+const (
+	NoCandidateNoActiveMembers        = "no-active-members"
+	NoCandidateAllIdle                = "all-idle"
+	NoCandidateCalendarBusy           = "calendar-busy"
+	NoCandidateOnlyAuthorAndReviewers = "only-author-and-reviewers"
+	NoCandidateNotEnoughTeams         = "not-enough-teams"
+	NoCandidateNotEnoughCandidates    = "not-enough-candidates"
+	NoCandidateCandidatesUnavailable  = "candidates-unavailable"
+	NoCandidateNoCoReviewer           = "no-co-reviewer"
+)
+
+var noCandidateMessages = map[string]string{
+	NoCandidateNoActiveMembers:        "team has no other active members accepting reviews",
+	NoCandidateAllIdle:                "all candidates are idle for longer than MAX_IDLE",
+	NoCandidateCalendarBusy:           "all candidates are busy per their calendar",
+	NoCandidateOnlyAuthorAndReviewers: "team has only the author and current reviewers",
+	NoCandidateNotEnoughTeams:         "not enough candidates from distinct teams",
+	NoCandidateNotEnoughCandidates:    "fewer candidates than MAX_REVIEWERS",
+	NoCandidateCandidatesUnavailable:  "picked candidates kept becoming unavailable while assigning",
+	NoCandidateNoCoReviewer:           "no active replacement candidate or co-reviewer",
+}
+
+// NoCandidateError is ErrNoCandidate with the reason nobody could be picked,
+// computed from the selection state. errors.Is matches it to ErrNoCandidate
+type NoCandidateError struct {
+	Reason string
+}
+
+func noCandidate(reason string) error {
+	return &NoCandidateError{Reason: reason}
+}
+
+func (e *NoCandidateError) Error() string {
+	return ErrNoCandidate.Error()
+}
+
+func (e *NoCandidateError) Is(target error) bool {
+	return target == ErrNoCandidate
+}
+
+// Message describes the reason for people
+func (e *NoCandidateError) Message() string {
+	return noCandidateMessages[e.Reason]
+}
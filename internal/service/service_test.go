@@ -0,0 +1,865 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"review-service/internal/config"
+	"review-service/internal/models"
+)
+
+func TestReassignReviewerOnlyAuthorAndReviewers(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{
+		PullRequestID:     "pr-1",
+		AuthorID:          "author",
+		Status:            models.PRStatusOpen,
+		AssignedReviewers: []string{"r1", "r2"},
+	})
+	svc := NewService(store, testConfig())
+
+	_, err := svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"})
+	if !errors.Is(err, ErrNoCandidate) {
+		t.Fatalf("ReassignReviewer error = %v, want NO_CANDIDATE", err)
+	}
+	var noCandidate *NoCandidateError
+	if !errors.As(err, &noCandidate) || noCandidate.Reason != NoCandidateOnlyAuthorAndReviewers {
+		t.Errorf("reason = %v, want %s", err, NoCandidateOnlyAuthorAndReviewers)
+	}
+
+	pr, _ := store.GetPRByID(context.Background(), "pr-1")
+	if !slices.Equal(pr.AssignedReviewers, []string{"r1", "r2"}) {
+		t.Errorf("reviewers changed to %v", pr.AssignedReviewers)
+	}
+}
+
+func TestReassignReviewerCalendarBusy(t *testing.T) {
+	now := time.Now()
+	busy := &models.BusyWindow{Start: *models.NewTimestamp(now.Add(-time.Hour)), End: *models.NewTimestamp(now.Add(time.Hour))}
+	users := []models.User{member("author", "backend"), member("r1", "backend"), member("r2", "backend")}
+	users[1].CalendarBusy = busy
+	users[2].CalendarBusy = busy
+	store := newFakeStore(users...)
+	store.addPR(models.PullRequest{
+		PullRequestID:     "pr-1",
+		AuthorID:          "author",
+		Status:            models.PRStatusOpen,
+		AssignedReviewers: []string{"r1"},
+	})
+	cfg := testConfig()
+	cfg.MaxIdle = 24 * time.Hour
+	svc := NewService(store, cfg)
+
+	_, err := svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"})
+	var noCandidate *NoCandidateError
+	if !errors.As(err, &noCandidate) || noCandidate.Reason != NoCandidateCalendarBusy {
+		t.Errorf("everyone busy: error = %v, want NO_CANDIDATE %s", err, NoCandidateCalendarBusy)
+	}
+
+	// Free per the calendar but idle, so MAX_IDLE is what emptied the set
+	users[2].CalendarBusy = nil
+	users[2].LastActiveAt = models.NewTimestamp(now.Add(-48 * time.Hour))
+	store.users["r2"] = users[2]
+	_, err = svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"})
+	if !errors.As(err, &noCandidate) || noCandidate.Reason != NoCandidateAllIdle {
+		t.Errorf("idle member: error = %v, want NO_CANDIDATE %s", err, NoCandidateAllIdle)
+	}
+}
+
+func TestReassignReviewerPicksNewMember(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+	store.addPR(models.PullRequest{
+		PullRequestID:     "pr-1",
+		AuthorID:          "author",
+		Status:            models.PRStatusOpen,
+		AssignedReviewers: []string{"r1", "r2"},
+	})
+	svc := NewService(store, testConfig())
+
+	result, err := svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"})
+	if err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if result.ReplacedBy != "r3" {
+		t.Errorf("replaced by %q, want r3", result.ReplacedBy)
+	}
+	if !slices.Equal(result.PR.AssignedReviewers, []string{"r3", "r2"}) {
+		t.Errorf("reviewers = %v, want [r3 r2]", result.PR.AssignedReviewers)
+	}
+}
+
+func TestReassignReviewerNotAssigned(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	svc := NewService(store, testConfig())
+
+	_, err := svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "author"})
+	if err != ErrReviewerNotAssigned {
+		t.Errorf("error = %v, want ErrReviewerNotAssigned", err)
+	}
+}
+
+func TestReassignReviewerReplacementDeactivated(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	svc := NewService(store, testConfig())
+
+	// The first replacement picked is deactivated before the change is written
+	var deactivated string
+	store.beforeReassign = func(replacementID string) {
+		if deactivated == "" {
+			deactivated = replacementID
+			store.setActive(replacementID, false)
+		}
+	}
+
+	result, err := svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"})
+	if err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if result.ReplacedBy == deactivated || !slices.Contains([]string{"r2", "r3"}, result.ReplacedBy) {
+		t.Errorf("replaced by %q after %q was deactivated, want the other member", result.ReplacedBy, deactivated)
+	}
+	if !slices.Equal(result.PR.AssignedReviewers, []string{result.ReplacedBy}) {
+		t.Errorf("reviewers = %v, want [%s]", result.PR.AssignedReviewers, result.ReplacedBy)
+	}
+
+	// With nobody else left the reassign fails and the reviewers stay
+	store.setActive(deactivated, true)
+	store.beforeReassign = func(replacementID string) { store.setActive(replacementID, false) }
+	_, err = svc.ReassignReviewer(context.Background(), models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: result.ReplacedBy})
+	var noCandidate *NoCandidateError
+	if !errors.As(err, &noCandidate) || noCandidate.Reason != NoCandidateCandidatesUnavailable {
+		t.Errorf("all replacements deactivated: error = %v, want NO_CANDIDATE %s", err, NoCandidateCandidatesUnavailable)
+	}
+	pr, _ := store.GetPRByID(context.Background(), "pr-1")
+	if !slices.Equal(pr.AssignedReviewers, []string{result.ReplacedBy}) {
+		t.Errorf("reviewers changed to %v", pr.AssignedReviewers)
+	}
+}
+
+func TestUnassignReviewer(t *testing.T) {
+	for _, refill := range []bool{false, true} {
+		store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+		store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+		cfg := testConfig()
+		cfg.AutoRefillOnUnassign = refill
+		svc := NewService(store, cfg)
+
+		pr, refilledBy, err := svc.UnassignReviewer(context.Background(), models.UnassignReviewerRequest{PullRequestID: "pr-1", UserID: "r1"})
+		if err != nil {
+			t.Fatalf("refill %v: UnassignReviewer: %v", refill, err)
+		}
+		want := []string{"r2"}
+		if refill {
+			want = []string{"r2", "r3"}
+		}
+		if slices.Sort(pr.AssignedReviewers); !slices.Equal(pr.AssignedReviewers, want) {
+			t.Errorf("refill %v: reviewers = %v, want %v", refill, pr.AssignedReviewers, want)
+		}
+		if refill && refilledBy != "r3" || !refill && refilledBy != "" {
+			t.Errorf("refill %v: refilled by %q", refill, refilledBy)
+		}
+	}
+}
+
+func TestUnassignReviewerMergedMeanwhile(t *testing.T) {
+	for _, refill := range []bool{false, true} {
+		store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+		store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+		cfg := testConfig()
+		cfg.AutoRefillOnUnassign = refill
+		svc := NewService(store, cfg)
+		ctx := context.Background()
+
+		// The PR is merged after it was read but before the change is written
+		store.beforeReassign = func(string) { store.MergePR(ctx, "pr-1") }
+		_, _, err := svc.UnassignReviewer(ctx, models.UnassignReviewerRequest{PullRequestID: "pr-1", UserID: "r1"})
+		if err != ErrPRMerged {
+			t.Errorf("refill %v: error = %v, want ErrPRMerged", refill, err)
+		}
+		pr, _ := store.GetPRByID(ctx, "pr-1")
+		if !slices.Equal(pr.AssignedReviewers, []string{"r1", "r2"}) {
+			t.Errorf("refill %v: reviewers of the merged PR changed to %v", refill, pr.AssignedReviewers)
+		}
+		if events := store.eventsOfType(models.EventReviewerUnassigned); len(events) != 0 {
+			t.Errorf("refill %v: recorded %d unassign events, want none", refill, len(events))
+		}
+	}
+}
+
+func TestUnassignReviewerDeletedMeanwhile(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+	svc := NewService(store, testConfig())
+	ctx := context.Background()
+
+	store.beforeReassign = func(string) { store.SoftDeletePR(ctx, "pr-1") }
+	_, _, err := svc.UnassignReviewer(ctx, models.UnassignReviewerRequest{PullRequestID: "pr-1", UserID: "r1"})
+	if err != ErrPRNotFound {
+		t.Errorf("error = %v, want ErrPRNotFound", err)
+	}
+}
+
+func TestReassignReviewerRacesMerge(t *testing.T) {
+	users := []models.User{
+		member("author", "backend"), member("r1", "backend"), member("r2", "backend"),
+		member("r3", "backend"), member("r4", "backend"), member("r5", "backend"),
+	}
+	ctx := context.Background()
+
+	// A merge landing between the read and the write is reported as PR_MERGED
+	store := newFakeStore(users...)
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+	svc := NewService(store, testConfig())
+	store.beforeReassign = func(string) { store.MergePR(ctx, "pr-1") }
+	if _, err := svc.ReassignReviewer(ctx, models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"}); err != ErrPRMerged {
+		t.Errorf("merged mid-reassign: error = %v, want ErrPRMerged", err)
+	}
+	if pr, _ := store.GetPRByID(ctx, "pr-1"); !slices.Equal(pr.AssignedReviewers, []string{"r1", "r2"}) {
+		t.Errorf("reviewers of the merged PR changed to %v", pr.AssignedReviewers)
+	}
+
+	// Two reassigns racing a merge either finish first or see the PR merged,
+	// never missing
+	for i := range 50 {
+		store := newFakeStore(users...)
+		store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+		svc := NewService(store, testConfig())
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for j, oldUserID := range []string{"r1", "r2"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[j] = svc.ReassignReviewer(ctx, models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: oldUserID})
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := svc.MergePR(ctx, "pr-1"); err != nil {
+				t.Errorf("run %d: MergePR: %v", i, err)
+			}
+		}()
+		wg.Wait()
+
+		for j, err := range errs {
+			if err != nil && err != ErrPRMerged {
+				t.Errorf("run %d: reassign %d: error = %v, want nil or ErrPRMerged", i, j, err)
+			}
+		}
+		pr, err := store.GetPRByID(ctx, "pr-1")
+		if err != nil || pr.Status != models.PRStatusMerged {
+			t.Fatalf("run %d: PR = %+v, %v, want it merged", i, pr, err)
+		}
+		if len(slices.Compact(slices.Sorted(slices.Values(pr.AssignedReviewers)))) != 2 {
+			t.Errorf("run %d: reviewers = %v, want two distinct", i, pr.AssignedReviewers)
+		}
+	}
+}
+
+func TestMergePRIsIdempotent(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	svc := NewService(store, testConfig())
+
+	pr, alreadyMerged, err := svc.MergePR(context.Background(), "pr-1")
+	if err != nil {
+		t.Fatalf("first MergePR: %v", err)
+	}
+	if alreadyMerged || pr.Status != models.PRStatusMerged || pr.MergedAt == nil {
+		t.Errorf("first merge = %s merged_at %v already_merged %v, want MERGED with merged_at and false", pr.Status, pr.MergedAt, alreadyMerged)
+	}
+
+	pr, alreadyMerged, err = svc.MergePR(context.Background(), "pr-1")
+	if err != nil {
+		t.Fatalf("repeated MergePR: %v", err)
+	}
+	if !alreadyMerged || pr.Status != models.PRStatusMerged {
+		t.Errorf("repeated merge = %s already_merged %v, want MERGED and true", pr.Status, alreadyMerged)
+	}
+
+	if events := store.eventsOfType(models.EventPRMerged); len(events) != 1 {
+		t.Errorf("recorded %d merge events, want 1", len(events))
+	}
+	if store.webhookLookups != 1 {
+		t.Errorf("dispatched %d merge notifications, want 1", store.webhookLookups)
+	}
+}
+
+func TestCreatePRWithoutReview(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	svc := NewService(store, testConfig())
+	ctx := context.Background()
+
+	reviewRequired := false
+	pr, err := svc.CreatePR(ctx, models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Fix typo", AuthorID: "author", ReviewRequired: &reviewRequired})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 0 || pr.PendingAssignment || pr.ReviewRequired == nil || *pr.ReviewRequired {
+		t.Errorf("PR = reviewers %v pending %v review_required %v, want none, false and false", pr.AssignedReviewers, pr.PendingAssignment, pr.ReviewRequired)
+	}
+	if len(store.decisions) != 0 {
+		t.Errorf("recorded assignment decisions %+v, want none", store.decisions)
+	}
+
+	// Merging never waits for approvals
+	merged, _, err := svc.MergePR(ctx, "pr-1")
+	if err != nil || merged.Status != models.PRStatusMerged {
+		t.Errorf("MergePR = %v, %v, want MERGED", merged, err)
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	inactive := member("r3", "frontend")
+	inactive.IsActive = false
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "frontend"), inactive)
+	store.pools = map[string][]string{"security": {"r2", "r3"}}
+	cfg := testConfig()
+	svc := NewService(store, cfg)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		reviewers []string
+		pools     []string
+		want      []string
+	}{
+		{"one reviewer short", []string{"r1"}, nil, []string{models.PolicyRequiredCount}},
+		{"inactive reviewer doesn't count", []string{"r1", "r3"}, nil, []string{models.PolicyRequiredCount}},
+		{"enough reviewers", []string{"r1", "r2"}, nil, []string{}},
+		{"required pool covered", []string{"r1", "r2"}, []string{"security"}, []string{}},
+		{"required pool only by inactive member", []string{"r1", "r3"}, []string{"security"}, []string{models.PolicyRequiredCount, models.PolicyRequiredGroups}},
+		{"required pool without members", []string{"r1", "r2"}, []string{"security", "dba"}, []string{models.PolicyRequiredGroups}},
+	}
+	for _, tt := range tests {
+		cfg.RequiredReviewerPools = tt.pools
+		pr := &models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", AssignedReviewers: tt.reviewers}
+		if err := svc.EvaluatePolicies(ctx, pr); err != nil {
+			t.Fatalf("%s: EvaluatePolicies: %v", tt.name, err)
+		}
+		if pr.PolicySatisfied == nil || *pr.PolicySatisfied != (len(tt.want) == 0) || !slices.Equal(pr.UnmetPolicies, tt.want) {
+			t.Errorf("%s: policy_satisfied = %v, unmet = %v, want %v", tt.name, pr.PolicySatisfied, pr.UnmetPolicies, tt.want)
+		}
+	}
+
+	// Without review required there is nothing to meet
+	reviewRequired := false
+	pr := &models.PullRequest{PullRequestID: "pr-2", AuthorID: "author", ReviewRequired: &reviewRequired}
+	if err := svc.EvaluatePolicies(ctx, pr); err != nil || pr.PolicySatisfied == nil || !*pr.PolicySatisfied {
+		t.Errorf("review not required: policy_satisfied = %v, %v, want true", pr.PolicySatisfied, err)
+	}
+}
+
+func TestMergePRConcurrent(t *testing.T) {
+	store := newFakeStore(member("author", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen})
+	svc := NewService(store, testConfig())
+
+	const callers = 8
+	var wg sync.WaitGroup
+	results := make(chan bool, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, alreadyMerged, err := svc.MergePR(context.Background(), "pr-1")
+			if err != nil {
+				t.Errorf("MergePR: %v", err)
+				return
+			}
+			results <- alreadyMerged
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	merged := 0
+	for alreadyMerged := range results {
+		if !alreadyMerged {
+			merged++
+		}
+	}
+	if merged != 1 {
+		t.Errorf("%d callers merged the PR, want exactly 1", merged)
+	}
+	if events := store.eventsOfType(models.EventPRMerged); len(events) != 1 {
+		t.Errorf("recorded %d merge events, want 1", len(events))
+	}
+}
+
+func TestMergePRDraft(t *testing.T) {
+	store := newFakeStore(member("author", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusDraft})
+	svc := NewService(store, testConfig())
+
+	if _, _, err := svc.MergePR(context.Background(), "pr-1"); err != ErrPRDraft {
+		t.Errorf("error = %v, want ErrPRDraft", err)
+	}
+	if _, _, err := svc.MergePR(context.Background(), "missing"); err != ErrPRNotFound {
+		t.Errorf("missing PR error = %v, want ErrPRNotFound", err)
+	}
+}
+
+func TestDeletePR(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	svc := NewService(store, testConfig())
+	ctx := context.Background()
+
+	pr, err := svc.DeletePR(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("DeletePR: %v", err)
+	}
+	if pr.DeletedAt == nil {
+		t.Error("deleted_at not set")
+	}
+
+	if _, err := svc.GetPR(ctx, "pr-1", false); err != ErrPRNotFound {
+		t.Errorf("GetPR after delete: error = %v, want ErrPRNotFound", err)
+	}
+	if _, _, err := svc.MergePR(ctx, "pr-1"); err != ErrPRNotFound {
+		t.Errorf("MergePR after delete: error = %v, want ErrPRNotFound", err)
+	}
+	if _, err := svc.ReassignReviewer(ctx, models.ReassignReviewerRequest{PullRequestID: "pr-1", OldUserID: "r1"}); err != ErrPRNotFound {
+		t.Errorf("ReassignReviewer after delete: error = %v, want ErrPRNotFound", err)
+	}
+	if _, err := svc.DeletePR(ctx, "pr-1"); err != ErrPRNotFound {
+		t.Errorf("second DeletePR: error = %v, want ErrPRNotFound", err)
+	}
+
+	// A failed lookup is reported as such, not as a missing PR
+	store.prErr = errors.New("connection reset")
+	if _, err := svc.DeletePR(ctx, "pr-1"); err == nil || errors.Is(err, ErrPRNotFound) {
+		t.Errorf("failed lookup: error = %v, want the database error", err)
+	}
+}
+
+func TestRunPurgeWorker(t *testing.T) {
+	store := newFakeStore(member("author", "backend"))
+	now := time.Now()
+	for prID, deletedAt := range map[string]*models.Timestamp{
+		"pr-old":    models.NewTimestamp(now.Add(-2 * time.Hour)),
+		"pr-recent": models.NewTimestamp(now.Add(-time.Minute)),
+		"pr-live":   nil,
+	} {
+		store.addPR(models.PullRequest{PullRequestID: prID, AuthorID: "author", Status: models.PRStatusOpen, DeletedAt: deletedAt})
+	}
+	svc := NewService(store, testConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.RunPurgeWorker(ctx, 5*time.Millisecond, time.Hour)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		_, old := store.prs["pr-old"]
+		store.mu.Unlock()
+		if !old {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pr-old past retention was not purged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	for _, prID := range []string{"pr-recent", "pr-live"} {
+		if _, ok := store.prs[prID]; !ok {
+			t.Errorf("%s within retention was purged", prID)
+		}
+	}
+}
+
+func TestCreatePRDryRun(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-taken", AuthorID: "author", Status: models.PRStatusOpen})
+	svc := NewService(store, testConfig())
+	ctx := context.Background()
+
+	pr, err := svc.CreatePR(ctx, models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Add search", AuthorID: "author", DryRun: true})
+	if err != nil {
+		t.Fatalf("dry-run CreatePR: %v", err)
+	}
+	reviewers := slices.Sorted(slices.Values(pr.AssignedReviewers))
+	if !slices.Equal(reviewers, []string{"r1", "r2"}) {
+		t.Errorf("would-be reviewers = %v, want [r1 r2]", pr.AssignedReviewers)
+	}
+	if _, ok := store.prs["pr-1"]; ok {
+		t.Error("dry run stored the PR")
+	}
+	if len(store.events) != 0 || len(store.decisions) != 0 {
+		t.Errorf("dry run recorded events %+v and decisions %+v", store.events, store.decisions)
+	}
+
+	// Conflicts are still reported
+	_, err = svc.CreatePR(ctx, models.CreatePRRequest{PullRequestID: "pr-taken", PullRequestName: "Again", AuthorID: "author", DryRun: true})
+	if err != ErrPRExists {
+		t.Errorf("dry run over a taken ID: error = %v, want ErrPRExists", err)
+	}
+}
+
+func TestApprovePR(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1", "r2"}})
+	store.addPR(models.PullRequest{PullRequestID: "pr-2", AuthorID: "author", Status: models.PRStatusDraft, AssignedReviewers: []string{"r1"}})
+	svc := NewService(store, testConfig())
+	ctx := context.Background()
+
+	result, err := svc.ApprovePR(ctx, models.ApprovePRRequest{PullRequestID: "pr-1", UserID: "r1"})
+	if err != nil {
+		t.Fatalf("ApprovePR: %v", err)
+	}
+	if result.PR.PullRequestID != "pr-1" || result.Reviewer == nil || result.Reviewer.ApprovedAt == nil {
+		t.Errorf("result = %+v, want pr-1 with r1's approval", result)
+	}
+
+	if _, err := svc.ApprovePR(ctx, models.ApprovePRRequest{PullRequestID: "pr-1", UserID: "author"}); err != ErrReviewerNotAssigned {
+		t.Errorf("not assigned: error = %v, want ErrReviewerNotAssigned", err)
+	}
+	if _, err := svc.ApprovePR(ctx, models.ApprovePRRequest{PullRequestID: "pr-2", UserID: "r1"}); err != ErrPRDraft {
+		t.Errorf("draft: error = %v, want ErrPRDraft", err)
+	}
+
+	// A failed update is not a missing assignment
+	store.approveErr = errors.New("connection reset")
+	if _, err := svc.ApprovePR(ctx, models.ApprovePRRequest{PullRequestID: "pr-1", UserID: "r2"}); err == nil || err == ErrReviewerNotAssigned {
+		t.Errorf("failed update: error = %v, want the database error", err)
+	}
+}
+
+func TestAssignReviewerCap(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"), member("r2", "backend"), member("r3", "backend"))
+	store.addPR(models.PullRequest{PullRequestID: "pr-1", AuthorID: "author", Status: models.PRStatusOpen})
+	cfg := testConfig()
+	cfg.MaxReviewers = 3
+	// The DB cap is lower, as if a concurrent assign slipped past the service check
+	store.reviewerCap = 2
+	svc := NewService(store, cfg)
+	ctx := context.Background()
+
+	for _, reviewerID := range []string{"r1", "r2"} {
+		if _, err := svc.AssignReviewer(ctx, models.AssignReviewerRequest{PullRequestID: "pr-1", UserID: reviewerID}); err != nil {
+			t.Fatalf("assign %s: %v", reviewerID, err)
+		}
+	}
+	if _, err := svc.AssignReviewer(ctx, models.AssignReviewerRequest{PullRequestID: "pr-1", UserID: "r3"}); err != ErrTooManyReviewers {
+		t.Errorf("assign over the DB cap: error = %v, want ErrTooManyReviewers", err)
+	}
+
+	cfg.MaxReviewers = 2
+	if _, err := svc.AssignReviewer(ctx, models.AssignReviewerRequest{PullRequestID: "pr-1", UserID: "r3"}); err != ErrTooManyReviewers {
+		t.Errorf("assign over the service cap: error = %v, want ErrTooManyReviewers", err)
+	}
+}
+
+func TestGetApprovalRate(t *testing.T) {
+	store := newFakeStore(member("author", "backend"), member("r1", "backend"))
+	merged := func(prID string, at time.Time, approved bool) {
+		store.addPR(models.PullRequest{
+			PullRequestID:     prID,
+			AuthorID:          "author",
+			Status:            models.PRStatusMerged,
+			MergedAt:          models.NewTimestamp(at),
+			AssignedReviewers: []string{"r1"},
+		})
+		if approved {
+			store.approvals[prID] = map[string]time.Time{"r1": at.Add(-time.Hour)}
+		}
+	}
+	now := time.Now()
+	merged("pr-1", now.Add(-24*time.Hour), true)
+	merged("pr-2", now.Add(-48*time.Hour), false)
+	merged("pr-3", now.Add(-72*time.Hour), true)
+	merged("pr-old", now.Add(-200*24*time.Hour), false)
+	store.addPR(models.PullRequest{PullRequestID: "pr-open", AuthorID: "author", Status: models.PRStatusOpen, AssignedReviewers: []string{"r1"}})
+	svc := NewService(store, testConfig())
+
+	rate, err := svc.GetApprovalRate(context.Background(), "r1", 90*24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetApprovalRate: %v", err)
+	}
+	if rate.MergedAssignments != 3 || rate.Approved != 2 || rate.MergedWithoutApproval != 1 {
+		t.Errorf("rate = %+v, want 2 of 3 merged assignments approved", rate)
+	}
+	if rate.ApprovalRate < 0.66 || rate.ApprovalRate > 0.67 {
+		t.Errorf("ApprovalRate = %v, want 2/3", rate.ApprovalRate)
+	}
+
+	if _, err := svc.GetApprovalRate(context.Background(), "nobody", time.Hour); err != ErrUserNotFound {
+		t.Errorf("unknown user: error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestEligibleCandidates(t *testing.T) {
+	now := time.Now()
+	busy := member("busy", "backend")
+	busy.CalendarBusy = &models.BusyWindow{Start: models.Timestamp{Time: now.Add(-time.Hour)}, End: models.Timestamp{Time: now.Add(time.Hour)}}
+	wasBusy := member("was-busy", "backend")
+	wasBusy.CalendarBusy = &models.BusyWindow{Start: models.Timestamp{Time: now.Add(-2 * time.Hour)}, End: models.Timestamp{Time: now.Add(-time.Hour)}}
+	idle := member("idle", "backend")
+	idle.LastActiveAt = models.NewTimestamp(now.Add(-48 * time.Hour))
+	resting := member("resting", "backend")
+	resting.LastReviewCompletedAt = models.NewTimestamp(now.Add(-time.Minute))
+	fresh := member("fresh", "backend")
+	fresh.LastActiveAt = models.NewTimestamp(now)
+
+	tests := []struct {
+		name       string
+		cfg        config.Config
+		candidates []models.User
+		want       []string
+		excluded   map[string]string
+	}{
+		{
+			name:       "calendar busy always excluded",
+			candidates: []models.User{busy, wasBusy, idle},
+			want:       []string{"was-busy", "idle"},
+			excluded:   map[string]string{"busy": ExcludedCalendarBusy},
+		},
+		{
+			name:       "idle excluded under MAX_IDLE",
+			cfg:        config.Config{MaxIdle: 24 * time.Hour},
+			candidates: []models.User{idle, fresh},
+			want:       []string{"fresh"},
+			excluded:   map[string]string{"idle": ExcludedIdle},
+		},
+		{
+			name:       "cooldown excluded while others remain",
+			cfg:        config.Config{ReviewCooldown: time.Hour},
+			candidates: []models.User{resting, fresh},
+			want:       []string{"fresh"},
+			excluded:   map[string]string{"resting": ExcludedCooldown},
+		},
+		{
+			name:       "cooldown kept when nobody else is left",
+			cfg:        config.Config{ReviewCooldown: time.Hour},
+			candidates: []models.User{resting},
+			want:       []string{"resting"},
+			excluded:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &Service{cfg: &tt.cfg}
+			trace := &models.AssignmentTrace{}
+			got := svc.eligibleCandidates(slices.Clone(tt.candidates), trace)
+			if ids := userIDs(got); !slices.Equal(ids, tt.want) {
+				t.Errorf("candidates = %v, want %v", ids, tt.want)
+			}
+
+			excluded := make(map[string]string)
+			for _, entry := range trace.Excluded {
+				excluded[entry.UserID] = entry.Reason
+			}
+			if len(excluded) != len(tt.excluded) {
+				t.Errorf("excluded = %v, want %v", excluded, tt.excluded)
+			}
+			for userID, reason := range tt.excluded {
+				if excluded[userID] != reason {
+					t.Errorf("%s excluded as %q, want %q", userID, excluded[userID], reason)
+				}
+			}
+		})
+	}
+}
+
+func TestPickReviewers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	candidates := []models.User{member("a", "t"), member("b", "t"), member("c", "t")}
+
+	got := pickReviewers(rng, slices.Clone(candidates), 2)
+	if len(got) != 2 || got[0] == got[1] {
+		t.Errorf("pickReviewers = %v, want 2 distinct reviewers", got)
+	}
+	if got := pickReviewers(rng, slices.Clone(candidates), 5); len(got) != 3 {
+		t.Errorf("pickReviewers with too few candidates = %v, want all 3", got)
+	}
+	if got := pickReviewers(rng, nil, 2); got == nil || len(got) != 0 {
+		t.Errorf("pickReviewers without candidates = %#v, want empty", got)
+	}
+}
+
+func TestPickDistinctTeams(t *testing.T) {
+	candidates := []models.User{
+		member("a1", "alpha"), member("a2", "alpha"),
+		member("b1", "beta"),
+		member("c1", "gamma"), member("c2", "gamma"),
+	}
+	teams := make(map[string]string)
+	for _, candidate := range candidates {
+		teams[candidate.UserID] = candidate.TeamName
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		got := pickDistinctTeams(rand.New(rand.NewSource(seed)), slices.Clone(candidates), 3)
+		if len(got) != 3 {
+			t.Fatalf("seed %d: picked %v, want 3 reviewers", seed, got)
+		}
+		seen := make(map[string]bool)
+		for _, reviewer := range got {
+			if seen[teams[reviewer]] {
+				t.Fatalf("seed %d: %v has two reviewers from %s", seed, got, teams[reviewer])
+			}
+			seen[teams[reviewer]] = true
+		}
+	}
+
+	single := []models.User{member("a1", "alpha"), member("a2", "alpha")}
+	if got := pickDistinctTeams(rand.New(rand.NewSource(1)), single, 2); len(got) != 1 {
+		t.Errorf("one team gave %v, want a single reviewer", got)
+	}
+}
+
+func TestAssignReviewersDistinctTeamsOnlyForPools(t *testing.T) {
+	store := newFakeStore(
+		member("author", "backend"), member("b1", "backend"), member("b2", "backend"),
+		member("f1", "frontend"),
+	)
+	store.pools = map[string][]string{
+		"platform": {"b1", "b2", "f1"},
+		"backend":  {"b1", "b2"},
+	}
+	cfg := testConfig()
+	cfg.DistinctReviewerTeams = true
+	svc := NewService(store, cfg)
+	author, _ := store.GetUserByID(context.Background(), "author")
+
+	// Without a pool the candidates are the author's team
+	reviewers, _, _, err := svc.assignReviewers(context.Background(), author, &models.PullRequest{PullRequestID: "pr-1"}, nil, nil)
+	if err != nil {
+		t.Fatalf("team PR: %v", err)
+	}
+	slices.Sort(reviewers)
+	if !slices.Equal(reviewers, []string{"b1", "b2"}) {
+		t.Errorf("team PR reviewers = %v, want [b1 b2]", reviewers)
+	}
+
+	reviewers, _, _, err = svc.assignReviewers(context.Background(), author, &models.PullRequest{PullRequestID: "pr-2", ReviewerPool: "platform"}, nil, nil)
+	if err != nil {
+		t.Fatalf("pool PR: %v", err)
+	}
+	if len(reviewers) != 2 || !slices.Contains(reviewers, "f1") {
+		t.Errorf("pool PR reviewers = %v, want f1 and a backend member", reviewers)
+	}
+
+	_, _, _, err = svc.assignReviewers(context.Background(), author, &models.PullRequest{PullRequestID: "pr-3", ReviewerPool: "backend"}, nil, nil)
+	var noCandidate *NoCandidateError
+	if !errors.As(err, &noCandidate) || noCandidate.Reason != NoCandidateNotEnoughTeams {
+		t.Errorf("single-team pool error = %v, want NO_CANDIDATE %s", err, NoCandidateNotEnoughTeams)
+	}
+
+	// A pool too small for the seats isn't blamed on distinct teams
+	store.pools["solo"] = []string{"f1"}
+	_, _, _, err = svc.assignReviewers(context.Background(), author, &models.PullRequest{PullRequestID: "pr-4", ReviewerPool: "solo"}, nil, nil)
+	if !errors.As(err, &noCandidate) || noCandidate.Reason != NoCandidateNotEnoughCandidates {
+		t.Errorf("one-member pool error = %v, want NO_CANDIDATE %s", err, NoCandidateNotEnoughCandidates)
+	}
+}
+
+func TestExcludeReportingLine(t *testing.T) {
+	author := member("author", "backend")
+	author.ManagerID = "boss"
+	report := member("report", "backend")
+	report.ManagerID = "author"
+	boss := member("boss", "backend")
+	peer := member("peer", "backend")
+
+	if got := userIDs(excludeReportingLine(&author, []models.User{boss, report, peer})); !slices.Equal(got, []string{"peer"}) {
+		t.Errorf("excludeReportingLine = %v, want [peer]", got)
+	}
+	if got := userIDs(excludeReportingLine(&author, []models.User{boss, report})); !slices.Equal(got, []string{"boss", "report"}) {
+		t.Errorf("excludeReportingLine with nobody else = %v, want candidates unchanged", got)
+	}
+}
+
+func TestInReviewWindow(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2025, 1, 10, hour, 30, 0, 0, time.UTC) }
+	tests := []struct {
+		start, end, hour int
+		want             bool
+	}{
+		{9, 18, 9, true},
+		{9, 18, 17, true},
+		{9, 18, 18, false},
+		{9, 18, 3, false},
+		{22, 6, 23, true},
+		{22, 6, 5, true},
+		{22, 6, 12, false},
+		{0, 0, 12, true},
+	}
+	for _, tt := range tests {
+		if got := inReviewWindow(tt.start, tt.end, at(tt.hour)); got != tt.want {
+			t.Errorf("inReviewWindow(%d, %d) at %02d:30 = %v, want %v", tt.start, tt.end, tt.hour, got, tt.want)
+		}
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	created := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+	prs := []models.PullRequest{
+		{PullRequestID: "pr-1", CreatedAt: models.NewTimestamp(created)},
+		{PullRequestID: "pr-2", CreatedAt: models.NewTimestamp(created.Add(time.Minute))},
+	}
+
+	if got := nextCursor(prs, models.Page{Limit: 3}); got != "" {
+		t.Errorf("short page cursor = %q, want none", got)
+	}
+	if got := nextCursor(prs, models.Page{}); got != "" {
+		t.Errorf("unlimited page cursor = %q, want none", got)
+	}
+
+	token := nextCursor(prs, models.Page{Limit: 2})
+	cursor, err := models.DecodePRCursor(token)
+	if err != nil {
+		t.Fatalf("DecodePRCursor(%q): %v", token, err)
+	}
+	if cursor.PullRequestID != "pr-2" || !cursor.At.Equal(created.Add(time.Minute)) {
+		t.Errorf("cursor = %+v, want position after pr-2", cursor)
+	}
+}
+
+func TestFitPRName(t *testing.T) {
+	long := strings.Repeat("я", 12)
+
+	svc := &Service{cfg: &config.Config{MaxPRNameLength: 10, PRNameOverflow: config.OverflowReject}}
+	if name, err := svc.fitPRName("short"); err != nil || name != "short" {
+		t.Errorf("fitPRName(short) = %q, %v", name, err)
+	}
+	if _, err := svc.fitPRName(long); err != ErrPRNameTooLong {
+		t.Errorf("reject mode error = %v, want ErrPRNameTooLong", err)
+	}
+
+	svc.cfg.PRNameOverflow = config.OverflowTruncate
+	name, err := svc.fitPRName(long)
+	if err != nil {
+		t.Fatalf("truncate mode: %v", err)
+	}
+	if want := strings.Repeat("я", 9) + "…"; name != want {
+		t.Errorf("truncated name = %q, want %q", name, want)
+	}
+}
+
+func userIDs(users []models.User) []string {
+	ids := []string{}
+	for _, user := range users {
+		ids = append(ids, user.UserID)
+	}
+	return ids
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"review-service/internal/models"
+	"review-service/internal/notify"
+)
+
+// Store is the persistence the service relies on, implemented by
+// *database.DB
+type Store interface {
+	notify.WebhookResolver
+	notify.DigestStore
+
+	AddPoolMember(ctx context.Context, poolName, userID string) error
+	AddReviewer(ctx context.Context, prID, reviewerID, note string) error
+	ApproveReview(ctx context.Context, prID, reviewerID string) error
+	CompletePendingAssignment(ctx context.Context, prID string, reviewers []string) (bool, error)
+	CompleteReviews(ctx context.Context, prID string, at time.Time) error
+	CountOpenReviews(ctx context.Context, userID string) (int, error)
+	CreatePR(ctx context.Context, pr *models.PullRequest) ([]string, error)
+	CreatePool(ctx context.Context, pool *models.ReviewerPool) error
+	CreateTeam(ctx context.Context, team *models.Team) (*models.TeamMemberSummary, error)
+	CreateTeams(ctx context.Context, teams []models.Team) ([]models.TeamBatchResult, error)
+	GetActiveUsersByPool(ctx context.Context, poolName string, excludeUserID string) ([]models.User, error)
+	GetActiveUsersByTeam(ctx context.Context, teamName string, excludeUserID string) ([]models.User, error)
+	GetAppliedMigrations(ctx context.Context) ([]models.SchemaMigration, error)
+	GetApprovalStats(ctx context.Context, reviewerID string, since time.Time) (assigned, approved int, err error)
+	GetAssignmentCountsSince(ctx context.Context, userIDs []string, since time.Time) (map[string]int, error)
+	GetAvailableMentor(ctx context.Context, authorID string) (*models.User, error)
+	GetCandidatePool(ctx context.Context, teamName, poolName string) ([]models.User, error)
+	GetCoverageGaps(ctx context.Context, page models.Page) ([]models.CoverageGap, error)
+	GetDecisions(ctx context.Context, prID string) ([]models.AssignmentDecision, error)
+	GetEvents(ctx context.Context, prID string, limit int) ([]models.AuditEvent, error)
+	GetLabels(ctx context.Context, teamName string) ([]models.LabelUsage, error)
+	GetLastPairings(ctx context.Context, userIDs []string) (map[[2]string]time.Time, error)
+	GetMergeThroughput(ctx context.Context, bucket string, since, until time.Time) ([]models.ThroughputBucket, error)
+	GetNeverAssignedMembers(ctx context.Context, teamName string) ([]models.User, error)
+	GetPRByID(ctx context.Context, prID string) (*models.PullRequest, error)
+	GetPRReviewerIDs(ctx context.Context, prID string) ([]string, error)
+	GetPRReviewers(ctx context.Context, prID string) ([]models.Reviewer, error)
+	GetPRsByReviewer(ctx context.Context, reviewerID string, page models.Page) ([]models.PullRequest, error)
+	GetPRsByReviewers(ctx context.Context, userIDs []string, matchAll bool, page models.Page) ([]models.PullRequest, error)
+	GetPendingAssignmentPRs(ctx context.Context) ([]models.PullRequest, error)
+	GetPendingReviews(ctx context.Context, reviewerID string, page models.Page) ([]models.PullRequest, error)
+	GetPoolByName(ctx context.Context, name string) (*models.ReviewerPool, error)
+	GetPoolsOfUsers(ctx context.Context, userIDs []string) (map[string][]string, error)
+	GetReassignments(ctx context.Context, prID string) ([]models.Reassignment, error)
+	GetRecentAreaAuthors(ctx context.Context, userIDs, labels []string, since time.Time) ([]string, error)
+	GetResponseTimes(ctx context.Context, teamName string) ([]models.ReviewerResponseTime, error)
+	GetReviewerDistribution(ctx context.Context, teamName, status string) ([]models.ReviewerCountBucket, error)
+	GetReviewerHistory(ctx context.Context, prID string) ([]models.ReviewerHistoryEntry, error)
+	GetSoleReviewerPRs(ctx context.Context, reviewerID string) ([]models.PullRequestShort, error)
+	GetTeamAssignmentSeed(ctx context.Context, name string) (*int64, error)
+	GetTeamByName(ctx context.Context, name string) (*models.Team, error)
+	GetTeamCapacity(ctx context.Context) ([]models.TeamCapacity, error)
+	GetTeamReviewWindow(ctx context.Context, name string) (start, end *int, err error)
+	GetTeamReviewerLoad(ctx context.Context, teamName string) ([]models.ReviewerLoad, error)
+	GetTeamsOfUsers(ctx context.Context, userIDs []string) ([]string, error)
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+	GetUsersByIDs(ctx context.Context, userIDs []string) ([]models.User, error)
+	HealthCheck(ctx context.Context) error
+	ListPRs(ctx context.Context, status, authorID string, fn func(models.PullRequest) error) error
+	MarkPRReady(ctx context.Context, prID string, pending bool, reviewers []string) (bool, error)
+	MergePR(ctx context.Context, prID string) (time.Time, bool, error)
+	PRExists(ctx context.Context, prID string) (bool, error)
+	PRIDTaken(ctx context.Context, prID string) (bool, error)
+	PoolExists(ctx context.Context, name string) (bool, error)
+	PurgeDeletedPRs(ctx context.Context, before time.Time) (int, error)
+	ReassignPRReviewers(ctx context.Context, prID string, reviewers []string, replacementID string, mergedAfter *time.Time) (bool, error)
+	Reconcile(ctx context.Context, fix bool) (*models.ReconcileReport, error)
+	RecordDecision(ctx context.Context, decision *models.AssignmentDecision) error
+	RecordEvent(ctx context.Context, event *models.AuditEvent) error
+	RemovePoolMember(ctx context.Context, poolName, userID string) error
+	SetAcceptingReviews(ctx context.Context, userID string, accepting bool) error
+	SetAuthorMentor(ctx context.Context, authorID, mentorID string) error
+	SetCalendarBusy(ctx context.Context, userID string, busy *models.BusyWindow) error
+	SetNotificationPrefs(ctx context.Context, userID string, prefs models.NotificationPrefs) error
+	SoftDeletePR(ctx context.Context, prID string) (time.Time, error)
+	SuggestTeamNames(ctx context.Context, name string, limit int) ([]string, error)
+	TeamExists(ctx context.Context, name string) (bool, error)
+	TouchUser(ctx context.Context, userID string) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	UserExists(ctx context.Context, userID string) (bool, error)
+	WriteQueryMetrics(w io.Writer) error
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"review-service/internal/config"
+	"review-service/internal/database"
+	"review-service/internal/models"
+)
+
+// fakeStore keeps users and PRs in memory. Methods a test reaches without
+// them being implemented here panic through the nil embedded Store
+type fakeStore struct {
+	Store
+
+	mu        sync.Mutex
+	users     map[string]models.User
+	pools     map[string][]string
+	prs       map[string]*models.PullRequest
+	events    []models.AuditEvent
+	decisions []models.AssignmentDecision
+
+	// approvals holds approved_at by PR and reviewer, approveErr fails ApproveReview
+	approvals  map[string]map[string]time.Time
+	approveErr error
+
+	// prErr fails every PR lookup, as a lost connection would
+	prErr error
+
+	// reviewerCap stands in for the pr_reviewers trigger when non-zero
+	reviewerCap int
+
+	// beforeReassign runs at the start of ReassignPRReviewers, before the PR
+	// is locked, standing in for a concurrent change
+	beforeReassign func(replacementID string)
+
+	// webhookLookups counts resolved webhooks, one per dispatched event
+	webhookLookups int
+}
+
+func newFakeStore(users ...models.User) *fakeStore {
+	store := &fakeStore{
+		users:     make(map[string]models.User),
+		prs:       make(map[string]*models.PullRequest),
+		approvals: make(map[string]map[string]time.Time),
+	}
+	for _, user := range users {
+		store.users[user.UserID] = user
+	}
+	return store
+}
+
+func (f *fakeStore) addPR(pr models.PullRequest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prs[pr.PullRequestID] = &pr
+}
+
+func (f *fakeStore) eventsOfType(eventType string) []models.AuditEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var events []models.AuditEvent
+	for _, event := range f.events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (f *fakeStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, database.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (f *fakeStore) GetActiveUsersByTeam(ctx context.Context, teamName string, excludeUserID string) ([]models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []models.User
+	for _, user := range f.users {
+		if user.TeamName != teamName || !user.IsActive || user.UserID == excludeUserID {
+			continue
+		}
+		if user.AcceptingReviews != nil && !*user.AcceptingReviews {
+			continue
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	return users, nil
+}
+
+func (f *fakeStore) GetActiveUsersByPool(ctx context.Context, poolName string, excludeUserID string) ([]models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []models.User
+	for _, userID := range f.pools[poolName] {
+		user := f.users[userID]
+		if user.IsActive && user.UserID != excludeUserID {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeStore) GetUsersByIDs(ctx context.Context, userIDs []string) ([]models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []models.User
+	for _, userID := range userIDs {
+		if user, ok := f.users[userID]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeStore) GetPoolsOfUsers(ctx context.Context, userIDs []string) (map[string][]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pools := make(map[string][]string)
+	for pool, members := range f.pools {
+		for _, userID := range members {
+			if slices.Contains(userIDs, userID) {
+				pools[userID] = append(pools[userID], pool)
+			}
+		}
+	}
+	return pools, nil
+}
+
+func (f *fakeStore) GetAvailableMentor(ctx context.Context, authorID string) (*models.User, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetTeamAssignmentSeed(ctx context.Context, name string) (*int64, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetTeamReviewWindow(ctx context.Context, name string) (start, end *int, err error) {
+	return nil, nil, nil
+}
+
+func (f *fakeStore) GetPRByID(ctx context.Context, prID string) (*models.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.prErr != nil {
+		return nil, f.prErr
+	}
+	pr, ok := f.prs[prID]
+	if !ok || pr.DeletedAt != nil {
+		return nil, database.ErrPRNotFound
+	}
+	copied := *pr
+	copied.AssignedReviewers = slices.Clone(pr.AssignedReviewers)
+	return &copied, nil
+}
+
+func (f *fakeStore) GetPRReviewerIDs(ctx context.Context, prID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.prs[prID].AssignedReviewers), nil
+}
+
+// ReassignPRReviewers checks the PR and the replacement under the lock like
+// the database does
+func (f *fakeStore) ReassignPRReviewers(ctx context.Context, prID string, reviewers []string, replacementID string, mergedAfter *time.Time) (bool, error) {
+	if f.beforeReassign != nil {
+		f.beforeReassign(replacementID)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, ok := f.prs[prID]
+	if !ok || pr.DeletedAt != nil {
+		return false, database.ErrPRNotFound
+	}
+	if pr.Status == models.PRStatusMerged && (mergedAfter == nil || pr.MergedAt.Before(*mergedAfter)) {
+		return false, database.ErrPRMerged
+	}
+	if replacementID != "" {
+		if user, ok := f.users[replacementID]; !ok || !user.IsActive {
+			return false, nil
+		}
+	}
+	pr.AssignedReviewers = slices.Clone(reviewers)
+	return true, nil
+}
+
+func (f *fakeStore) setActive(userID string, active bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user := f.users[userID]
+	user.IsActive = active
+	f.users[userID] = user
+}
+
+func (f *fakeStore) MergePR(ctx context.Context, prID string) (time.Time, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, ok := f.prs[prID]
+	if !ok || pr.Status != models.PRStatusOpen || pr.DeletedAt != nil {
+		return time.Time{}, false, nil
+	}
+	now := time.Now()
+	pr.Status = models.PRStatusMerged
+	pr.MergedAt = models.NewTimestamp(now)
+	return now, true, nil
+}
+
+func (f *fakeStore) ApproveReview(ctx context.Context, prID, reviewerID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.approveErr != nil {
+		return f.approveErr
+	}
+	if !slices.Contains(f.prs[prID].AssignedReviewers, reviewerID) {
+		return database.ErrReviewerNotAssigned
+	}
+	if f.approvals[prID] == nil {
+		f.approvals[prID] = make(map[string]time.Time)
+	}
+	if _, ok := f.approvals[prID][reviewerID]; !ok {
+		f.approvals[prID][reviewerID] = time.Now()
+	}
+	return nil
+}
+
+func (f *fakeStore) TouchUser(ctx context.Context, userID string) error {
+	return nil
+}
+
+func (f *fakeStore) GetPRReviewers(ctx context.Context, prID string) ([]models.Reviewer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reviewers := []models.Reviewer{}
+	for _, reviewerID := range f.prs[prID].AssignedReviewers {
+		reviewer := models.Reviewer{UserID: reviewerID}
+		if approvedAt, ok := f.approvals[prID][reviewerID]; ok {
+			reviewer.ApprovedAt = models.NewTimestamp(approvedAt)
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	return reviewers, nil
+}
+
+func (f *fakeStore) UserExists(ctx context.Context, userID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.users[userID]
+	return ok, nil
+}
+
+func (f *fakeStore) GetApprovalStats(ctx context.Context, reviewerID string, since time.Time) (assigned, approved int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, pr := range f.prs {
+		if pr.Status != models.PRStatusMerged || pr.MergedAt.Before(since) || !slices.Contains(pr.AssignedReviewers, reviewerID) {
+			continue
+		}
+		assigned++
+		if _, ok := f.approvals[pr.PullRequestID][reviewerID]; ok {
+			approved++
+		}
+	}
+	return assigned, approved, nil
+}
+
+func (f *fakeStore) PRIDTaken(ctx context.Context, prID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.prs[prID]
+	return ok, nil
+}
+
+func (f *fakeStore) CreatePR(ctx context.Context, pr *models.PullRequest) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *pr
+	copied.AssignedReviewers = slices.Clone(pr.AssignedReviewers)
+	f.prs[pr.PullRequestID] = &copied
+	return nil, nil
+}
+
+func (f *fakeStore) PRExists(ctx context.Context, prID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, ok := f.prs[prID]
+	return ok && pr.DeletedAt == nil, nil
+}
+
+func (f *fakeStore) AddReviewer(ctx context.Context, prID, reviewerID, note string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr := f.prs[prID]
+	if slices.Contains(pr.AssignedReviewers, reviewerID) {
+		return nil
+	}
+	if f.reviewerCap > 0 && len(pr.AssignedReviewers) >= f.reviewerCap {
+		return fmt.Errorf("failed to assign reviewer %s to PR %s: %w", reviewerID, prID, database.ErrTooManyReviewers)
+	}
+	pr.AssignedReviewers = append(pr.AssignedReviewers, reviewerID)
+	return nil
+}
+
+func (f *fakeStore) SoftDeletePR(ctx context.Context, prID string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, ok := f.prs[prID]
+	if !ok || pr.DeletedAt != nil {
+		return time.Time{}, database.ErrPRNotFound
+	}
+	now := time.Now()
+	pr.DeletedAt = models.NewTimestamp(now)
+	return now, nil
+}
+
+func (f *fakeStore) PurgeDeletedPRs(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	purged := 0
+	for prID, pr := range f.prs {
+		if pr.DeletedAt != nil && pr.DeletedAt.Before(before) {
+			delete(f.prs, prID)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (f *fakeStore) CompleteReviews(ctx context.Context, prID string, at time.Time) error {
+	return nil
+}
+
+func (f *fakeStore) RecordEvent(ctx context.Context, event *models.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, *event)
+	return nil
+}
+
+func (f *fakeStore) RecordDecision(ctx context.Context, decision *models.AssignmentDecision) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.decisions = append(f.decisions, *decision)
+	return nil
+}
+
+func (f *fakeStore) GetNotifiableUsers(ctx context.Context, userIDs []string, channel string) ([]string, error) {
+	return userIDs, nil
+}
+
+func (f *fakeStore) GetAuthorWebhookURL(ctx context.Context, authorID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.webhookLookups++
+	return "", nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		MaxReviewers:       2,
+		MaxPRNameLength:    500,
+		AssignmentStrategy: config.StrategyRandom,
+	}
+}
+
+func member(userID, teamName string) models.User {
+	return models.User{UserID: userID, Username: userID, TeamName: teamName, IsActive: true}
+}
@@ -0,0 +1,53 @@
+package version
+
+import "runtime/debug"
+
+// Set at build time via
+// -ldflags "-X review-service/internal/version.Version=... -X ...Commit=... -X ...BuildDate=..."
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// Get returns the injected build info, falling back to what the Go toolchain
+// embedded into the binary for values that weren't injected
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = buildInfo.Main.Version
+		}
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "unknown"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+
+	return info
+}
@@ -1,33 +1,33 @@
-CREATE TABLE teams (
-    name VARCHAR(255) PRIMARY KEY,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE users (
-    user_id VARCHAR(255) PRIMARY KEY,
-    username VARCHAR(255) NOT NULL,
-    team_name VARCHAR(255) NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
-    is_active BOOLEAN DEFAULT true,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE pull_requests (
-    pull_request_id VARCHAR(255) PRIMARY KEY,
-    pull_request_name VARCHAR(500) NOT NULL,
-    author_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
-    status VARCHAR(20) DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'MERGED')),
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    merged_at TIMESTAMP NULL
-);
-
-CREATE TABLE pr_reviewers (
-    pr_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
-    reviewer_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
-    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    PRIMARY KEY (pr_id, reviewer_id)
-);
-
-CREATE INDEX idx_users_team_active ON users(team_name, is_active);
-CREATE INDEX idx_pr_status ON pull_requests(status);
-CREATE INDEX idx_pr_author ON pull_requests(author_id);
+CREATE TABLE teams (
+    name VARCHAR(255) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE users (
+    user_id VARCHAR(255) PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    team_name VARCHAR(255) NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE pull_requests (
+    pull_request_id VARCHAR(255) PRIMARY KEY,
+    pull_request_name VARCHAR(500) NOT NULL,
+    author_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+    status VARCHAR(20) DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'MERGED')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    merged_at TIMESTAMP NULL
+);
+
+CREATE TABLE pr_reviewers (
+    pr_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (pr_id, reviewer_id)
+);
+
+CREATE INDEX idx_users_team_active ON users(team_name, is_active);
+CREATE INDEX idx_pr_status ON pull_requests(status);
+CREATE INDEX idx_pr_author ON pull_requests(author_id);
 CREATE INDEX idx_pr_reviewers_reviewer ON pr_reviewers(reviewer_id);
\ No newline at end of file
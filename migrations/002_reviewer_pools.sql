@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS reviewer_pools (
+    name VARCHAR(255) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS reviewer_pool_members (
+    pool_name VARCHAR(255) NOT NULL REFERENCES reviewer_pools(name) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    PRIMARY KEY (pool_name, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pool_members_user ON reviewer_pool_members(user_id);
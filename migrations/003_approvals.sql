@@ -0,0 +1 @@
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP NULL;
@@ -0,0 +1 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS created_by VARCHAR(255) NULL;
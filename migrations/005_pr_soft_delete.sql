@@ -0,0 +1 @@
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
@@ -0,0 +1 @@
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS note TEXT NULL;
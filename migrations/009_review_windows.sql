@@ -0,0 +1,7 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS review_window_start SMALLINT NULL CHECK (review_window_start BETWEEN 0 AND 23);
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS review_window_end SMALLINT NULL CHECK (review_window_end BETWEEN 0 AND 23);
+
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS reviewer_pool VARCHAR(255) NULL;
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS pending_assignment BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_pr_pending_assignment ON pull_requests(pending_assignment) WHERE pending_assignment;
@@ -0,0 +1 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS webhook_url TEXT NULL;
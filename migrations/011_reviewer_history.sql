@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS pr_reviewer_history (
+    id BIGSERIAL PRIMARY KEY,
+    pr_id VARCHAR(255) NOT NULL,
+    reviewer_id VARCHAR(255) NOT NULL,
+    assigned_at TIMESTAMP NOT NULL DEFAULT now(),
+    removed_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviewer_history_reviewer ON pr_reviewer_history(reviewer_id);
+CREATE INDEX IF NOT EXISTS idx_reviewer_history_pr ON pr_reviewer_history(pr_id);
+
+INSERT INTO pr_reviewer_history (pr_id, reviewer_id, assigned_at)
+SELECT r.pr_id, r.reviewer_id, p.created_at
+FROM pr_reviewers r
+JOIN pull_requests p ON p.pull_request_id = r.pr_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM pr_reviewer_history h WHERE h.pr_id = r.pr_id AND h.reviewer_id = r.reviewer_id
+);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS accepting_reviews BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS manager_id VARCHAR(255) NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_manager ON users(manager_id);
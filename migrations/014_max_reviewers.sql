@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS settings (
+    name VARCHAR(255) PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION enforce_max_reviewers() RETURNS trigger AS $$
+DECLARE
+    max_reviewers INTEGER;
+BEGIN
+    SELECT value::INTEGER INTO max_reviewers FROM settings WHERE name = 'max_reviewers';
+    IF max_reviewers IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    -- Serialize concurrent assigns to the same PR
+    PERFORM 1 FROM pull_requests WHERE pull_request_id = NEW.pr_id FOR UPDATE;
+
+    -- Re-inserting a current reviewer (ON CONFLICT DO NOTHING) never grows the list
+    IF NOT EXISTS (SELECT 1 FROM pr_reviewers WHERE pr_id = NEW.pr_id AND reviewer_id = NEW.reviewer_id)
+       AND (SELECT COUNT(*) FROM pr_reviewers WHERE pr_id = NEW.pr_id) >= max_reviewers THEN
+        RAISE EXCEPTION 'PR % already has % reviewers', NEW.pr_id, max_reviewers
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'pr_reviewers_max_reviewers';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS pr_reviewers_max_reviewers ON pr_reviewers;
+CREATE TRIGGER pr_reviewers_max_reviewers
+    BEFORE INSERT ON pr_reviewers
+    FOR EACH ROW EXECUTE FUNCTION enforce_max_reviewers();
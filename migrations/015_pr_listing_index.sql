@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_pr_created_id ON pull_requests(created_at, pull_request_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    pull_request_id VARCHAR(255) NULL,
+    user_id VARCHAR(255) NULL,
+    actor_id VARCHAR(255) NOT NULL,
+    details JSONB NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_pr ON audit_events(pull_request_id);
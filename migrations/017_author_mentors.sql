@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS author_mentors (
+    author_id VARCHAR(255) PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    mentor_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE
+);
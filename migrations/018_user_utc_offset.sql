@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS utc_offset_minutes SMALLINT NULL CHECK (utc_offset_minutes BETWEEN -720 AND 840);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_prefs JSONB NOT NULL
+    DEFAULT '{"assignments": true, "channels": ["webhook"]}';
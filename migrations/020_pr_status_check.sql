@@ -0,0 +1,12 @@
+-- 001 declares the check inline; make sure databases created without it get it
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'pull_requests'::regclass AND conname = 'pull_requests_status_check'
+    ) THEN
+        ALTER TABLE pull_requests
+            ADD CONSTRAINT pull_requests_status_check CHECK (status IN ('OPEN', 'MERGED'));
+    END IF;
+END;
+$$;
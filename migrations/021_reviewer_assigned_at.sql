@@ -0,0 +1,3 @@
+UPDATE pr_reviewers SET assigned_at = CURRENT_TIMESTAMP WHERE assigned_at IS NULL;
+ALTER TABLE pr_reviewers ALTER COLUMN assigned_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE pr_reviewers ALTER COLUMN assigned_at SET NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS review_required BOOLEAN NOT NULL DEFAULT true;
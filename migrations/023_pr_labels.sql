@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS pr_labels (
+    pr_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    label VARCHAR(100) NOT NULL,
+    PRIMARY KEY (pr_id, label)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pr_labels_label ON pr_labels(label);
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_review_completed_at TIMESTAMP NULL;
+
+UPDATE users u SET last_review_completed_at = done.merged_at
+FROM (
+    SELECT r.reviewer_id, MAX(p.merged_at) AS merged_at
+    FROM pr_reviewers r JOIN pull_requests p ON p.pull_request_id = r.pr_id
+    WHERE p.status = 'MERGED' AND p.merged_at IS NOT NULL
+    GROUP BY r.reviewer_id
+) done
+WHERE u.user_id = done.reviewer_id AND u.last_review_completed_at IS NULL;
@@ -0,0 +1,14 @@
+-- Allow DRAFT, replacing the OPEN/MERGED check from 001/020 once
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'pull_requests'::regclass AND conname = 'pull_requests_status_check'
+          AND pg_get_constraintdef(oid) LIKE '%DRAFT%'
+    ) THEN
+        ALTER TABLE pull_requests DROP CONSTRAINT IF EXISTS pull_requests_status_check;
+        ALTER TABLE pull_requests
+            ADD CONSTRAINT pull_requests_status_check CHECK (status IN ('OPEN', 'MERGED', 'DRAFT'));
+    END IF;
+END;
+$$;
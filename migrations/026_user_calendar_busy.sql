@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS busy_from TIMESTAMPTZ NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS busy_until TIMESTAMPTZ NULL;
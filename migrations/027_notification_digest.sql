@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+    id BIGSERIAL PRIMARY KEY,
+    team_name VARCHAR(255) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS assignment_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    strategy VARCHAR(64) NOT NULL,
+    candidate_pool_size INTEGER NOT NULL,
+    selected TEXT[] NOT NULL,
+    replaced_user_id VARCHAR(255) NULL,
+    fallback VARCHAR(32) NULL,
+    actor_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_assignment_decisions_pr ON assignment_decisions(pull_request_id);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS seniority VARCHAR(16) NULL CHECK (seniority IN ('junior', 'senior'));